@@ -5,6 +5,7 @@ import (
 	"context"
 	"expvar"
 	"math"
+	"os"
 	"reflect"
 	"sync"
 	"time"
@@ -30,12 +31,24 @@ type BatchHandler func(context.Context, []byte) error
 // Batcher implements buffered batcher
 type Batcher struct {
 	mu sync.Mutex
+	// muBatch keeps order of handling batches
+	muBatch sync.Mutex
 
 	buf        [][]byte
 	bufSize    int
 	maxBytes   int
 	ticker     *time.Ticker
 	tickerExit chan bool
+	period     time.Duration
+	debounce   bool
+
+	wal     *os.File
+	walPath string
+	walSeq  int
+	// walAttempts counts handling of payloads failed before
+	walAttempts int
+	// walRetryPaths keeps files of failed payloads put back into the buffer
+	walRetryPaths []string
 
 	builder BatchBuilder
 	handler BatchHandler
@@ -61,6 +74,7 @@ func NewBatcher(
 		maxBytes:   maxBytes,
 		ticker:     time.NewTicker(d),
 		tickerExit: make(chan bool, 1),
+		period:     d,
 
 		builder: bb,
 		handler: bh,
@@ -104,30 +118,52 @@ func (bt *Batcher) Add(p []byte) {
 
 	bt.buf = append(bt.buf, p)
 	bt.bufSize += len(p)
+	if bt.debounce {
+		bt.ticker.Reset(bt.period)
+	}
+	if bt.wal != nil {
+		if err := writeWALRecord(bt.wal, p); err != nil {
+			log.Err(err).Str("bt.tracerName", bt.tracerName).
+				Msg("Batcher.Add could not write WAL")
+		}
+	}
 
 	tracing.EndTraceSpan(span,
 		tracing.TraceAttrPayloadDbg(p),
 		tracing.TraceAttrPayloadLen(p),
 	)
 
+	bufSize, maxBytes := bt.bufSize, bt.maxBytes
 	bt.mu.Unlock()
-	if bt.bufSize > bt.maxBytes {
+	if bufSize > maxBytes {
 		log.Trace().Str("bt.tracerName", bt.tracerName).
 			Msgf("batch buffer size %dKB exceeded the soft limit %dKB",
-				bt.bufSize/1024, bt.maxBytes/1024)
+				bufSize/1024, maxBytes/1024)
 		bt.Batch()
 	}
 }
 
 // Batch processes buffered payloads
 func (bt *Batcher) Batch() {
+	bt.muBatch.Lock()
+	defer bt.muBatch.Unlock()
 	bt.xBatchedAt.Set(time.Now().UnixMilli())
 	bt.mu.Lock()
 
-	buf, bufSize := bt.buf, bt.bufSize
+	buf, bufSize, maxBytes := bt.buf, bt.bufSize, bt.maxBytes
 	bt.buf, bt.bufSize = make([][]byte, 0), 0
+	/* keep batching payloads in the separate file until handled */
+	var walBatchPath string
+	var walRetryPaths []string
+	var walAttempts int
+	if bt.wal != nil && len(buf) > 0 {
+		walBatchPath = bt.rotateWAL()
+		walRetryPaths, bt.walRetryPaths = bt.walRetryPaths, nil
+		walAttempts, bt.walAttempts = bt.walAttempts+1, 0
+	}
 
 	bt.mu.Unlock()
+	var failed [][]byte
 	if len(buf) > 0 {
 		func() {
 			/* wrap into closure for simple defer,
@@ -135,7 +171,7 @@ func (bt *Batcher) Batch() {
 			ctx, span := tracing.StartTraceSpan(bt.traceCtx, bt.tracerName, "batcher:Batch")
 			defer func() {
 				tracing.EndTraceSpan(span,
-					tracing.TraceAttrInt("maxBytes", bt.maxBytes),
+					tracing.TraceAttrInt("maxBytes", maxBytes),
 					tracing.TraceAttrInt("bufferLen", len(buf)),
 					tracing.TraceAttrInt("bufferSize", bufSize),
 					tracing.TraceAttrFnDbg("buffer", func() string { return string(bytes.Join(buf, []byte("\n"))) }),
@@ -146,19 +182,20 @@ func (bt *Batcher) Batch() {
 				bt.traceCtx, bt.traceSpan = tracing.StartTraceSpan(context.Background(), bt.tracerName, "batching")
 			}()
 
-			bt.builder.Build(&buf, bt.maxBytes)
+			bt.builder.Build(&buf, maxBytes)
 			log.Trace().Func(func(e *zerolog.Event) { // process only if loglevel enabled
 				e.RawJSON("buf", append(append([]byte("["), bytes.Join(buf, []byte(","))...), ']'))
 			}).
 				Str("bt.tracerName", bt.tracerName).
 				Int("bufferLen", len(buf)).
 				Int("bufferSize", bufSize).
-				Int("maxBytes", bt.maxBytes).
+				Int("maxBytes", maxBytes).
 				Msg("Batcher.Batch")
 			if len(buf) > 0 {
 				for _, p := range buf {
 					if len(p) > 0 {
 						if err := bt.handler(ctx, p); err != nil {
+							failed = append(failed, p)
 							log.Err(err).Str("bt.tracerName", bt.tracerName).
 								RawJSON("payload", p).
 								Int("payloadLen", len(p)).
@@ -169,6 +206,9 @@ func (bt *Batcher) Batch() {
 			}
 		}()
 	}
+	if walAttempts > 0 {
+		bt.keepWALBatch(walBatchPath, walRetryPaths, failed, walAttempts)
+	}
 }
 
 // Exit stops the internal ticker
@@ -183,15 +223,34 @@ func (bt *Batcher) Clear() {
 
 	bt.buf = make([][]byte, 0)
 	bt.bufSize = 0
+	bt.walAttempts = 0
+	removeWALFiles(bt.walRetryPaths)
+	bt.walRetryPaths = nil
+	if bt.wal != nil {
+		if err := bt.wal.Truncate(0); err != nil {
+			log.Err(err).Str("bt.tracerName", bt.tracerName).
+				Msg("Batcher.Clear could not truncate WAL")
+		}
+	}
 }
 
 // Reset applies configuration
 func (bt *Batcher) Reset(d time.Duration, maxBytes int) {
 	log.Trace().Str("bt.tracerName", bt.tracerName).Msg("Batcher.Reset")
 	bt.Batch()
-	bt.maxBytes = maxBytes
 	if d == 0 {
 		d = math.MaxInt64
 	}
+	bt.mu.Lock()
+	bt.maxBytes, bt.period = maxBytes, d
+	bt.mu.Unlock()
 	bt.ticker.Reset(d)
 }
+
+// SetDebounce makes the batching period restart on each added payload,
+// so the buffer is handled after the period without additions or on exceeding maxBytes
+func (bt *Batcher) SetDebounce(b bool) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	bt.debounce = b
+}
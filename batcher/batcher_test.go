@@ -0,0 +1,36 @@
+package batcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebounce(t *testing.T) {
+	sent := make(chan string, 1)
+	bt := NewBatcher(joinBuilder{}, func(_ context.Context, p []byte) error {
+		sent <- string(p)
+		return nil
+	}, time.Millisecond*100, 1024)
+	bt.SetDebounce(true)
+
+	/* additions within the period postpone batching */
+	for _, s := range []string{"a", "b", "c", "d"} {
+		bt.Add([]byte(s))
+		time.Sleep(time.Millisecond * 40)
+	}
+	select {
+	case p := <-sent:
+		t.Fatalf("unexpected batch: %s", p)
+	default:
+	}
+	select {
+	case p := <-sent:
+		assert.Equal(t, "a,b,c,d", p)
+	case <-time.After(time.Second):
+		t.Fatal("batch is not handled")
+	}
+	bt.Exit()
+}
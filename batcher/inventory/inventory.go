@@ -0,0 +1,130 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gwos/tcg/sdk/transit"
+	"github.com/rs/zerolog/log"
+)
+
+// InventoryBatchBuilder implements builder
+type InventoryBatchBuilder struct{}
+
+// Build merges buffered inventory payloads into one per agent:
+// resources and services are united by name keeping the latest info and properties,
+// groups are united by type and name with union of resources,
+// empty inventory is kept as it clears the agent inventory
+func (bld *InventoryBatchBuilder) Build(buf *[][]byte, _ int) {
+	if len(*buf) < 2 {
+		return
+	}
+
+	/* keep agents in order of the first payload */
+	agents := make([]string, 0)
+	merges := make(map[string]*merge)
+	for _, p := range *buf {
+		var q transit.InventoryRequest
+		if err := json.Unmarshal(p, &q); err != nil {
+			log.Err(err).
+				RawJSON("payload", p).
+				Msg("could not unmarshal inventory payload for batch")
+			continue
+		}
+		m, ok := merges[q.Context.AgentID]
+		if !ok {
+			m = newMerge()
+			merges[q.Context.AgentID] = m
+			agents = append(agents, q.Context.AgentID)
+		}
+		m.add(q)
+	}
+
+	*buf = make([][]byte, 0)
+	for _, agentID := range agents {
+		bq := merges[agentID].bq
+		p, err := json.Marshal(bq)
+		if err != nil {
+			log.Err(err).
+				Str("inventory", fmt.Sprintf("%+v", bq)).
+				Msg("could not marshal inventory")
+			continue
+		}
+		log.Debug().
+			Int("payloadLen", len(p)).
+			Str("agentId", agentID).
+			Msgf("batched %d resources", len(bq.Resources))
+		*buf = append(*buf, p)
+	}
+}
+
+// merge unites inventory payloads of one agent
+type merge struct {
+	bq     transit.InventoryRequest
+	resIdx map[string]int
+	svcIdx map[string]map[string]int
+	grpIdx map[string]int
+	refIdx map[string]map[string]bool
+}
+
+func newMerge() *merge {
+	return &merge{
+		resIdx: make(map[string]int),
+		svcIdx: make(map[string]map[string]int),
+		grpIdx: make(map[string]int),
+		refIdx: make(map[string]map[string]bool),
+	}
+}
+
+func (m *merge) add(q transit.InventoryRequest) {
+	bq := &m.bq
+	bq.SetContext(q.Context)
+	if q.OwnershipType != "" {
+		bq.OwnershipType = q.OwnershipType
+	}
+
+	for _, res := range q.Resources {
+		rk := key(string(res.Type), res.Name)
+		i, ok := m.resIdx[rk]
+		if !ok {
+			m.resIdx[rk], m.svcIdx[rk] = len(bq.Resources), make(map[string]int)
+			bq.Resources = append(bq.Resources, transit.InventoryResource{})
+			i = m.resIdx[rk]
+		}
+		services := bq.Resources[i].Services
+		bq.Resources[i].BaseResource = res.BaseResource
+		for _, svc := range res.Services {
+			if j, ok := m.svcIdx[rk][svc.Name]; ok {
+				services[j] = svc
+				continue
+			}
+			m.svcIdx[rk][svc.Name] = len(services)
+			services = append(services, svc)
+		}
+		bq.Resources[i].Services = services
+	}
+
+	for _, g := range q.Groups {
+		gk := key(string(g.Type), g.GroupName)
+		i, ok := m.grpIdx[gk]
+		if !ok {
+			m.grpIdx[gk], m.refIdx[gk] = len(bq.Groups), make(map[string]bool)
+			bq.Groups = append(bq.Groups, transit.ResourceGroup{})
+			i = m.grpIdx[gk]
+		}
+		refs := bq.Groups[i].Resources
+		bq.Groups[i] = g
+		for _, ref := range g.Resources {
+			if rk := key(string(ref.Type), ref.Name); !m.refIdx[gk][rk] {
+				m.refIdx[gk][rk] = true
+				refs = append(refs, ref)
+			}
+		}
+		bq.Groups[i].Resources = refs
+	}
+}
+
+func key(s ...string) string {
+	return strings.Join(s, ":")
+}
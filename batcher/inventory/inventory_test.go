@@ -0,0 +1,73 @@
+package inventory
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gwos/tcg/sdk/transit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuild(t *testing.T) {
+	buf := [][]byte{
+		[]byte(`{"context":{"appType":"APM","traceToken":"t1"},
+			"resources":[{"name":"h1","type":"host","properties":{"p":{"valueType":"StringType","stringValue":"v1"}},
+				"services":[{"name":"s1","type":"service","owner":"h1"}]}],
+			"groups":[{"groupName":"g1","type":"HostGroup","resources":[{"name":"h1","type":"host"}]}]}`),
+		[]byte(`{"context":{"appType":"APM","traceToken":"t2"},
+			"resources":[{"name":"h2","type":"host","services":[]}],
+			"groups":[{"groupName":"g1","type":"HostGroup","resources":[{"name":"h2","type":"host"}]}]}`),
+		[]byte(`{"context":{"appType":"APM","traceToken":"t3"},
+			"resources":[{"name":"h1","type":"host","properties":{"p":{"valueType":"StringType","stringValue":"v3"}},
+				"services":[{"name":"s2","type":"service","owner":"h1"}]}],
+			"groups":[{"groupName":"g1","type":"HostGroup","resources":[{"name":"h1","type":"host"}]}]}`),
+	}
+	new(InventoryBatchBuilder).Build(&buf, 1024)
+	assert.Len(t, buf, 1)
+
+	var q transit.InventoryRequest
+	assert.NoError(t, json.Unmarshal(buf[0], &q))
+	assert.Equal(t, "t3", q.Context.TraceToken)
+	if assert.Len(t, q.Resources, 2) {
+		assert.Equal(t, "h1", q.Resources[0].Name)
+		assert.Equal(t, "v3", *q.Resources[0].Properties["p"].StringValue)
+		assert.Len(t, q.Resources[0].Services, 2)
+		assert.Equal(t, "h2", q.Resources[1].Name)
+	}
+	if assert.Len(t, q.Groups, 1) {
+		assert.Len(t, q.Groups[0].Resources, 2)
+	}
+}
+
+func TestBuildByAgent(t *testing.T) {
+	buf := [][]byte{
+		[]byte(`{"context":{"agentId":"agent1","traceToken":"t1"},"resources":[{"name":"h1","type":"host"}]}`),
+		[]byte(`{"context":{"agentId":"agent2","traceToken":"t2"},"resources":[{"name":"h2","type":"host"}]}`),
+		[]byte(`{"context":{"agentId":"agent1","traceToken":"t3"},"resources":[{"name":"h3","type":"host"}]}`),
+	}
+	new(InventoryBatchBuilder).Build(&buf, 1024)
+	if assert.Len(t, buf, 2) {
+		var q1, q2 transit.InventoryRequest
+		assert.NoError(t, json.Unmarshal(buf[0], &q1))
+		assert.NoError(t, json.Unmarshal(buf[1], &q2))
+		assert.Equal(t, "agent1", q1.Context.AgentID)
+		assert.Equal(t, "t3", q1.Context.TraceToken)
+		assert.Len(t, q1.Resources, 2)
+		assert.Equal(t, "agent2", q2.Context.AgentID)
+		assert.Len(t, q2.Resources, 1)
+	}
+}
+
+func TestBuildEmpty(t *testing.T) {
+	buf := [][]byte{
+		[]byte(`{"context":{"agentId":"agent1","traceToken":"t1"},"resources":[]}`),
+		[]byte(`{"context":{"agentId":"agent1","traceToken":"t2"},"resources":[],"groups":[]}`),
+	}
+	new(InventoryBatchBuilder).Build(&buf, 1024)
+	if assert.Len(t, buf, 1) {
+		var q transit.InventoryRequest
+		assert.NoError(t, json.Unmarshal(buf[0], &q))
+		assert.Equal(t, "t2", q.Context.TraceToken)
+		assert.Empty(t, q.Resources)
+	}
+}
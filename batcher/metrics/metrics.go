@@ -13,10 +13,17 @@ import (
 type MetricsBatchBuilder struct{}
 
 // Build builds the batch payloads for HostUnchanged and not empty
-// splits incoming payloads bigger than maxBytes
+// splits incoming payloads bigger than maxBytes,
+// combines payloads of the same agent only
 func (bld *MetricsBatchBuilder) Build(buf *[][]byte, maxBytes int) {
-	// counter, batched request, and accum
-	c, bq, qq := 0, transit.ResourcesWithServicesRequest{}, make([]transit.ResourcesWithServicesRequest, 0)
+	// counter and batched request by agent, keep agents in order of the first payload
+	type batch struct {
+		c  int
+		bq transit.ResourcesWithServicesRequest
+	}
+	agents, batches := make([]string, 0), make(map[string]*batch)
+	// accum
+	qq := make([]transit.ResourcesWithServicesRequest, 0)
 	var q transit.ResourcesWithServicesRequest
 
 	for _, p := range *buf {
@@ -33,29 +40,38 @@ func (bld *MetricsBatchBuilder) Build(buf *[][]byte, maxBytes int) {
 			continue
 		}
 
+		b, ok := batches[q.Context.AgentID]
+		if !ok {
+			b = new(batch)
+			batches[q.Context.AgentID] = b
+			agents = append(agents, q.Context.AgentID)
+		}
+
 		// in case of not HostUnchanged stop combining, put bq and q into accum
 		if hasStatus(&q) {
-			if len(bq.Resources) > 0 {
-				qq = append(qq, bq)
-				c, bq = 0, transit.ResourcesWithServicesRequest{}
+			if len(b.bq.Resources) > 0 {
+				qq = append(qq, b.bq)
+				*b = batch{}
 			}
 			qq = append(qq, q)
 			continue
 		}
 
-		bq.SetContext(q.Context)
-		bq.Groups = append(bq.Groups, q.Groups...)
-		bq.Resources = append(bq.Resources, q.Resources...)
-		c += len(p)
-		if c >= maxBytes {
-			qq = append(qq, bq)
-			c, bq = 0, transit.ResourcesWithServicesRequest{}
+		b.bq.SetContext(q.Context)
+		b.bq.Groups = append(b.bq.Groups, q.Groups...)
+		b.bq.Resources = append(b.bq.Resources, q.Resources...)
+		b.c += len(p)
+		if b.c >= maxBytes {
+			qq = append(qq, b.bq)
+			*b = batch{}
 		}
 	}
 	*buf = make([][]byte, 0)
 
-	if len(bq.Resources) > 0 {
-		qq = append(qq, bq)
+	for _, agentID := range agents {
+		if b := batches[agentID]; len(b.bq.Resources) > 0 {
+			qq = append(qq, b.bq)
+		}
 	}
 
 	for _, q := range qq {
@@ -167,6 +167,28 @@ func TestBuild(t *testing.T) {
 		assert.Equal(t, transit.HostUnchanged, qq[2].Resources[1].Status)
 		assert.Equal(t, transit.HostUnscheduledDown, qq[3].Resources[0].Status)
 	})
+
+	t.Run("combine by agent", func(t *testing.T) {
+		buf := [][]byte{
+			[]byte(`{"context":{"agentId":"agent1","traceToken":"t1"},"resources":[{"name":"h1","type":"host","status":"HOST_UNCHANGED"}]}`),
+			[]byte(`{"context":{"agentId":"agent2","traceToken":"t2"},"resources":[{"name":"h2","type":"host","status":"HOST_UNCHANGED"}]}`),
+			[]byte(`{"context":{"agentId":"agent1","traceToken":"t3"},"resources":[{"name":"h3","type":"host","status":"HOST_UNCHANGED"}]}`),
+		}
+		mbb.Build(&buf, 1024)
+
+		qq := make([]transit.ResourcesWithServicesRequest, 0, len(buf))
+		for _, p := range buf {
+			q := transit.ResourcesWithServicesRequest{}
+			assert.NoError(t, json.Unmarshal(p, &q))
+			qq = append(qq, q)
+		}
+		if assert.Len(t, qq, 2) {
+			assert.Equal(t, "agent1", qq[0].Context.AgentID)
+			assert.Len(t, qq[0].Resources, 2)
+			assert.Equal(t, "agent2", qq[1].Context.AgentID)
+			assert.Len(t, qq[1].Resources, 1)
+		}
+	})
 }
 
 func BenchmarkCatStrings(b *testing.B) {
@@ -0,0 +1,259 @@
+package batcher
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// walMaxAttempts limits handling of payloads failed to handle,
+// they are moved aside with failed suffix then and are not replayed anymore
+const walMaxAttempts = 3
+
+// SetWAL enables write-ahead file for buffered payloads.
+// Payloads left from previous run are replayed into the buffer.
+// On batching the file is synced and moved aside with sequence suffix and removed after handling,
+// so payloads may be sent twice after crash while handling.
+// The file is not synced on adding payloads, so the ones added after last batching
+// may be lost on host crash, the process crash does not lose them.
+// Can be called at runtime to move the buffer into another file
+// or to keep it in memory only with empty filePath
+func (bt *Batcher) SetWAL(filePath string) error {
+	bt.muBatch.Lock()
+	defer bt.muBatch.Unlock()
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	if filePath == bt.walPath {
+		return nil
+	}
+	/* the buffer is kept in memory, files of the previous WAL are removed once moved */
+	var prevPaths []string
+	if bt.wal != nil {
+		_ = bt.wal.Close()
+		prevPaths = append(bt.walRetryPaths, bt.walPath)
+		bt.wal, bt.walPath, bt.walRetryPaths = nil, "", nil
+	}
+	if filePath == "" {
+		removeWALFiles(prevPaths)
+		return nil
+	}
+
+	/* replay batches left unhandled, then the current file */
+	paths, attempts := walBatchPaths(filePath)
+	n := len(bt.buf)
+	for _, p := range append(paths, filePath) {
+		payloads, err := readWAL(p)
+		if err != nil {
+			return err
+		}
+		for _, p := range payloads {
+			bt.buf = append(bt.buf, p)
+			bt.bufSize += len(p)
+		}
+	}
+	if len(bt.buf) > n {
+		log.Info().Str("bt.tracerName", bt.tracerName).
+			Int("bufferLen", len(bt.buf)).
+			Msg("Batcher.SetWAL replayed buffer")
+	}
+
+	/* rewrite the buffer into the current file */
+	if err := writeWALFile(filePath, bt.buf); err != nil {
+		return err
+	}
+	removeWALFiles(paths)
+	removeWALFiles(prevPaths)
+
+	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	bt.wal, bt.walPath, bt.walSeq = file, filePath, 0
+	bt.walAttempts = max(bt.walAttempts, attempts)
+	return nil
+}
+
+// rotateWAL moves the current file aside and opens the new one,
+// returns path of moved file, should be called under lock
+func (bt *Batcher) rotateWAL() string {
+	if err := bt.wal.Sync(); err != nil {
+		log.Err(err).Str("bt.tracerName", bt.tracerName).
+			Msg("Batcher.Batch could not sync WAL")
+	}
+	_ = bt.wal.Close()
+	bt.wal = nil
+	bt.walSeq++
+	batchPath := fmt.Sprintf("%s.%d", bt.walPath, bt.walSeq)
+	if err := os.Rename(bt.walPath, batchPath); err != nil {
+		log.Err(err).Str("bt.tracerName", bt.tracerName).
+			Msg("Batcher.Batch could not move WAL")
+		batchPath = ""
+	}
+	file, err := os.OpenFile(bt.walPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Err(err).Str("bt.tracerName", bt.tracerName).
+			Msg("Batcher.Batch could not open WAL")
+		return batchPath
+	}
+	bt.wal = file
+	return batchPath
+}
+
+// keepWALBatch keeps payloads failed to handle and puts them back into the buffer
+// to retry with the next batch, then removes files of the handled batch,
+// failed payloads are written into the file with the number of attempts
+// as suffix after sequence to replay on start
+func (bt *Batcher) keepWALBatch(batchPath string, retryPaths []string, failed [][]byte, attempts int) {
+	defer func() {
+		removeWALFiles(append(retryPaths, batchPath))
+	}()
+	if len(failed) == 0 {
+		return
+	}
+	if batchPath == "" {
+		batchPath = fmt.Sprintf("%s.%d", bt.walPath, bt.walSeq)
+	}
+	if attempts >= walMaxAttempts {
+		keepPath := fmt.Sprintf("%s.failed-%d", bt.walPath, time.Now().UnixNano())
+		if err := writeWALFile(keepPath, failed); err != nil {
+			log.Err(err).Str("bt.tracerName", bt.tracerName).
+				Msg("Batcher.Batch could not keep WAL batch")
+		}
+		log.Error().Str("bt.tracerName", bt.tracerName).
+			Str("walBatchPath", keepPath).
+			Int("attempts", attempts).
+			Int("failedLen", len(failed)).
+			Msg("Batcher.Batch moved aside payloads failed too many times")
+		return
+	}
+
+	keepPath := fmt.Sprintf("%s.%d", batchPath, attempts)
+	if err := writeWALFile(keepPath, failed); err != nil {
+		log.Err(err).Str("bt.tracerName", bt.tracerName).
+			Msg("Batcher.Batch could not keep WAL batch")
+		keepPath = ""
+	}
+	bt.mu.Lock()
+	size := 0
+	for _, p := range failed {
+		size += len(p)
+	}
+	bt.buf = append(failed, bt.buf...)
+	bt.bufSize += size
+	bt.walAttempts = max(bt.walAttempts, attempts)
+	if keepPath != "" {
+		bt.walRetryPaths = append(bt.walRetryPaths, keepPath)
+	}
+	bt.mu.Unlock()
+	log.Warn().Str("bt.tracerName", bt.tracerName).
+		Str("walBatchPath", keepPath).
+		Int("attempts", attempts).
+		Int("failedLen", len(failed)).
+		Msg("Batcher.Batch keeps payloads failed to handle to retry with next batch")
+}
+
+// removeWALFiles removes handled files skipping empty paths
+func removeWALFiles(paths []string) {
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			log.Err(err).Str("walPath", p).
+				Msg("Batcher could not remove WAL file")
+		}
+	}
+}
+
+// walBatchPaths returns moved aside files ordered by sequence
+// and max number of handling attempts recorded in file names
+func walBatchPaths(filePath string) ([]string, int) {
+	matches, _ := filepath.Glob(filePath + ".*")
+	seqs := make(map[string]int, len(matches))
+	paths := make([]string, 0, len(matches))
+	maxAttempts := 0
+	for _, p := range matches {
+		seqStr, attemptsStr, hasAttempts := strings.Cut(strings.TrimPrefix(p, filePath+"."), ".")
+		seq, err := strconv.Atoi(seqStr)
+		if err != nil {
+			continue
+		}
+		if hasAttempts {
+			attempts, err := strconv.Atoi(attemptsStr)
+			if err != nil {
+				continue
+			}
+			maxAttempts = max(maxAttempts, attempts)
+		}
+		seqs[p] = seq
+		paths = append(paths, p)
+	}
+	slices.SortFunc(paths, func(a, b string) int { return seqs[a] - seqs[b] })
+	return paths, maxAttempts
+}
+
+// writeWALFile writes payloads into temp file, syncs it and renames
+func writeWALFile(filePath string, payloads [][]byte) error {
+	tmpPath := filePath + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	for _, p := range payloads {
+		if err := writeWALRecord(file, p); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filePath)
+}
+
+// writeWALRecord writes length-prefixed payload
+func writeWALRecord(file *os.File, p []byte) error {
+	rec := make([]byte, 4, 4+len(p))
+	binary.BigEndian.PutUint32(rec, uint32(len(p)))
+	_, err := file.Write(append(rec, p...))
+	return err
+}
+
+// readWAL reads length-prefixed payloads,
+// the incomplete tail record written on crash is skipped
+func readWAL(filePath string) ([][]byte, error) {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var payloads [][]byte
+	for len(data) >= 4 {
+		n := int(binary.BigEndian.Uint32(data))
+		if len(data) < 4+n {
+			break
+		}
+		payloads = append(payloads, data[4:4+n])
+		data = data[4+n:]
+	}
+	if len(data) > 0 {
+		log.Warn().Str("walPath", filePath).
+			Int("tailLen", len(data)).
+			Msg("Batcher.SetWAL skipped incomplete record")
+	}
+	return payloads, nil
+}
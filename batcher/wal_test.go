@@ -0,0 +1,128 @@
+package batcher
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type joinBuilder struct{}
+
+func (joinBuilder) Build(buf *[][]byte, _ int) {
+	*buf = [][]byte{bytes.Join(*buf, []byte(","))}
+}
+
+// passBuilder keeps payloads apart
+type passBuilder struct{}
+
+func (passBuilder) Build(*[][]byte, int) {}
+
+func TestWAL(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "test.wal")
+	var mu sync.Mutex
+	var sent []string
+	handler := func(_ context.Context, p []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if bytes.HasPrefix(p, []byte("x")) {
+			return os.ErrDeadlineExceeded
+		}
+		sent = append(sent, string(p))
+		return nil
+	}
+	failing := func(context.Context, []byte) error { return os.ErrDeadlineExceeded }
+
+	/* buffered payloads survive crash without batching */
+	bt := NewBatcher(joinBuilder{}, handler, time.Hour, 1024)
+	assert.NoError(t, bt.SetWAL(walPath))
+	bt.Add([]byte("a"))
+	bt.Add([]byte("b"))
+
+	/* incomplete record written on crash is skipped */
+	file, _ := os.OpenFile(walPath, os.O_APPEND|os.O_WRONLY, 0600)
+	_, _ = file.Write([]byte{0, 0, 0, 9, 'x'})
+	_ = file.Close()
+
+	bt = NewBatcher(joinBuilder{}, handler, time.Hour, 1024)
+	assert.NoError(t, bt.SetWAL(walPath))
+	bt.Add([]byte("c"))
+	bt.Batch()
+	assert.Equal(t, []string{"a,b,c"}, sent)
+	payloads, err := readWAL(walPath)
+	assert.NoError(t, err)
+	assert.Empty(t, payloads)
+	paths, _ := walBatchPaths(walPath)
+	assert.Empty(t, paths)
+
+	/* failed payloads are retried with the next batch */
+	bt.handler = failing
+	bt.Add([]byte("d"))
+	bt.Batch()
+	paths, attempts := walBatchPaths(walPath)
+	assert.Len(t, paths, 1)
+	assert.Equal(t, 1, attempts)
+	bt.handler = handler
+	bt.Add([]byte("e"))
+	bt.Batch()
+	assert.Equal(t, []string{"a,b,c", "d,e"}, sent)
+	paths, _ = walBatchPaths(walPath)
+	assert.Empty(t, paths)
+	bt.Exit()
+
+	/* only failed payloads are kept and replayed on start */
+	sent = nil
+	bt = NewBatcher(passBuilder{}, handler, time.Hour, 1024)
+	assert.NoError(t, bt.SetWAL(walPath))
+	bt.Add([]byte("x1"))
+	bt.Add([]byte("f"))
+	bt.Batch()
+	assert.Equal(t, []string{"f"}, sent)
+	paths, _ = walBatchPaths(walPath)
+	if assert.Len(t, paths, 1) {
+		payloads, err := readWAL(paths[0])
+		assert.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("x1")}, payloads)
+	}
+
+	bt = NewBatcher(passBuilder{}, failing, time.Hour, 1024)
+	assert.NoError(t, bt.SetWAL(walPath))
+	assert.Equal(t, [][]byte{[]byte("x1")}, bt.buf)
+	assert.Equal(t, 1, bt.walAttempts)
+
+	/* payloads failed too many times are moved aside */
+	for i := 2; i < walMaxAttempts; i++ {
+		bt.Batch()
+		_, attempts := walBatchPaths(walPath)
+		assert.Equal(t, i, attempts)
+	}
+	bt.Batch()
+	paths, _ = walBatchPaths(walPath)
+	assert.Empty(t, paths)
+	assert.Empty(t, bt.buf)
+	failed, _ := filepath.Glob(walPath + ".failed-*")
+	assert.Len(t, failed, 1)
+	bt.Exit()
+
+	/* the buffer is moved into another file at runtime */
+	sent = nil
+	bt = NewBatcher(joinBuilder{}, handler, time.Hour, 1024)
+	assert.NoError(t, bt.SetWAL(walPath))
+	bt.Add([]byte("g"))
+	walPath2 := filepath.Join(t.TempDir(), "test.wal")
+	assert.NoError(t, bt.SetWAL(walPath2))
+	assert.NoFileExists(t, walPath)
+	payloads, err = readWAL(walPath2)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("g")}, payloads)
+	assert.NoError(t, bt.SetWAL(""))
+	assert.NoFileExists(t, walPath2)
+	bt.Batch()
+	assert.Equal(t, []string{"g"}, sent)
+	bt.Exit()
+}
@@ -0,0 +1,126 @@
+// Package codec provides compressions for NATS and GroundWork payloads
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Names of codecs used in Compressed header and Content-Encoding
+const (
+	Gzip   = "gzip"
+	Snappy = "snappy"
+	Zstd   = "zstd"
+)
+
+// Codec defines compression
+type Codec interface {
+	Encode(w io.Writer, p []byte) error
+	Decode(p []byte) ([]byte, error)
+}
+
+var codecs = map[string]Codec{
+	Gzip:   gzipCodec{},
+	Snappy: snappyCodec{},
+	Zstd:   new(zstdCodec),
+}
+
+// Get returns codec by name
+func Get(name string) (Codec, error) {
+	if c, ok := codecs[name]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("unknown codec: %s", name)
+}
+
+// Encode compresses payload with codec by name
+func Encode(name string, p []byte) ([]byte, error) {
+	c, err := Get(name)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := c.Encode(&buf, p); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decompresses payload with codec by name
+func Decode(name string, p []byte) ([]byte, error) {
+	c, err := Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.Decode(p)
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Encode(w io.Writer, p []byte) error {
+	gw := gzip.NewWriter(w)
+	if _, err := gw.Write(p); err != nil {
+		_ = gw.Close()
+		return err
+	}
+	return gw.Close()
+}
+
+func (gzipCodec) Decode(p []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(p))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+type snappyCodec struct{}
+
+func (snappyCodec) Encode(w io.Writer, p []byte) error {
+	_, err := w.Write(snappy.Encode(nil, p))
+	return err
+}
+
+func (snappyCodec) Decode(p []byte) ([]byte, error) {
+	return snappy.Decode(nil, p)
+}
+
+// zstdCodec shares encoder and decoder which are safe for concurrent EncodeAll/DecodeAll
+type zstdCodec struct {
+	once sync.Once
+	enc  *zstd.Encoder
+	dec  *zstd.Decoder
+	err  error
+}
+
+func (c *zstdCodec) init() error {
+	c.once.Do(func() {
+		if c.enc, c.err = zstd.NewWriter(nil); c.err != nil {
+			return
+		}
+		c.dec, c.err = zstd.NewReader(nil)
+	})
+	return c.err
+}
+
+func (c *zstdCodec) Encode(w io.Writer, p []byte) error {
+	if err := c.init(); err != nil {
+		return err
+	}
+	_, err := w.Write(c.enc.EncodeAll(p, nil))
+	return err
+}
+
+func (c *zstdCodec) Decode(p []byte) ([]byte, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	return c.dec.DecodeAll(p, nil)
+}
@@ -0,0 +1,24 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodecs(t *testing.T) {
+	p := bytes.Repeat([]byte(`{"name":"host","type":"host","status":"HOST_UP"},`), 100)
+	for _, name := range []string{Gzip, Snappy, Zstd} {
+		t.Run(name, func(t *testing.T) {
+			enc, err := Encode(name, p)
+			assert.NoError(t, err)
+			assert.Less(t, len(enc), len(p))
+			dec, err := Decode(name, enc)
+			assert.NoError(t, err)
+			assert.Equal(t, p, dec)
+		})
+	}
+	_, err := Encode("lz4", p)
+	assert.Error(t, err)
+}
@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gwos/tcg/codec"
 	"github.com/gwos/tcg/logzer"
 	"github.com/gwos/tcg/nats"
 	"github.com/gwos/tcg/sdk/clients"
@@ -96,9 +97,49 @@ type Nats struct {
 	NatsStoreMaxBytes int64 `env:"NATSSTOREMAXBYTES" yaml:"natsStoreMaxBytes"`
 	// How many messages are allowed per-channel
 	NatsStoreMaxMsgs int64 `env:"NATSSTOREMAXMSGS" yaml:"natsStoreMaxMsgs"`
+	// How many messages are kept in the dead-letter stream
+	// messages land there when GroundWork rejects them permanently
+	NatsDLQMaxMsgs int64 `env:"NATSDLQMAXMSGS" yaml:"natsDLQMaxMsgs"`
 	// NatsServerConfigFile is used to override yaml values for
 	// NATS server configuration (debug only).
 	NatsServerConfigFile string `env:"NATSSERVERCONFIGFILE" yaml:"natsServerConfigFile"`
+	// NatsURLs accepts URLs of external NATS JetStream servers,
+	// if set TCG connects to them instead of starting the embedded server
+	NatsURLs []string `env:"NATSURLS" yaml:"natsURLs,omitempty"`
+	// Credentials of external NATS: creds file with JWT and NKEY seed,
+	// NKEY seed file, user with password, or token.
+	// NatsPassword and NatsToken may be secret references
+	NatsCredsFile string `env:"NATSCREDSFILE" yaml:"natsCredsFile,omitempty"`
+	NatsNKeyFile  string `env:"NATSNKEYFILE" yaml:"natsNKeyFile,omitempty"`
+	NatsUser      string `env:"NATSUSER" yaml:"natsUser,omitempty"`
+	NatsPassword  string `env:"NATSPASSWORD" yaml:"natsPassword,omitempty" secret:"true"`
+	NatsToken     string `env:"NATSTOKEN" yaml:"natsToken,omitempty" secret:"true"`
+	// TLS of external NATS: CA file to verify servers,
+	// client certificate and key for mutual TLS
+	NatsTLSCAFile   string `env:"NATSTLSCAFILE" yaml:"natsTLSCAFile,omitempty"`
+	NatsTLSCertFile string `env:"NATSTLSCERTFILE" yaml:"natsTLSCertFile,omitempty"`
+	NatsTLSKeyFile  string `env:"NATSTLSKEYFILE" yaml:"natsTLSKeyFile,omitempty"`
+	// NatsStreamName and NatsSubjectPrefix separate agents sharing external NATS,
+	// both are required with NatsURLs, the dead-letter stream is named with "-dlq" suffix
+	NatsStreamName    string `env:"NATSSTREAMNAME" yaml:"natsStreamName,omitempty"`
+	NatsSubjectPrefix string `env:"NATSSUBJECTPREFIX" yaml:"natsSubjectPrefix,omitempty"`
+	// NatsReplicas defines replication factor of streams in NATS cluster
+	NatsReplicas int `env:"NATSREPLICAS" yaml:"natsReplicas,omitempty"`
+	// NatsStreams splits subjects into separate streams with own retention,
+	// if empty all subjects are kept in the single stream
+	NatsStreams []NatsStream `yaml:"natsStreams,omitempty"`
+}
+
+// NatsStream defines stream named with suffix Name for subjects like "tcg.events",
+// zero limits fall back to NatsStore* limits, MaxMsgsPerSubject 1 keeps the latest message only.
+// The oldest messages are discarded on reaching limits
+type NatsStream struct {
+	Name              string        `yaml:"name"`
+	Subjects          []string      `yaml:"subjects"`
+	MaxAge            time.Duration `yaml:"maxAge,omitempty"`
+	MaxBytes          int64         `yaml:"maxBytes,omitempty"`
+	MaxMsgs           int64         `yaml:"maxMsgs,omitempty"`
+	MaxMsgsPerSubject int64         `yaml:"maxMsgsPerSubject,omitempty"`
 }
 
 // Hashsum calculates FNV non-cryptographic hash suitable for checking the equality
@@ -114,15 +155,40 @@ type Connector struct {
 	BatchEvents   time.Duration `env:"BATCHEVENTS" yaml:"batchEvents"`
 	BatchMetrics  time.Duration `env:"BATCHMETRICS" yaml:"batchMetrics"`
 	BatchMaxBytes int           `env:"BATCHMAXBYTES" yaml:"batchMaxBytes"`
+	// BatchInventory accepts debounce window for merging inventory payloads,
+	// inventory is sent after the window without new payloads, if 0 turn off batching
+	BatchInventory time.Duration `env:"BATCHINVENTORY" yaml:"batchInventory"`
+	// BatchWALDir accepts directory for write-ahead files of batch buffers,
+	// buffered payloads are replayed from there on start, if empty keep buffers in memory only
+	BatchWALDir string `env:"BATCHWALDIR" yaml:"batchWALDir"`
+	// NatsCompression defines compression of NATS payloads per subject,
+	// payloads exceeding NatsMaxPayload are compressed with gzip anyway
+	NatsCompression []CompressionRule `yaml:"natsCompression,omitempty"`
 
 	// ControllerAddr accepts value for combined "host:port"
 	// used as `http.Server{Addr}`
 	ControllerAddr     string `env:"CONTROLLERADDR" yaml:"controllerAddr"`
 	ControllerCertFile string `env:"CONTROLLERCERTFILE" yaml:"controllerCertFile"`
 	ControllerKeyFile  string `env:"CONTROLLERKEYFILE" yaml:"controllerKeyFile"`
+	// ControllerClientCAFile turns on verifying client certificates with CA bundle on TLS,
+	// the verified certificates are allowed by ControllerClientCerts rules,
+	// requests without certificate or not matched by rules are checked with other methods
+	ControllerClientCAFile string           `env:"CONTROLLERCLIENTCAFILE" yaml:"controllerClientCAFile,omitempty"`
+	ControllerClientCerts  []ClientCertRule `yaml:"controllerClientCerts,omitempty"`
+	// ControllerTokens defines local API tokens accepted in "Authorization: Bearer" header
+	ControllerTokens []APIToken `yaml:"controllerTokens,omitempty"`
+	// ControllerRateLimit limits requests per second per client IP before authentication
+	// and per local API token after it on private API,
+	// ControllerRateBurst allows bursts over the limit, if 0 turn off limiting
+	ControllerRateLimit float64 `env:"CONTROLLERRATELIMIT" yaml:"controllerRateLimit,omitempty"`
+	ControllerRateBurst int     `env:"CONTROLLERRATEBURST" yaml:"controllerRateBurst,omitempty"`
+	// ControllerMaxConcurrent limits requests processed at once on private API, if 0 no limit
+	ControllerMaxConcurrent int `env:"CONTROLLERMAXCONCURRENT" yaml:"controllerMaxConcurrent,omitempty"`
+	// ControllerMaxBodyBytes limits request body size on private API, if 0 no limit
+	ControllerMaxBodyBytes int64 `env:"CONTROLLERMAXBODYBYTES" yaml:"controllerMaxBodyBytes,omitempty"`
 	// ControllerPin accepts value from environment
 	// provides local access for debug
-	ControllerPin string `env:"CONTROLLERPIN" yaml:"-"`
+	ControllerPin string `env:"CONTROLLERPIN" yaml:"-" secret:"true"`
 	// Custom HTTP configuration
 	ControllerReadTimeout  time.Duration `env:"CONTROLLERREADTIMEOUT" yaml:"-"`
 	ControllerWriteTimeout time.Duration `env:"CONTROLLERWRITETIMEOUT" yaml:"-"`
@@ -148,16 +214,98 @@ type Connector struct {
 	LogLevel      LogLevel `env:"LOGLEVEL" yaml:"logLevel"`
 	LogColors     bool     `env:"LOGCOLORS" yaml:"logColors"`
 	LogTimeFormat string   `env:"LOGTIMEFORMAT" yaml:"logTimeFormat"`
+	// AuditLogFile accepts file path to record mutating controller operations,
+	// it is rotated with LogFileMaxSize and LogFileRotate, if empty turn off auditing
+	AuditLogFile string `env:"AUDITLOGFILE" yaml:"auditLogFile"`
 
 	Nats `yaml:",inline"`
 
-	RetryDelays []time.Duration `env:"RETRYDELAYS" yaml:"-"`
+	RetryDelays []time.Duration `env:"RETRYDELAYS" yaml:"retryDelays"`
+
+	// ConfigWatchInterval defines how often the config file is checked for changes
+	// to apply them at runtime, if 0 turn off watching
+	ConfigWatchInterval time.Duration `env:"CONFIGWATCHINTERVAL" yaml:"configWatchInterval"`
+
+	// DeltaStates turns on sending only changed states of services,
+	// all states are sent anyway every DeltaStatesRefresh cycles,
+	// connectors in multi-connector mode override them with
+	// deltaStates and deltaStatesRefresh MonitorConnection extensions
+	DeltaStates        bool `env:"DELTASTATES" yaml:"deltaStates"`
+	DeltaStatesRefresh int  `env:"DELTASTATESREFRESH" yaml:"deltaStatesRefresh"`
+
+	// DrainTimeout limits waiting for in-flight deliveries on exit
+	// if 0 turn off waiting, buffered batches are flushed anyway
+	DrainTimeout time.Duration `env:"DRAINTIMEOUT" yaml:"drainTimeout"`
+
+	// ReadyMaxAuthAge limits the age of the last GroundWork authentication or delivery
+	// reported in readiness details, ReadyMaxBacklogAge limits the age of the oldest
+	// not delivered message for readiness, if 0 turn off the check
+	ReadyMaxAuthAge    time.Duration `env:"READYMAXAUTHAGE" yaml:"readyMaxAuthAge"`
+	ReadyMaxBacklogAge time.Duration `env:"READYMAXBACKLOGAGE" yaml:"readyMaxBacklogAge"`
+
+	// Webhooks defines outbound notifications on agent events
+	Webhooks []Webhook `yaml:"webhooks,omitempty"`
+	// WebhookCheckInterval defines how often NATS store and backlog are checked for webhooks,
+	// if 0 turn off the checks. Backlog is checked with ReadyMaxBacklogAge
+	WebhookCheckInterval time.Duration `env:"WEBHOOKCHECKINTERVAL" yaml:"webhookCheckInterval"`
+	// WebhookStoreThreshold defines usage of NATS store limits in percent to notify about
+	WebhookStoreThreshold float64 `env:"WEBHOOKSTORETHRESHOLD" yaml:"webhookStoreThreshold"`
 
 	TransportStartRndDelay int `env:"TRANSPORTSTARTRNDDELAY" yaml:"-"`
 
 	ExportProm bool `env:"EXPORTPROM" yaml:"-"`
 
 	ExportTransitDir string `env:"EXPORTTRANSITDIR" yaml:"-"`
+
+	// Connectors lists connectors run in one process in multi-connector mode,
+	// they share NATS, transport and controller but have own identity and config delivery
+	Connectors []string `env:"CONNECTORS" yaml:"connectors,omitempty"`
+	// Agents keeps identities of connectors in multi-connector mode
+	Agents map[string]AgentConfig `yaml:"agents,omitempty"`
+}
+
+// AgentConfig defines connector identity in multi-connector mode
+type AgentConfig struct {
+	transit.AgentIdentity `yaml:",inline"`
+
+	Enabled bool `json:"enabled" yaml:"enabled"`
+}
+
+// CompressionRule defines codec "gzip", "snappy" or "zstd" for payloads
+// of subjects matched with path.Match patterns like "tcg.*",
+// payloads smaller than MinBytes are not compressed
+type CompressionRule struct {
+	Subjects []string `yaml:"subjects"`
+	Codec    string   `yaml:"codec"`
+	MinBytes int      `yaml:"minBytes,omitempty"`
+}
+
+// ClientCertRule maps client certificates to permissions.
+// Certificate matches if its subject or common name matches any of Subjects,
+// or any of its DNS, email or URI SANs matches any of SANs.
+// Permissions are API paths relative to /api/v1, like "/events" or "/apm/*",
+// "*" allows all. Values support path.Match patterns
+type ClientCertRule struct {
+	Subjects    []string `yaml:"subjects,omitempty"`
+	SANs        []string `yaml:"sans,omitempty"`
+	Permissions []string `yaml:"permissions"`
+}
+
+// APIToken defines local API token with scopes like "ingest:metrics" or "admin",
+// the token is kept as hex-encoded sha256 hash, see "tcg hash-token"
+type APIToken struct {
+	Name   string   `yaml:"name"`
+	Hash   string   `yaml:"hash"`
+	Scopes []string `yaml:"scopes"`
+}
+
+// Webhook defines endpoint notified with JSON POST on agent events
+// like "transportStopped" or "gwUnauthorized", empty Events means all.
+// Header values support secret references
+type Webhook struct {
+	URL     string            `yaml:"url"`
+	Events  []string          `yaml:"events,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty" secret:"true"`
 }
 
 // ConnectorDTO defines TCG Connector configuration
@@ -187,21 +335,29 @@ func (c *DSConnection) AsClient() clients.DSConnection {
 type GWConnection clients.GWConnection
 
 // AsClient returns as clients type
+// resolves the password secret reference
 func (c *GWConnection) AsClient() clients.GWConnection {
-	return (clients.GWConnection)(*c)
+	cc := (clients.GWConnection)(*c)
+	if password, err := ResolveSecret(c.Password); err != nil {
+		log.Err(err).Str("hostName", c.HostName).Msg("could not resolve password")
+		cc.Password = ""
+	} else {
+		cc.Password = password
+	}
+	return cc
 }
 
 // MarshalYAML implements yaml.Marshaler interface
-// overrides the password field
+// overrides the password field, keeps secret reference as is
 func (c GWConnection) MarshalYAML() (any, error) {
 	type plain GWConnection
 	p := plain(c)
-	if s := os.Getenv(SecKeyEnv); s != "" {
-		encrypted, err := Encrypt([]byte(p.Password), []byte(s))
+	if os.Getenv(SecKeyEnv) != "" && !IsSecretRef(p.Password) {
+		encrypted, err := EncryptSecret(p.Password)
 		if err != nil {
 			return nil, err
 		}
-		p.Password = fmt.Sprintf("%s%x", SecVerPrefix, encrypted)
+		p.Password = encrypted
 	}
 	return p, nil
 }
@@ -213,20 +369,12 @@ func (c *GWConnection) UnmarshalYAML(unmarshal func(any) error) error {
 	if err := unmarshal((*plain)(c)); err != nil {
 		return err
 	}
-	if strings.HasPrefix(c.Password, SecVerPrefix) {
-		s := os.Getenv(SecKeyEnv)
-		if s == "" {
-			return fmt.Errorf("unmarshaler error: %s SecKeyEnv is empty", SecVerPrefix)
-		}
-		var encrypted []byte
-		if _, err := fmt.Sscanf(c.Password, SecVerPrefix+"%x", &encrypted); err != nil {
-			return err
-		}
-		decrypted, err := Decrypt(encrypted, []byte(s))
+	if IsEncrypted(c.Password) {
+		decrypted, err := DecryptSecret(c.Password)
 		if err != nil {
-			return err
+			return fmt.Errorf("unmarshaler error: %w", err)
 		}
-		c.Password = string(decrypted)
+		c.Password = decrypted
 	}
 	return nil
 }
@@ -263,6 +411,7 @@ func defaults() Config {
 		Connector: Connector{
 			BatchEvents:            0,
 			BatchMetrics:           0,
+			BatchInventory:         0,
 			BatchMaxBytes:          1024 * 1024, // 1MB
 			ControllerAddr:         ":8099",
 			ControllerReadTimeout:  time.Second * 10,
@@ -286,11 +435,20 @@ func defaults() Config {
 				NatsStoreMaxAge:        time.Hour * 24 * 10,     // 10days
 				NatsStoreMaxBytes:      1024 * 1024 * 1024 * 20, // 20GB
 				NatsStoreMaxMsgs:       1_000_000,               // 1 000 000
+				NatsDLQMaxMsgs:         10_000,                  // 10 000
 				NatsServerConfigFile:   "",
 			},
 			RetryDelays: []time.Duration{time.Second * 30, time.Second * 30, time.Second * 30, time.Second * 30, time.Second * 30,
 				time.Second * 30, time.Second * 30, time.Second * 30, time.Minute * 1, time.Minute * 5, time.Minute * 20},
+			ConfigWatchInterval:    time.Second * 10,
 			TransportStartRndDelay: 60,
+			DeltaStates:            false,
+			DeltaStatesRefresh:     10,
+			DrainTimeout:           time.Second * 20,
+			ReadyMaxAuthAge:        time.Hour,
+			ReadyMaxBacklogAge:     time.Minute * 10,
+			WebhookCheckInterval:   time.Minute,
+			WebhookStoreThreshold:  90,
 		},
 		// create disabled connections to support partial setting with struct-path
 		// 4 items should be enough
@@ -341,18 +499,7 @@ func GetConfig() *Config {
 		logSuppress(Suppress.Inventory, "Inventory")
 		logSuppress(Suppress.Metrics, "Metrics")
 
-		/* process PMC */
-		if cfg.IsPMC() {
-			cfg.Connector.InstallationMode = InstallationModePMC
-			cfg.DSConnection.HostName = os.Getenv(ParentInstanceNameEnv)
-		}
-		/* prepare gwConnections */
-		gwEncode := strings.ToLower(cfg.Connector.GWEncode)
-		for i := range cfg.GWConnections {
-			cfg.GWConnections[i].IsDynamicInventory = cfg.Connector.IsDynamicInventory
-			cfg.GWConnections[i].HTTPEncode = gwEncode == "force" ||
-				(gwEncode != "off" && cfg.GWConnections[i].IsChild)
-		}
+		cfg.prepare()
 		/* init logger and flush buffer */
 		cfg.initLogger()
 		logzer.WriteLogBuffer(logBuf)
@@ -374,17 +521,46 @@ func (cfg Config) ConfigPath() string {
 	return configPath
 }
 
-func (cfg *Config) loadConnector(data []byte) (*ConnectorDTO, error) {
+func (cfg *Config) loadConnector(name string, data []byte) (*ConnectorDTO, error) {
 	var dto ConnectorDTO
 	if err := json.Unmarshal(data, &dto); err != nil {
 		log.Err(err).Msg("could not parse connector")
 		return nil, err
 	}
-	cfg.Connector.AgentID = dto.AgentID
-	cfg.Connector.AppName = dto.AppName
-	cfg.Connector.AppType = dto.AppType
 	cfg.Connector.LogLevel = dto.LogLevel
-	cfg.Connector.Enabled = dto.Enabled
+	if name == "" {
+		cfg.Connector.AgentID = dto.AgentID
+		cfg.Connector.AppName = dto.AppName
+		cfg.Connector.AppType = dto.AppType
+		cfg.Connector.Enabled = dto.Enabled
+	} else {
+		/* keep identity of connector apart, transport is enabled if any connector is enabled */
+		agents := make(map[string]AgentConfig, len(cfg.Connector.Agents)+1)
+		for k, v := range cfg.Connector.Agents {
+			agents[k] = v
+		}
+		agents[name] = AgentConfig{
+			AgentIdentity: transit.AgentIdentity{AgentID: dto.AgentID, AppName: dto.AppName, AppType: dto.AppType},
+			Enabled:       dto.Enabled,
+		}
+		cfg.Connector.Agents = agents
+		cfg.Connector.Enabled = false
+		for _, a := range agents {
+			cfg.Connector.Enabled = cfg.Connector.Enabled || a.Enabled
+		}
+	}
+	/* keep routing rules from config file if not provided with connector */
+	for i := range dto.GWConnections {
+		if !dto.GWConnections[i].Routing.IsEmpty() {
+			continue
+		}
+		for _, c := range cfg.GWConnections {
+			if c.HostName == dto.GWConnections[i].HostName {
+				dto.GWConnections[i].Routing = c.Routing
+				break
+			}
+		}
+	}
 	cfg.GWConnections = dto.GWConnections
 	if len(dto.DSConnection.HostName) != 0 {
 		cfg.DSConnection.HostName = dto.DSConnection.HostName
@@ -433,11 +609,17 @@ func (cfg *Config) loadDynamicInventoryFlag(_ []byte) error {
 		return err
 	} */
 
-	switch cfg.Connector.AppType {
-	case "CHECKER", "APM", "EVENTS", "TCGAZURE", "ORACLE":
-		cfg.Connector.IsDynamicInventory = true
-	default:
-		cfg.Connector.IsDynamicInventory = false
+	isDynamic := func(appType string) bool {
+		switch appType {
+		case "CHECKER", "APM", "EVENTS", "TCGAZURE", "ORACLE":
+			return true
+		}
+		return false
+	}
+	cfg.Connector.IsDynamicInventory = isDynamic(cfg.Connector.AppType)
+	/* connections are shared in multi-connector mode */
+	for _, a := range cfg.Connector.Agents {
+		cfg.Connector.IsDynamicInventory = cfg.Connector.IsDynamicInventory || isDynamic(a.AppType)
 	}
 
 	return nil
@@ -445,6 +627,16 @@ func (cfg *Config) loadDynamicInventoryFlag(_ []byte) error {
 
 // LoadConnectorDTO loads ConnectorDTO into Config
 func (cfg *Config) LoadConnectorDTO(data []byte) (*ConnectorDTO, error) {
+	return cfg.loadConnectorDTO("", data)
+}
+
+// LoadAgentDTO loads ConnectorDTO of named connector in multi-connector mode,
+// the identity is kept in Agents, connections are shared by connectors
+func (cfg *Config) LoadAgentDTO(name string, data []byte) (*ConnectorDTO, error) {
+	return cfg.loadConnectorDTO(name, data)
+}
+
+func (cfg *Config) loadConnectorDTO(name string, data []byte) (*ConnectorDTO, error) {
 	newCfg := new(Config)
 	*newCfg = defaults()
 	/* load config file */
@@ -461,7 +653,7 @@ func (cfg *Config) LoadConnectorDTO(data []byte) (*ConnectorDTO, error) {
 		}
 	}
 	/* load as ConnectorDTO */
-	dto, err := newCfg.loadConnector(data)
+	dto, err := newCfg.loadConnector(name, data)
 	if err != nil {
 		return nil, err
 	}
@@ -493,19 +685,38 @@ func (cfg *Config) LoadConnectorDTO(data []byte) (*ConnectorDTO, error) {
 		}
 	}
 
+	newCfg.prepare()
+	cfg.apply(newCfg)
+	return dto, nil
+}
+
+// prepare processes PMC, gwConnections and natsCompression
+func (cfg *Config) prepare() {
 	/* process PMC */
 	if cfg.IsPMC() {
-		newCfg.Connector.InstallationMode = InstallationModePMC
-		newCfg.DSConnection.HostName = os.Getenv(ParentInstanceNameEnv)
+		cfg.Connector.InstallationMode = InstallationModePMC
+		cfg.DSConnection.HostName = os.Getenv(ParentInstanceNameEnv)
 	}
 	/* prepare gwConnections */
-	gwEncode := strings.ToLower(newCfg.Connector.GWEncode)
-	for i := range newCfg.GWConnections {
-		newCfg.GWConnections[i].IsDynamicInventory = newCfg.Connector.IsDynamicInventory
-		newCfg.GWConnections[i].HTTPEncode = gwEncode == "force" ||
-			(gwEncode != "off" && newCfg.GWConnections[i].IsChild)
+	gwEncode := strings.ToLower(cfg.Connector.GWEncode)
+	for i := range cfg.GWConnections {
+		cfg.GWConnections[i].IsDynamicInventory = cfg.Connector.IsDynamicInventory
+		cfg.GWConnections[i].HTTPEncode = gwEncode == "force" ||
+			(gwEncode != "off" && cfg.GWConnections[i].IsChild)
+	}
+	/* unknown codec fails publishing, so fall back to gzip */
+	for i, r := range cfg.Connector.NatsCompression {
+		if _, err := codec.Get(r.Codec); err != nil {
+			log.Warn().Err(err).
+				Int("rule", i).
+				Msg("natsCompression rule falls back to gzip")
+			cfg.Connector.NatsCompression[i].Codec = codec.Gzip
+		}
 	}
-	/* update config */
+}
+
+// apply updates config with new values and deps
+func (cfg *Config) apply(newCfg *Config) {
 	cfg.Connector = newCfg.Connector
 	cfg.DSConnection = newCfg.DSConnection
 	cfg.GWConnections = newCfg.GWConnections
@@ -515,8 +726,11 @@ func (cfg *Config) LoadConnectorDTO(data []byte) (*ConnectorDTO, error) {
 
 	/* update other deps */
 	nats.RetryDelays = cfg.Connector.RetryDelays
+}
 
-	return dto, nil
+// IsMulti checks multi-connector mode
+func (cfg *Config) IsMulti() bool {
+	return len(cfg.Connector.Connectors) > 0
 }
 
 // IsPMC checks configuration
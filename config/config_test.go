@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gwos/tcg/codec"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v3"
 )
@@ -227,3 +228,215 @@ gwConnections:
 	assert.NotContains(t, string(res), "password: _v1_fc0546f02")
 	// t.Logf("$$\n%v", string(data))
 }
+
+func TestReload(t *testing.T) {
+	once = sync.Once{}
+	configYAML := []byte(`
+connector:
+  appType: test
+  batchEvents: 1s
+  logLevel: 1
+gwConnections:
+  - hostName: localhost:80
+    password: SEC RET
+`)
+
+	tmpFile, err := os.CreateTemp("", "config")
+	assert.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(configYAML)
+	assert.NoError(t, err)
+	assert.NoError(t, tmpFile.Close())
+
+	t.Setenv(ConfigEnv, tmpFile.Name())
+	t.Setenv("TCG_CONNECTOR_CONTROLLERADDR", ":8022")
+
+	cfg := GetConfig()
+	changes, err := cfg.Reload()
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	assert.NoError(t, os.WriteFile(tmpFile.Name(), []byte(`
+connector:
+  appType: test
+  batchEvents: 2s
+  logLevel: 3
+  natsToken: NATS TOKEN
+  retryDelays: [1s, 1m]
+  webhooks:
+    - url: http://localhost/hook
+      headers:
+        Authorization: Bearer SEC RET
+gwConnections:
+  - hostName: localhost:80
+    password: NEW SEC RET
+  - hostName: localhost:81
+`), 0644))
+	changes, err = cfg.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Field: "Connector.BatchEvents", Old: "1s", New: "2s"},
+		{Field: "Connector.LogLevel", Old: "Warn", New: "Debug"},
+		{Field: "Connector.NatsToken", Old: "***", New: "***"},
+		{Field: "Connector.RetryDelays", Old: "[30s 30s 30s 30s 30s 30s 30s 30s 1m0s 5m0s 20m0s]", New: "[1s 1m0s]"},
+		{Field: "Connector.Webhooks[0].URL", Old: "", New: "http://localhost/hook"},
+		{Field: "Connector.Webhooks[0].Headers", Old: "map[]", New: "map[Authorization:***]"},
+		{Field: "GWConnections[0].Password", Old: "***", New: "***"},
+		{Field: "GWConnections[1].HostName", Old: "", New: "localhost:81"},
+	}, changes)
+	assert.Equal(t, 2*time.Second, cfg.Connector.BatchEvents)
+	assert.Equal(t, ":8022", cfg.Connector.ControllerAddr)
+	assert.Equal(t, "NEW SEC RET", cfg.GWConnections[0].Password)
+
+	/* broken file keeps config untouched */
+	assert.NoError(t, os.WriteFile(tmpFile.Name(), []byte(`connector: [`), 0644))
+	_, err = cfg.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, 2*time.Second, cfg.Connector.BatchEvents)
+}
+
+func TestValidate(t *testing.T) {
+	configYAML := []byte(`
+connector:
+  appType: test
+  batchEvents: 1x
+  controllerCertFile: /not/existing/cert.pem
+  natsToken: NATS TOKEN
+  natsStreams:
+    - name: events
+      subjects: []
+  natsCompression:
+    - subjects: [tcg.metrics]
+      codec: lz4
+  webhooks:
+    - url: http://localhost/hook
+      headers:
+        Authorization: Bearer HEADER TOKEN
+  unknownField: 1
+gwConnections:
+  - hostName: localhost:80
+    password: SEC RET
+    unknownField: 1
+`)
+
+	tmpFile, err := os.CreateTemp("", "config")
+	assert.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(configYAML)
+	assert.NoError(t, err)
+	assert.NoError(t, tmpFile.Close())
+
+	t.Setenv("TCG_CONNECTOR_APPNAME", "test-app")
+
+	cfg, errs := Validate(tmpFile.Name())
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	assert.Len(t, messages, 6)
+	assert.Contains(t, messages, "unknown key: connector.unknownField (line 17)")
+	assert.Contains(t, messages, "unknown key: gwConnections[0].unknownField (line 21)")
+	assert.Contains(t, messages, "natsStreams[0].subjects: empty")
+	assert.Contains(t, messages, "natsCompression[0].codec: unknown codec: lz4")
+	assert.Contains(t, messages, "bad value: line 4: cannot unmarshal !!str `1x` into time.Duration")
+	assert.Contains(t, messages, "unreachable path: connector.controllerCertFile: stat /not/existing/cert.pem: no such file or directory")
+	assert.Equal(t, "test-app", cfg.Connector.AppName)
+	assert.Equal(t, codec.Gzip, cfg.Connector.NatsCompression[0].Codec)
+
+	output, err := cfg.RedactedYAML()
+	assert.NoError(t, err)
+	assert.Contains(t, string(output), "password: '***'")
+	assert.NotContains(t, string(output), "SEC RET")
+	assert.NotContains(t, string(output), "NATS TOKEN")
+	assert.Contains(t, string(output), "Authorization: '***'")
+	assert.NotContains(t, string(output), "HEADER TOKEN")
+}
+
+func TestSecretRefs(t *testing.T) {
+	secretFile, err := os.CreateTemp("", "secret")
+	assert.NoError(t, err)
+	defer os.Remove(secretFile.Name())
+	_, err = secretFile.WriteString("FILE SECRET\n")
+	assert.NoError(t, err)
+	assert.NoError(t, secretFile.Close())
+
+	configYAML := []byte(`
+gwConnections:
+  - hostName: localhost:80
+    password: file://` + secretFile.Name() + `
+  - hostName: localhost:81
+    password: env://TEST_GW_PASSWORD
+`)
+
+	t.Setenv(SecKeyEnv, "SECRET")
+	t.Setenv("TEST_GW_PASSWORD", "ENV SECRET")
+
+	var cfg Config
+	assert.NoError(t, yaml.Unmarshal(configYAML, &cfg))
+	assert.Equal(t, "FILE SECRET", cfg.GWConnections[0].AsClient().Password)
+	assert.Equal(t, "ENV SECRET", cfg.GWConnections[1].AsClient().Password)
+	assert.Equal(t, []string{secretFile.Name()}, cfg.SecretFiles())
+
+	res, err := yaml.Marshal(cfg)
+	assert.NoError(t, err)
+	assert.Contains(t, string(res), "password: file://"+secretFile.Name())
+	assert.Contains(t, string(res), "password: env://TEST_GW_PASSWORD")
+
+	/* rotated secret is read on next use */
+	assert.NoError(t, os.WriteFile(secretFile.Name(), []byte("ROTATED"), 0644))
+	assert.Equal(t, "ROTATED", cfg.GWConnections[0].AsClient().Password)
+
+	_, err = ResolveSecret("env://TEST_GW_PASSWORD_MISSING")
+	assert.Error(t, err)
+}
+
+func TestRotateKeys(t *testing.T) {
+	t.Setenv(SecKeyEnv, "OLD SECRET")
+	encrypted, err := EncryptSecret("P@SSW0RD")
+	assert.NoError(t, err)
+
+	tmpDir := t.TempDir()
+	tmpFile, err := os.CreateTemp(tmpDir, "config")
+	assert.NoError(t, err)
+	_, err = tmpFile.WriteString(`
+gwConnections:
+  - hostName: localhost:80
+    password: ` + encrypted + `
+  - hostName: localhost:81
+    password: PLAIN
+  - hostName: localhost:82
+    password: env://TEST_GW_PASSWORD
+`)
+	assert.NoError(t, err)
+	assert.NoError(t, tmpFile.Close())
+
+	t.Setenv(SecKeyEnv, "NEW SECRET")
+	_, err = DecryptSecret(encrypted)
+	assert.Error(t, err)
+
+	t.Setenv(SecKeysOldEnv, "OTHER SECRET,OLD SECRET")
+	decrypted, err := DecryptSecret(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "P@SSW0RD", decrypted)
+
+	count, err := RotateKeys(tmpFile.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	/* the file is replaced by renaming temp one with the same permissions */
+	entries, err := os.ReadDir(tmpDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	fi, err := os.Stat(tmpFile.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	/* rotated values are decrypted with the new secret only */
+	t.Setenv(SecKeysOldEnv, "")
+	data, err := os.ReadFile(tmpFile.Name())
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "password: env://TEST_GW_PASSWORD")
+	var cfg Config
+	assert.NoError(t, yaml.Unmarshal(data, &cfg))
+	assert.Equal(t, "P@SSW0RD", cfg.GWConnections[0].Password)
+	assert.Equal(t, "PLAIN", cfg.GWConnections[1].Password)
+}
@@ -5,6 +5,8 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"os"
+	"strings"
 
 	"golang.org/x/crypto/nacl/secretbox"
 )
@@ -14,6 +16,9 @@ import (
 func Decrypt(message, secret []byte) ([]byte, error) {
 	var nonce [24]byte
 	var secretKey = sha256.Sum256(secret)
+	if len(message) < len(nonce) {
+		return nil, fmt.Errorf("decryption error")
+	}
 	copy(nonce[:], message[:24])
 	decrypted, ok := secretbox.Open(nil, message[24:], &nonce, &secretKey)
 	if !ok {
@@ -32,3 +37,59 @@ func Encrypt(message, secret []byte) ([]byte, error) {
 	}
 	return secretbox.Seal(nonce[:], message, &nonce, &secretKey), nil
 }
+
+// IsEncrypted checks if value is encrypted with SecVerPrefix
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, SecVerPrefix)
+}
+
+// EncryptSecret encrypts value with the secret from SecKeyEnv
+// and returns it in form of SecVerPrefix and hex
+func EncryptSecret(s string) (string, error) {
+	secret := os.Getenv(SecKeyEnv)
+	if secret == "" {
+		return "", fmt.Errorf("encryption error: %s is empty", SecKeyEnv)
+	}
+	encrypted, err := Encrypt([]byte(s), []byte(secret))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%x", SecVerPrefix, encrypted), nil
+}
+
+// DecryptSecret decrypts value in form of SecVerPrefix and hex
+// trying the secret from SecKeyEnv and then previous ones from SecKeysOldEnv,
+// returns non-encrypted values as is
+func DecryptSecret(s string) (string, error) {
+	if !IsEncrypted(s) {
+		return s, nil
+	}
+	secrets := secKeys()
+	if len(secrets) == 0 {
+		return "", fmt.Errorf("decryption error: %s is empty", SecKeyEnv)
+	}
+	var encrypted []byte
+	if _, err := fmt.Sscanf(s, SecVerPrefix+"%x", &encrypted); err != nil {
+		return "", err
+	}
+	for _, secret := range secrets {
+		if decrypted, err := Decrypt(encrypted, []byte(secret)); err == nil {
+			return string(decrypted), nil
+		}
+	}
+	return "", fmt.Errorf("decryption error: no matching key in %s, %s", SecKeyEnv, SecKeysOldEnv)
+}
+
+// secKeys returns the current secret followed by previous ones
+func secKeys() []string {
+	var secrets []string
+	if s := os.Getenv(SecKeyEnv); s != "" {
+		secrets = append(secrets, s)
+	}
+	for _, s := range strings.Split(os.Getenv(SecKeysOldEnv), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			secrets = append(secrets, s)
+		}
+	}
+	return secrets
+}
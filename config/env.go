@@ -24,6 +24,9 @@ var (
 	ConfigName = "tcg_config.yaml"
 	// SecKeyEnv defines environment variable for
 	SecKeyEnv = "TCG_SECKEY"
+	// SecKeysOldEnv defines environment variable for comma-separated list of
+	// previous secrets, used for decryption only during the key rotation
+	SecKeysOldEnv = "TCG_SECKEYS_OLD"
 )
 
 func applyFlags() {
@@ -33,15 +36,28 @@ func applyFlags() {
 	/* as applyFlags (via GetConfig) used to be called in tests init
 	and std flag doesn't support it, using github.com/spf13/pflag instead */
 	flags := pflag.NewFlagSet(os.Args[0], pflag.ExitOnError)
+	AddEnvFlags(flags)
+	_ = flags.Parse(os.Args[1:])
+	ApplyEnvFlags()
+}
+
+// AddEnvFlags defines flags for names of environment variables,
+// used by subcommands handled before GetConfig,
+// ApplyEnvFlags should be called after parsing
+func AddEnvFlags(flags *pflag.FlagSet) {
 	flags.StringVar(&EnvPrefix, "env-prefix", "TCG_",
 		`prefix for environment variables, "TCG_" by default`)
 	flags.StringVar(&ConfigEnv, "config-env", "TCG_CONFIG",
 		`environment variable for config file path, "TCG_CONFIG" by default`)
 	flags.StringVar(&SecKeyEnv, "seckey-env", "TCG_SECKEY",
 		`environment variable for secret to crypt passwords in config file, "TCG_SECKEY" by default`)
-	_ = flags.Parse(os.Args[1:])
+	flags.StringVar(&SecKeysOldEnv, "seckeys-old-env", "TCG_SECKEYS_OLD",
+		`environment variable for previous secrets used in key rotation, "TCG_SECKEYS_OLD" by default`)
+}
 
-	for _, s := range []*string{&ConfigEnv, &SecKeyEnv} {
+// ApplyEnvFlags prefixes names of environment variables with EnvPrefix
+func ApplyEnvFlags() {
+	for _, s := range []*string{&ConfigEnv, &SecKeyEnv, &SecKeysOldEnv} {
 		*s = strings.TrimPrefix(*s, "TCG_")
 		*s = strings.TrimPrefix(*s, EnvPrefix)
 		*s = EnvPrefix + *s
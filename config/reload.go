@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Change describes changed config field
+type Change struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Reload reads config file and env, applies changed values,
+// returns the list of changed fields,
+// keeps config untouched if the file could not be read or parsed
+func (cfg *Config) Reload() ([]Change, error) {
+	data, err := os.ReadFile(cfg.ConfigPath())
+	if err != nil {
+		return nil, err
+	}
+	newCfg := new(Config)
+	*newCfg = defaults()
+	if err := yaml.Unmarshal(data, newCfg); err != nil {
+		return nil, fmt.Errorf("could not parse config: %w", err)
+	}
+	if err := applyEnv(newCfg); err != nil {
+		return nil, fmt.Errorf("could not apply env vars: %w", err)
+	}
+	/* keep the flag set on loading connector */
+	newCfg.Connector.IsDynamicInventory = cfg.Connector.IsDynamicInventory
+	newCfg.prepare()
+
+	changes := Diff(cfg, newCfg)
+	if len(changes) > 0 {
+		cfg.apply(newCfg)
+	}
+	return changes, nil
+}
+
+// Diff returns changed fields in form of struct-path,
+// for example: "Connector.LogLevel", "GWConnections[0].HostName"
+func Diff(a, b *Config) []Change {
+	var changes []Change
+	diff("", reflect.ValueOf(*a), reflect.ValueOf(*b), &changes)
+	return changes
+}
+
+func diff(path string, a, b reflect.Value, changes *[]Change) {
+	switch {
+	case a.Kind() == reflect.Struct:
+		for i := range a.NumField() {
+			f := a.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			p := path
+			if !f.Anonymous {
+				p = joinPath(path, f.Name)
+			}
+			/* fields tagged with `secret:"true"` are masked in changes */
+			if f.Tag.Get("secret") == "true" {
+				if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+					*changes = append(*changes, Change{Field: p, Old: maskSecret(a.Field(i)), New: maskSecret(b.Field(i))})
+				}
+				continue
+			}
+			diff(p, a.Field(i), b.Field(i), changes)
+		}
+	case a.Kind() == reflect.Slice && a.Type().Elem().Kind() == reflect.Struct:
+		for i := range max(a.Len(), b.Len()) {
+			diff(fmt.Sprintf("%s[%d]", path, i), sliceIndex(a, i), sliceIndex(b, i), changes)
+		}
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*changes = append(*changes, Change{
+				Field: path,
+				Old:   fmt.Sprintf("%+v", a.Interface()),
+				New:   fmt.Sprintf("%+v", b.Interface()),
+			})
+		}
+	}
+}
+
+// maskSecret hides value, keeps keys of map
+func maskSecret(v reflect.Value) string {
+	if v.Kind() != reflect.Map {
+		return "***"
+	}
+	m := make(map[string]string, v.Len())
+	for _, k := range v.MapKeys() {
+		m[fmt.Sprint(k.Interface())] = "***"
+	}
+	return fmt.Sprintf("%+v", m)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func sliceIndex(v reflect.Value, i int) reflect.Value {
+	if i < v.Len() {
+		return v.Index(i)
+	}
+	return reflect.Zero(v.Type().Elem())
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RotateKeys re-encrypts passwords in config file in place with the secret from SecKeyEnv.
+// Encrypted values are decrypted with the current or previous secrets from SecKeysOldEnv,
+// plain text values get encrypted, secret references are kept as is.
+// Returns the number of re-encrypted values, the file is untouched on any error
+func RotateKeys(configPath string) (int, error) {
+	if os.Getenv(SecKeyEnv) == "" {
+		return 0, fmt.Errorf("%s is empty", SecKeyEnv)
+	}
+	fi, err := os.Stat(configPath)
+	if err != nil {
+		return 0, err
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return 0, err
+	}
+	node := new(yaml.Node)
+	if err := yaml.Unmarshal(data, node); err != nil {
+		return 0, fmt.Errorf("could not parse config: %w", err)
+	}
+
+	var count int
+	var rotate func(*yaml.Node) error
+	rotate = func(node *yaml.Node) error {
+		if node.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				if key.Value != "password" || value.Kind != yaml.ScalarNode ||
+					value.Value == "" || IsSecretRef(value.Value) {
+					continue
+				}
+				decrypted, err := DecryptSecret(value.Value)
+				if err != nil {
+					return fmt.Errorf("line %d: %w", value.Line, err)
+				}
+				encrypted, err := EncryptSecret(decrypted)
+				if err != nil {
+					return fmt.Errorf("line %d: %w", value.Line, err)
+				}
+				value.SetString(encrypted)
+				count++
+			}
+		}
+		for _, n := range node.Content {
+			if err := rotate(n); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := rotate(node); err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	output, err := yaml.Marshal(node)
+	if err != nil {
+		return 0, err
+	}
+	return count, WriteFileAtomic(configPath, output, fi.Mode().Perm())
+}
+
+// WriteFileAtomic writes data into temp file in the same dir and renames it,
+// so the file is either untouched or fully written
+func WriteFileAtomic(name string, data []byte, perm os.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+	defer os.Remove(tmpPath)
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Chmod(perm); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, name)
+}
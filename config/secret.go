@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// SecretRefFile defines prefix of secret reference to file, like "file:///run/secrets/gw"
+	SecretRefFile = "file://"
+	// SecretRefEnv defines prefix of secret reference to environment variable, like "env://GW_PASSWORD"
+	SecretRefEnv = "env://"
+)
+
+// IsSecretRef checks if value is a secret reference
+func IsSecretRef(s string) bool {
+	return strings.HasPrefix(s, SecretRefFile) || strings.HasPrefix(s, SecretRefEnv)
+}
+
+// ResolveSecret returns the value referenced with file:// or env:// prefix,
+// other values are returned as is.
+// References are kept in config and resolved on use,
+// so the rotated secret is read on next use
+func ResolveSecret(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, SecretRefFile):
+		p := strings.TrimPrefix(s, SecretRefFile)
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return "", fmt.Errorf("could not resolve secret: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case strings.HasPrefix(s, SecretRefEnv):
+		name := strings.TrimPrefix(s, SecretRefEnv)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("could not resolve secret: %s is not set", name)
+		}
+		return v, nil
+	}
+	return s, nil
+}
+
+// SecretFiles returns files referenced in GWConnections
+func (cfg Config) SecretFiles() []string {
+	var files []string
+	for _, c := range cfg.GWConnections {
+		if strings.HasPrefix(c.Password, SecretRefFile) {
+			files = append(files, strings.TrimPrefix(c.Password, SecretRefFile))
+		}
+	}
+	return files
+}
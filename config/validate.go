@@ -0,0 +1,254 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/gwos/tcg/codec"
+	"gopkg.in/yaml.v3"
+)
+
+// Validate loads config from file and env the same way GetConfig does,
+// returns the effective config with found problems:
+// unknown keys, bad values, decrypt failures and unreachable paths
+func Validate(configPath string) (*Config, []error) {
+	var errs []error
+	cfg := new(Config)
+	*cfg = defaults()
+
+	if data, err := os.ReadFile(configPath); err != nil {
+		errs = append(errs, fmt.Errorf("could not read config: %w", err))
+	} else {
+		node := new(yaml.Node)
+		if err := yaml.Unmarshal(data, node); err != nil {
+			errs = append(errs, fmt.Errorf("could not parse config: %w", err))
+		} else if len(node.Content) > 0 {
+			errs = append(errs, unknownKeys(node.Content[0], reflect.TypeOf(*cfg), "")...)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			var typeErr *yaml.TypeError
+			if errors.As(err, &typeErr) {
+				for _, e := range typeErr.Errors {
+					errs = append(errs, fmt.Errorf("bad value: %s", e))
+				}
+			} else {
+				errs = append(errs, fmt.Errorf("could not decode config: %w", err))
+			}
+		}
+	}
+	if err := applyEnv(cfg, &Suppress); err != nil {
+		errs = append(errs, fmt.Errorf("could not apply env vars: %w", err))
+	}
+	/* check before prepare falls back to gzip */
+	for i, r := range cfg.Connector.NatsCompression {
+		if _, err := codec.Get(r.Codec); err != nil {
+			errs = append(errs, fmt.Errorf("natsCompression[%d].codec: %w", i, err))
+		}
+	}
+	cfg.prepare()
+	errs = append(errs, cfg.checkPaths()...)
+	for i, c := range cfg.GWConnections {
+		if _, err := ResolveSecret(c.Password); err != nil {
+			errs = append(errs, fmt.Errorf("gwConnections[%d].password: %w", i, err))
+		}
+	}
+	for field, v := range map[string]string{
+		"connector.natsPassword": cfg.Connector.NatsPassword,
+		"connector.natsToken":    cfg.Connector.NatsToken,
+	} {
+		if _, err := ResolveSecret(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+		}
+	}
+	if len(cfg.Connector.NatsURLs) != 0 {
+		if cfg.Connector.NatsStreamName == "" {
+			errs = append(errs, fmt.Errorf("connector.natsStreamName: required with natsURLs"))
+		}
+		if cfg.Connector.NatsSubjectPrefix == "" {
+			errs = append(errs, fmt.Errorf("connector.natsSubjectPrefix: required with natsURLs"))
+		}
+	}
+	if cfg.Connector.NatsReplicas < 0 {
+		errs = append(errs, fmt.Errorf("connector.natsReplicas: negative value: %d", cfg.Connector.NatsReplicas))
+	}
+	streamNames := make(map[string]bool)
+	for i, st := range cfg.Connector.NatsStreams {
+		if st.Name == "" || streamNames[st.Name] {
+			errs = append(errs, fmt.Errorf("natsStreams[%d].name: empty or duplicate: %q", i, st.Name))
+		}
+		streamNames[st.Name] = true
+		if len(st.Subjects) == 0 {
+			errs = append(errs, fmt.Errorf("natsStreams[%d].subjects: empty", i))
+		}
+	}
+	for i, w := range cfg.Connector.Webhooks {
+		if _, err := url.ParseRequestURI(w.URL); err != nil {
+			errs = append(errs, fmt.Errorf("webhooks[%d].url: %w", i, err))
+		}
+		for k, v := range w.Headers {
+			if _, err := ResolveSecret(v); err != nil {
+				errs = append(errs, fmt.Errorf("webhooks[%d].headers.%s: %w", i, k, err))
+			}
+		}
+	}
+	return cfg, errs
+}
+
+// unknownKeys walks yaml mapping nodes and reports keys absent in yaml tags of type
+func unknownKeys(node *yaml.Node, t reflect.Type, path string) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var errs []error
+	switch {
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			ft, ok := fields[key]
+			if !ok {
+				errs = append(errs, fmt.Errorf("unknown key: %s (line %d)", joinPath(path, key), node.Content[i].Line))
+				continue
+			}
+			errs = append(errs, unknownKeys(value, ft, joinPath(path, key))...)
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			errs = append(errs, unknownKeys(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value))...)
+		}
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for i, item := range node.Content {
+			errs = append(errs, unknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return errs
+}
+
+// yamlFields returns types of struct fields by yaml names including inline ones
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for k, v := range yamlFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// checkPaths reports missing input files and missing parent dirs of output paths
+func (cfg Config) checkPaths() []error {
+	var errs []error
+	for field, p := range map[string]string{
+		"connector.controllerCertFile":     cfg.Connector.ControllerCertFile,
+		"connector.controllerKeyFile":      cfg.Connector.ControllerKeyFile,
+		"connector.controllerClientCAFile": cfg.Connector.ControllerClientCAFile,
+		"connector.natsServerConfigFile":   cfg.Connector.NatsServerConfigFile,
+		"connector.natsCredsFile":          cfg.Connector.NatsCredsFile,
+		"connector.natsNKeyFile":           cfg.Connector.NatsNKeyFile,
+		"connector.natsTLSCAFile":          cfg.Connector.NatsTLSCAFile,
+		"connector.natsTLSCertFile":        cfg.Connector.NatsTLSCertFile,
+		"connector.natsTLSKeyFile":         cfg.Connector.NatsTLSKeyFile,
+	} {
+		if p == "" {
+			continue
+		}
+		if _, err := os.Stat(p); err != nil {
+			errs = append(errs, fmt.Errorf("unreachable path: %s: %w", field, err))
+		}
+	}
+	for field, p := range map[string]string{
+		"connector.logFile":          filepath.Dir(cfg.Connector.LogFile),
+		"connector.auditLogFile":     filepath.Dir(cfg.Connector.AuditLogFile),
+		"connector.natsFilestoreDir": filepath.Dir(filepath.Clean(cfg.Connector.NatsStoreDir)),
+		"connector.exportTransitDir": filepath.Dir(filepath.Clean(cfg.Connector.ExportTransitDir)),
+		"connector.batchWALDir":      filepath.Dir(filepath.Clean(cfg.Connector.BatchWALDir)),
+	} {
+		if p == "." {
+			continue
+		}
+		if fi, err := os.Stat(p); err != nil {
+			errs = append(errs, fmt.Errorf("unreachable path: %s: %w", field, err))
+		} else if !fi.IsDir() {
+			errs = append(errs, fmt.Errorf("unreachable path: %s: %s is not a directory", field, p))
+		}
+	}
+	return errs
+}
+
+// RedactedYAML returns config in yaml with secrets redacted,
+// secret references are kept
+func (cfg Config) RedactedYAML() ([]byte, error) {
+	node := new(yaml.Node)
+	if err := node.Encode(cfg); err != nil {
+		return nil, err
+	}
+	redact(node)
+	return yaml.Marshal(node)
+}
+
+// redactKeys lists yaml keys of secrets,
+// values of mapping are redacted for mapping key like "headers"
+var redactKeys = map[string]bool{"password": true, "natsPassword": true, "natsToken": true, "headers": true}
+
+func redact(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if !redactKeys[node.Content[i].Value] {
+				continue
+			}
+			switch v := node.Content[i+1]; v.Kind {
+			case yaml.ScalarNode:
+				redactValue(v)
+			case yaml.MappingNode:
+				for j := 1; j < len(v.Content); j += 2 {
+					redactValue(v.Content[j])
+				}
+			}
+		}
+	}
+	for _, n := range node.Content {
+		redact(n)
+	}
+}
+
+func redactValue(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Value != "" && !IsSecretRef(node.Value) {
+		node.SetString("***")
+	}
+}
+
+// MergeConnector applies connector config data the same way LoadConnectorDTO does
+// without writing the config file
+func (cfg *Config) MergeConnector(data []byte) error {
+	if _, err := cfg.loadConnector("", data); err != nil {
+		return fmt.Errorf("could not parse connector: %w", err)
+	}
+	if err := cfg.loadAdvancedPrefixes(data); err != nil {
+		return fmt.Errorf("could not parse advanced: %w", err)
+	}
+	if err := cfg.loadDynamicInventoryFlag(data); err != nil {
+		return fmt.Errorf("could not parse dynamic inventory flag: %w", err)
+	}
+	cfg.prepare()
+	return nil
+}
@@ -0,0 +1,116 @@
+package connectors
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gwos/tcg/sdk/transit"
+	"github.com/gwos/tcg/services"
+	"github.com/rs/zerolog/log"
+)
+
+// Agent defines connector run in multi-connector mode
+type Agent struct {
+	services.Agent
+	// Start is called once NATS started
+	Start func()
+}
+
+// RunAgents runs connectors in one process,
+// they share NATS, transport and controller,
+// each one gets config delivered via /api/v1/<name>/config
+func RunAgents(agents ...Agent) {
+	transitService := services.GetTransitService()
+	for _, a := range agents {
+		transitService.RegisterAgent(a.Agent)
+	}
+
+	log.Info().Msg("waiting for configuration to be delivered ...")
+	if err := transitService.DemandConfig(); err != nil {
+		log.Err(err).Msg("could not demand config")
+		return
+	}
+	if err := Start(); err != nil {
+		log.Err(err).Msg("could not start connectors")
+		return
+	}
+	for _, a := range agents {
+		if a.Start != nil {
+			a.Start()
+		}
+	}
+
+	/* return on quit signal */
+	<-transitService.Quit()
+}
+
+// makeTracerContext makes tracer context with the identity of connector
+// marked in ctx with services.CtxWithAgent
+func makeTracerContext(ctx context.Context) transit.TracerContext {
+	if name := services.AgentFromCtx(ctx); name != "" {
+		return services.GetTransitService().MakeAgentTracerContext(name)
+	}
+	return services.GetTransitService().MakeTracerContext()
+}
+
+// agentSettings holds settings grabbed from MonitorConnection extensions
+// of connector in multi-connector mode
+type agentSettings struct {
+	checkInterval           time.Duration
+	statusPolicy            transit.StatusPolicy
+	hostGroupStatusPolicies map[string]transit.StatusPolicy
+	// deltaStates and deltaStatesRefresh override TCG connector settings if set
+	deltaStates        *bool
+	deltaStatesRefresh int
+}
+
+// agentsSettings holds settings of connectors by name
+var agentsSettings = struct {
+	sync.RWMutex
+	m map[string]agentSettings
+}{m: make(map[string]agentSettings)}
+
+func setAgentSettings(name string, st agentSettings) {
+	agentsSettings.Lock()
+	defer agentsSettings.Unlock()
+	agentsSettings.m[name] = st
+}
+
+// settingsFor returns settings of connector marked in ctx with services.CtxWithAgent,
+// falls back to package level settings
+func settingsFor(ctx context.Context) agentSettings {
+	if name := services.AgentFromCtx(ctx); name != "" {
+		agentsSettings.RLock()
+		defer agentsSettings.RUnlock()
+		if st, ok := agentsSettings.m[name]; ok {
+			return st
+		}
+		return agentSettings{checkInterval: DefaultCheckInterval}
+	}
+	return agentSettings{
+		checkInterval:           CheckInterval,
+		statusPolicy:            StatusPolicy,
+		hostGroupStatusPolicies: HostGroupStatusPolicies,
+	}
+}
+
+// CheckIntervalFor returns CheckInterval of connector marked in ctx with services.CtxWithAgent
+func CheckIntervalFor(ctx context.Context) time.Duration {
+	return settingsFor(ctx).checkInterval
+}
+
+// deltaStatesFor returns DeltaStates settings of connector marked in ctx with services.CtxWithAgent,
+// falls back to TCG connector settings
+func deltaStatesFor(ctx context.Context) (bool, int) {
+	connector := services.GetTransitService().Connector
+	enabled, refresh := connector.DeltaStates, connector.DeltaStatesRefresh
+	st := settingsFor(ctx)
+	if st.deltaStates != nil {
+		enabled = *st.deltaStates
+	}
+	if st.deltaStatesRefresh > 0 {
+		refresh = st.deltaStatesRefresh
+	}
+	return enabled, refresh
+}
@@ -453,10 +453,12 @@ func initializeEntrypoints() []services.Entrypoint {
 			URL:     "/metrics/job/:name",
 			Method:  http.MethodPost,
 			Handler: receiverHandler,
+			Scope:   services.ScopeIngestMetrics,
 		},
 		{
 			URL:    "/metrics/available",
 			Method: http.MethodGet,
+			Scope:  services.ScopeReadStats,
 			Handler: func(c *gin.Context) {
 				resultSet := make([]string, 0, len(availableMetrics))
 				for _, arr := range availableMetrics {
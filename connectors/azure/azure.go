@@ -7,6 +7,7 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/rs/zerolog/log"
 
+	"github.com/gwos/tcg/config"
 	"github.com/gwos/tcg/connectors"
 	"github.com/gwos/tcg/connectors/azure/utils"
 	"github.com/gwos/tcg/sdk/clients"
@@ -27,10 +28,15 @@ func collectMetrics() {
 		extConfig.AzureClientSecret == "" || extConfig.AzureSubscriptionID == "" {
 		return
 	}
+	clientSecret, err := config.ResolveSecret(extConfig.AzureClientSecret)
+	if err != nil {
+		log.Error().Err(err).Msg("could not resolve client secret")
+		return
+	}
 
 	_ = os.Setenv(envAzureTenantID, extConfig.AzureTenantID)
 	_ = os.Setenv(envAzureClientID, extConfig.AzureClientID)
-	_ = os.Setenv(envAzureClientSecret, extConfig.AzureClientSecret)
+	_ = os.Setenv(envAzureClientSecret, clientSecret)
 
 	cred, err := azidentity.NewDefaultAzureCredential(nil)
 	if err != nil {
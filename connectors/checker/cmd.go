@@ -22,6 +22,8 @@ var (
 		Extensions: extConfig,
 	}
 	chksum []byte
+	// agentCtx is marked with connector name in multi-connector mode
+	agentCtx = context.Background()
 
 	sch = cron.New(
 		cron.WithSeconds(),
@@ -40,11 +42,7 @@ var (
 func Run() {
 	transitService := services.GetTransitService()
 	transitService.RegisterConfigHandler(configHandler)
-	transitService.RegisterExitHandler(func() {
-		if sch != nil {
-			sch.Stop()
-		}
-	})
+	transitService.RegisterExitHandler(exitHandler)
 
 	log.Info().Msg("waiting for configuration to be delivered ...")
 	if err := transitService.DemandConfig(); err != nil {
@@ -60,11 +58,29 @@ func Run() {
 	<-transitService.Quit()
 }
 
+// Agent returns connector for multi-connector mode
+func Agent(name string) connectors.Agent {
+	agentCtx = services.CtxWithAgent(context.Background(), name)
+	return connectors.Agent{
+		Agent: services.Agent{
+			Name:          name,
+			ConfigHandler: configHandler,
+			ExitHandler:   exitHandler,
+		},
+	}
+}
+
+func exitHandler() {
+	if sch != nil {
+		sch.Stop()
+	}
+}
+
 func configHandler(data []byte) {
 	log.Info().Msg("configuration received")
 	tExt, tMetProf := &ExtConfig{}, &transit.MetricsProfile{}
 	tMonConn := &transit.MonitorConnection{Extensions: tExt}
-	if err := connectors.UnmarshalConfig(data, tMetProf, tMonConn); err != nil {
+	if err := connectors.UnmarshalAgentConfig(agentCtx, data, tMetProf, tMonConn); err != nil {
 		log.Err(err).Msg("could not parse config")
 		return
 	}
@@ -113,7 +129,7 @@ func taskHandler(task ScheduleTask) func() {
 			handler = cmd.Output
 		}
 
-		ctx, span := tracing.StartTraceSpan(context.Background(), "connectors", "taskHandler")
+		ctx, span := tracing.StartTraceSpan(agentCtx, "connectors", "taskHandler")
 		defer func() {
 			tracing.EndTraceSpan(span,
 				tracing.TraceAttrError(err),
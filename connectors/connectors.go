@@ -24,7 +24,8 @@ const ExtKeyCheckInterval = "checkIntervalMinutes"
 // DefaultCheckInterval defines interval
 const DefaultCheckInterval = time.Minute * 2
 
-// CheckInterval comes from extensions field
+// CheckInterval comes from extensions field,
+// connectors in multi-connector mode use CheckIntervalFor
 var CheckInterval = DefaultCheckInterval
 
 var ErrUnsupportedType = errors.New("unsupported value type")
@@ -49,19 +50,49 @@ const noneThresholdText = "-1"
 
 // UnmarshalConfig updates args with data
 func UnmarshalConfig(data []byte, metricsProfile *transit.MetricsProfile, monitorConnection *transit.MonitorConnection) error {
-	/* grab CheckInterval from MonitorConnection extensions */
+	return UnmarshalAgentConfig(context.Background(), data, metricsProfile, monitorConnection)
+}
+
+// UnmarshalAgentConfig updates args with data,
+// keeps CheckInterval and status policies of connector marked in ctx with services.CtxWithAgent
+// apart of other connectors in multi-connector mode
+func UnmarshalAgentConfig(ctx context.Context, data []byte, metricsProfile *transit.MetricsProfile, monitorConnection *transit.MonitorConnection) error {
+	/* grab CheckInterval and status policies from MonitorConnection extensions */
 	var s struct {
 		MonitorConnection struct {
 			Extensions struct {
-				ExtensionsKeyTimer int `json:"checkIntervalMinutes"`
+				ExtensionsKeyTimer      int                             `json:"checkIntervalMinutes"`
+				StatusPolicy            transit.StatusPolicy            `json:"statusPolicy"`
+				HostGroupStatusPolicies map[string]transit.StatusPolicy `json:"hostGroupStatusPolicies"`
+				DeltaStates             *bool                           `json:"deltaStates"`
+				DeltaStatesRefresh      int                             `json:"deltaStatesRefresh"`
 			} `json:"extensions"`
 		} `json:"monitorConnection"`
 	}
 	if err := json.Unmarshal(data, &s); err == nil {
+		if err := s.MonitorConnection.Extensions.StatusPolicy.Validate(); err != nil {
+			return err
+		}
+		for g, p := range s.MonitorConnection.Extensions.HostGroupStatusPolicies {
+			if err := p.Validate(); err != nil {
+				return fmt.Errorf("host group %v: %w", g, err)
+			}
+		}
+		st := agentSettings{
+			checkInterval:           DefaultCheckInterval,
+			statusPolicy:            s.MonitorConnection.Extensions.StatusPolicy,
+			hostGroupStatusPolicies: s.MonitorConnection.Extensions.HostGroupStatusPolicies,
+			deltaStates:             s.MonitorConnection.Extensions.DeltaStates,
+			deltaStatesRefresh:      s.MonitorConnection.Extensions.DeltaStatesRefresh,
+		}
 		if s.MonitorConnection.Extensions.ExtensionsKeyTimer > 0 {
-			CheckInterval = time.Minute * time.Duration(s.MonitorConnection.Extensions.ExtensionsKeyTimer)
+			st.checkInterval = time.Minute * time.Duration(s.MonitorConnection.Extensions.ExtensionsKeyTimer)
+		}
+		if name := services.AgentFromCtx(ctx); name != "" {
+			setAgentSettings(name, st)
 		} else {
-			CheckInterval = DefaultCheckInterval
+			CheckInterval, StatusPolicy, HostGroupStatusPolicies =
+				st.checkInterval, st.statusPolicy, st.hostGroupStatusPolicies
 		}
 	}
 	/* process args */
@@ -105,22 +136,36 @@ func SendMetrics(ctx context.Context, resources []transit.MonitoredResource, gro
 	}()
 
 	request := transit.ResourcesWithServicesRequest{
-		Context:   services.GetTransitService().MakeTracerContext(),
+		Context:   makeTracerContext(ctx),
 		Resources: resources,
 	}
 	if groups != nil {
 		request.Groups = *groups
+		learnHostGroups(ctx, request.Groups, false)
 	}
 	for i := range request.Resources {
-		monitoredServices := EvaluateExpressions(request.Resources[i].Services)
-		request.Resources[i].Services = monitoredServices
-		request.Resources[i].LastPluginOutput = buildHostStatusText(monitoredServices)
+		request.Resources[i].Services = EvaluateExpressions(request.Resources[i].Services)
+		applyStatusPolicy(ctx, &request.Resources[i])
+	}
+	deltaStates, refresh := deltaStatesFor(ctx)
+	ttl := stateTTL(refresh, CheckIntervalFor(ctx))
+	var updates stateUpdates
+	if deltaStates {
+		request.Resources, updates = stateCache.filter(services.AgentFromCtx(ctx), request.Resources, refresh)
+		if len(request.Resources) == 0 {
+			stateCache.commit(updates, ttl)
+			log.Debug().Msg("SendMetrics: no changed states to send")
+			return nil
+		}
 	}
 	b, err = json.Marshal(request)
 	if err != nil {
 		return err
 	}
 	err = services.GetTransitService().SendResourceWithMetrics(ctxN, b)
+	if err == nil && deltaStates {
+		stateCache.commit(updates, ttl)
+	}
 	return err
 }
 
@@ -138,8 +183,12 @@ func SendInventory(ctx context.Context, resources []transit.InventoryResource, r
 		)
 	}()
 
+	/* hosts and services may be recreated on inventory, so force full refresh */
+	stateCache.clear(services.AgentFromCtx(ctx))
+	learnHostGroups(ctx, resourceGroups, true)
+
 	request := transit.InventoryRequest{
-		Context:       services.GetTransitService().MakeTracerContext(),
+		Context:       makeTracerContext(ctx),
 		OwnershipType: ownershipType,
 		Resources:     resources,
 		Groups:        resourceGroups,
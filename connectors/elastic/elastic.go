@@ -96,15 +96,20 @@ const (
 )
 
 func initClients(cfg ExtConfig) (clients.KibanaClient, clients.EsClient, error) {
+	password, err := config.ResolveSecret(cfg.Kibana.Password)
+	if err != nil {
+		log.Err(err).Msg("could not resolve kibana password")
+		return clients.KibanaClient{}, clients.EsClient{}, err
+	}
 	kibanaClient := clients.KibanaClient{
 		APIRoot:  cfg.Kibana.ServerName,
 		Username: cfg.Kibana.Username,
-		Password: cfg.Kibana.Password,
+		Password: password,
 	}
 	esClient := clients.EsClient{
 		Addresses: cfg.Servers,
 		Username:  cfg.Kibana.Username,
-		Password:  cfg.Kibana.Password,
+		Password:  password,
 	}
 	if err := kibanaClient.InitClient(); err != nil {
 		err = fmt.Errorf("could not initialize kibana client: %w", err)
@@ -323,6 +328,7 @@ func initializeEntrypoints() []services.Entrypoint {
 		{
 			URL:    "/suggest/:viewName",
 			Method: http.MethodGet,
+			Scope:  services.ScopeReadStats,
 			Handler: func(c *gin.Context) {
 				c.JSON(http.StatusOK, connector.ListSuggestions(c.Param("viewName"), ""))
 			},
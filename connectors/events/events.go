@@ -98,6 +98,7 @@ func initializeEntrypoints() []services.Entrypoint {
 			URL:     "/receive/events",
 			Method:  http.MethodPost,
 			Handler: receiver,
+			Scope:   services.ScopeIngestEvents,
 		},
 	}
 }
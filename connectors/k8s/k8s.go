@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/gwos/tcg/config"
 	"github.com/gwos/tcg/connectors"
 	"github.com/gwos/tcg/sdk/clients"
 	"github.com/gwos/tcg/sdk/mapping"
@@ -205,11 +206,19 @@ func (connector *KubernetesConnector) Initialize(ctx context.Context) error {
 	case InCluster:
 		log.Info().Msg("using InCluster auth")
 	case Credentials:
+		password, err := config.ResolveSecret(connector.ExtConfig.KubernetesUserPassword)
+		if err != nil {
+			return err
+		}
 		kConfig.Username = connector.ExtConfig.KubernetesUserName
-		kConfig.Password = connector.ExtConfig.KubernetesUserPassword
+		kConfig.Password = password
 		log.Info().Msg("using Credentials auth")
 	case BearerToken:
-		kConfig.BearerToken = connector.ExtConfig.KubernetesBearerToken
+		token, err := config.ResolveSecret(connector.ExtConfig.KubernetesBearerToken)
+		if err != nil {
+			return err
+		}
+		kConfig.BearerToken = token
 		log.Info().Msg("using Bearer Token auth")
 	case ConfigFile:
 		fConfig := KubernetesYaml{}
@@ -13,6 +13,8 @@ import (
 
 var (
 	nscaCancel context.CancelFunc
+	// agentCtx is marked with connector name in multi-connector mode
+	agentCtx = context.Background()
 )
 
 // @title TCG API Documentation
@@ -24,11 +26,7 @@ func Run() {
 	services.GetController().RegisterEntrypoints(initializeEntrypoints())
 
 	transitService := services.GetTransitService()
-	transitService.RegisterExitHandler(func() {
-		if nscaCancel != nil {
-			nscaCancel()
-		}
-	})
+	transitService.RegisterExitHandler(exitHandler)
 
 	log.Info().Msg("waiting for configuration to be delivered ...")
 	if err := transitService.DemandConfig(); err != nil {
@@ -40,10 +38,33 @@ func Run() {
 		return
 	}
 
+	startNSCA()
+
+	/* return on quit signal */
+	<-transitService.Quit()
+}
+
+// Agent returns connector for multi-connector mode
+func Agent(name string) connectors.Agent {
+	agentCtx = services.CtxWithAgent(context.Background(), name)
+	return connectors.Agent{
+		Agent: services.Agent{
+			Name:        name,
+			ExitHandler: exitHandler,
+			Entrypoints: initializeEntrypoints(),
+		},
+		Start: startNSCA,
+	}
+}
+
+func startNSCA() {
 	ctx, cancel := context.WithCancel(context.Background())
 	nscaCancel = cancel
 	nsca.Start(ctx, makeNSCAHandler())
+}
 
-	/* return on quit signal */
-	<-transitService.Quit()
+func exitHandler() {
+	if nscaCancel != nil {
+		nscaCancel()
+	}
 }
@@ -23,6 +23,7 @@ func initializeEntrypoints() []services.Entrypoint {
 			Handler: makeEntrypointHandler(dataFormat),
 			Method:  http.MethodPost,
 			URL:     fmt.Sprintf("check/%s", dataFormat),
+			Scope:   services.ScopeIngestMetrics,
 		})
 	}
 	return rv
@@ -34,7 +35,7 @@ func makeEntrypointHandler(dataFormat parser.DataFormat) func(*gin.Context) {
 			err     error
 			payload []byte
 		)
-		ctx, span := tracing.StartTraceSpan(context.Background(), "connectors", "EntrypointHandler")
+		ctx, span := tracing.StartTraceSpan(agentCtx, "connectors", "EntrypointHandler")
 		defer func() {
 			tracing.EndTraceSpan(span,
 				tracing.TraceAttrError(err),
@@ -66,7 +67,7 @@ func makeEntrypointHandler(dataFormat parser.DataFormat) func(*gin.Context) {
 
 func makeNSCAHandler() nsca.DataHandler {
 	return nsca.AdaptHandler(func(p []byte) error {
-		ctx, span := tracing.StartTraceSpan(context.Background(), "connectors", "EntrypointHandler")
+		ctx, span := tracing.StartTraceSpan(agentCtx, "connectors", "EntrypointHandler")
 		err := processData(ctx, p, parser.NSCA)
 		if err != nil {
 			log.Warn().Err(err).
@@ -98,7 +98,12 @@ func configHandler(data []byte) {
 	// if err != nil || !bytes.Equal(chksum, chk) {
 	// }
 
-	connector.SetCredentials(extConfig.TenantID, extConfig.ClientID, extConfig.ClientSecret)
+	clientSecret, err := config.ResolveSecret(extConfig.ClientSecret)
+	if err != nil {
+		log.Err(err).Msg("could not resolve client secret")
+		return
+	}
+	connector.SetCredentials(extConfig.TenantID, extConfig.ClientID, clientSecret)
 	connector.SetOptions(extConfig.SharePointSite, extConfig.SharePointSubsite, extConfig.OutlookEmail)
 	/* Restart periodic loop */
 	cancel()
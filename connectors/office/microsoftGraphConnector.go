@@ -461,6 +461,7 @@ func initializeEntrypoints() []services.Entrypoint {
 		{
 			URL:    "/suggest/:viewName",
 			Method: http.MethodGet,
+			Scope:  services.ScopeReadStats,
 			Handler: func(c *gin.Context) {
 				c.JSON(http.StatusOK, availableMetrics()[c.Param("viewName")])
 			},
@@ -15,6 +15,7 @@ import (
 	ociSearch "github.com/oracle/oci-go-sdk/v65/resourcesearch"
 	"github.com/rs/zerolog/log"
 
+	"github.com/gwos/tcg/config"
 	"github.com/gwos/tcg/connectors"
 	"github.com/gwos/tcg/connectors/oracle/utils"
 	"github.com/gwos/tcg/sdk/transit"
@@ -45,6 +46,11 @@ func collectMetrics() {
 		log.Error().Msg("failed to create oracle identity client: missing required config parameters")
 		return
 	}
+	privateKey, err := config.ResolveSecret(cfg.OraclePrivateKey)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create oracle identity client")
+		return
+	}
 
 	if len(cfg.GWMapping.Host) == 0 || len(cfg.GWMapping.Service) == 0 {
 		return
@@ -55,7 +61,7 @@ func collectMetrics() {
 		cfg.OracleUserOCID,
 		cfg.OracleRegion,
 		cfg.OracleFingerprint,
-		cfg.OraclePrivateKey,
+		privateKey,
 		nil,
 	)
 
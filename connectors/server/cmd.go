@@ -20,6 +20,8 @@ var (
 	}
 	chksum            []byte
 	ctxCancel, cancel = context.WithCancel(context.Background())
+	// agentCtx is marked with connector name in multi-connector mode
+	agentCtx = context.Background()
 )
 
 // @title TCG API Documentation
@@ -34,7 +36,7 @@ func Run() {
 
 	transitService := services.GetTransitService()
 	transitService.RegisterConfigHandler(configHandler)
-	transitService.RegisterExitHandler(cancel)
+	transitService.RegisterExitHandler(func() { cancel() })
 
 	log.Info().Msg("waiting for configuration to be delivered ...")
 	if err := transitService.DemandConfig(); err != nil {
@@ -47,12 +49,29 @@ func Run() {
 		return
 	}
 
-	connectors.StartPeriodic(ctxCancel, connectors.CheckInterval, periodicHandler)
+	connectors.StartPeriodic(ctxCancel, connectors.CheckIntervalFor(agentCtx), periodicHandler)
 
 	/* return on quit signal */
 	<-transitService.Quit()
 }
 
+// Agent returns connector for multi-connector mode
+func Agent(name string) connectors.Agent {
+	agentCtx = services.CtxWithAgent(context.Background(), name)
+	return connectors.Agent{
+		Agent: services.Agent{
+			Name:          name,
+			ConfigHandler: configHandler,
+			ExitHandler:   func() { cancel() },
+			Entrypoints:   initializeEntrypoints(),
+		},
+		Start: func() {
+			go handleCache()
+			connectors.StartPeriodic(ctxCancel, connectors.CheckIntervalFor(agentCtx), periodicHandler)
+		},
+	}
+}
+
 func handleCache() {
 	connectors.ProcessesCache.SetDefault("processes", collectProcesses())
 }
@@ -71,7 +90,7 @@ func configHandler(data []byte) {
 	}
 	tMonConn := &transit.MonitorConnection{Extensions: tExt}
 	tMetProf := &transit.MetricsProfile{}
-	if err := connectors.UnmarshalConfig(data, tMetProf, tMonConn); err != nil {
+	if err := connectors.UnmarshalAgentConfig(agentCtx, data, tMetProf, tMonConn); err != nil {
 		log.Err(err).Msg("could not parse config")
 		return
 	}
@@ -97,7 +116,7 @@ func configHandler(data []byte) {
 			groups[i] = connectors.FillGroupWithResources(group, resources)
 		}
 		_ = connectors.SendInventory(
-			context.Background(),
+			agentCtx,
 			resources,
 			groups,
 			extConfig.Ownership,
@@ -109,14 +128,13 @@ func configHandler(data []byte) {
 	/* Restart periodic loop */
 	cancel()
 	ctxCancel, cancel = context.WithCancel(context.Background())
-	services.GetTransitService().RegisterExitHandler(cancel)
-	connectors.StartPeriodic(ctxCancel, connectors.CheckInterval, periodicHandler)
+	connectors.StartPeriodic(ctxCancel, connectors.CheckIntervalFor(agentCtx), periodicHandler)
 }
 
 func periodicHandler() {
 	if len(metricsProfile.Metrics) > 0 {
 		log.Info().Msg("monitoring resources ...")
-		if err := connectors.SendMetrics(context.Background(), []transit.MonitoredResource{
+		if err := connectors.SendMetrics(agentCtx, []transit.MonitoredResource{
 			*CollectMetrics(metricsProfile.Metrics),
 		}, nil); err != nil {
 			log.Err(err).Msg("could not send metrics")
@@ -542,6 +542,7 @@ func initializeEntrypoints() []services.Entrypoint {
 		{
 			URL:    "/suggest/:viewName",
 			Method: http.MethodGet,
+			Scope:  services.ScopeReadStats,
 			Handler: func(c *gin.Context) {
 				if c.Param("viewName") == string(transit.ServiceTypeProcess) {
 					c.JSON(http.StatusOK, listSuggestions(""))
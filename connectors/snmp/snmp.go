@@ -246,6 +246,7 @@ func initializeEntryPoints() []services.Entrypoint {
 		{
 			URL:    "/suggest/:viewName",
 			Method: http.MethodGet,
+			Scope:  services.ScopeReadStats,
 			Handler: func(c *gin.Context) {
 				c.JSON(http.StatusOK, connector.listSuggestions(c.Param("viewName"), ""))
 			},
@@ -0,0 +1,156 @@
+package connectors
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/gwos/tcg/sdk/transit"
+)
+
+// stateCache holds fingerprints of sent states
+// used by SendMetrics to send only changed services if DeltaStates configured,
+// it is kept in memory apart of NATS store, so survives ResetNats
+var stateCache = &deltaStates{entries: make(map[stateKey]stateEntry), agentGens: make(map[string]int)}
+
+// stateKey identifies state of connector marked with services.CtxWithAgent,
+// agent is empty out of multi-connector mode
+type stateKey struct {
+	agent   string
+	host    string
+	service string
+}
+
+type stateEntry struct {
+	fingerprint uint64
+	skipped     int
+	// seen is the time of the last commit with the entry
+	seen time.Time
+}
+
+type deltaStates struct {
+	sync.Mutex
+	entries map[stateKey]stateEntry
+	// gen and agentGens are changed on clear to ignore commits of filtered before
+	gen       int
+	agentGens map[string]int
+}
+
+// stateUpdates holds entries of filtered states until they are sent
+type stateUpdates struct {
+	agent    string
+	gen      int
+	agentGen int
+	entries  map[stateKey]stateEntry
+}
+
+// ClearStateCache drops fingerprints of sent states
+// forces full refresh on the next SendMetrics
+func ClearStateCache() {
+	stateCache.Lock()
+	stateCache.entries = make(map[stateKey]stateEntry)
+	stateCache.gen++
+	stateCache.Unlock()
+}
+
+// clear drops fingerprints of sent states of connector only
+func (p *deltaStates) clear(agent string) {
+	p.Lock()
+	defer p.Unlock()
+	for key := range p.entries {
+		if key.agent == agent {
+			delete(p.entries, key)
+		}
+	}
+	p.agentGens[agent]++
+}
+
+// changed checks fingerprint and prepares entry update,
+// forces refresh after the refresh number of skipped cycles
+func (p *deltaStates) changed(updates stateUpdates, key stateKey, fingerprint uint64, refresh int) bool {
+	entry, ok := p.entries[key]
+	if ok && entry.fingerprint == fingerprint && entry.skipped+1 < refresh {
+		entry.skipped++
+		updates.entries[key] = entry
+		return false
+	}
+	updates.entries[key] = stateEntry{fingerprint: fingerprint}
+	return true
+}
+
+// filter drops unchanged services and resources,
+// keeps resource if its own state or any of its services changed,
+// the returned updates should be committed after the states are sent
+func (p *deltaStates) filter(agent string, resources []transit.MonitoredResource, refresh int) ([]transit.MonitoredResource, stateUpdates) {
+	p.Lock()
+	defer p.Unlock()
+
+	updates := stateUpdates{agent: agent, gen: p.gen, agentGen: p.agentGens[agent], entries: make(map[stateKey]stateEntry)}
+	result := make([]transit.MonitoredResource, 0, len(resources))
+	for _, res := range resources {
+		resChanged := p.changed(updates, stateKey{agent: agent, host: res.Name}, fingerprint(res.MonitoredInfo, nil), refresh)
+		services := make([]transit.MonitoredService, 0, len(res.Services))
+		for _, svc := range res.Services {
+			if p.changed(updates, stateKey{agent: agent, host: res.Name, service: svc.Name}, fingerprint(svc.MonitoredInfo, svc.Metrics), refresh) {
+				services = append(services, svc)
+			}
+		}
+		if resChanged || len(services) > 0 {
+			res.Services = services
+			result = append(result, res)
+		}
+	}
+	return result, updates
+}
+
+// commit stores fingerprints of sent states
+// and drops entries of the same connector not seen during ttl,
+// it is counted by time as connectors may send several subsets per check interval
+func (p *deltaStates) commit(updates stateUpdates, ttl time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+
+	if updates.gen != p.gen || updates.agentGen != p.agentGens[updates.agent] {
+		return
+	}
+	now := time.Now()
+	for key, entry := range updates.entries {
+		entry.seen = now
+		p.entries[key] = entry
+	}
+	for key, entry := range p.entries {
+		if key.agent == updates.agent && now.Sub(entry.seen) > ttl {
+			delete(p.entries, key)
+		}
+	}
+}
+
+// stateTTL returns time to keep entries not seen,
+// it covers the refresh number of check intervals
+func stateTTL(refresh int, checkInterval time.Duration) time.Duration {
+	return time.Duration(max(refresh, 1)+1) * checkInterval
+}
+
+// fingerprint calculates hash of status, plugin output and graphed metrics
+// ignores check times and metric intervals as they change every cycle
+func fingerprint(info transit.MonitoredInfo, metrics []transit.TimeSeries) uint64 {
+	type metric struct {
+		MetricName string
+		Value      *transit.TypedValue
+		Unit       transit.UnitType
+		Thresholds []transit.ThresholdValue
+	}
+	state := struct {
+		Status           transit.MonitorStatus
+		LastPluginOutput string
+		Metrics          []metric
+	}{info.Status, info.LastPluginOutput, make([]metric, 0, len(metrics))}
+	for _, m := range metrics {
+		state.Metrics = append(state.Metrics, metric{m.MetricName, m.Value, m.Unit, m.Thresholds})
+	}
+
+	h := fnv.New64a()
+	_ = json.NewEncoder(h).Encode(state)
+	return h.Sum64()
+}
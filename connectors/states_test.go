@@ -0,0 +1,127 @@
+package connectors
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gwos/tcg/sdk/transit"
+	"github.com/gwos/tcg/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeltaStates(t *testing.T) {
+	makeHost := func(name string, svcStatus transit.MonitorStatus, value int64) []transit.MonitoredResource {
+		return []transit.MonitoredResource{{
+			BaseResource:  transit.BaseResource{BaseInfo: transit.BaseInfo{Name: name}},
+			MonitoredInfo: transit.MonitoredInfo{Status: transit.HostUp, LastCheckTime: transit.NewTimestamp()},
+			Services: []transit.MonitoredService{{
+				BaseInfo:      transit.BaseInfo{Name: "svc1"},
+				MonitoredInfo: transit.MonitoredInfo{Status: svcStatus, LastCheckTime: transit.NewTimestamp()},
+				Metrics: []transit.TimeSeries{{
+					MetricName: "m1",
+					Value:      transit.NewTypedValue(value),
+				}},
+			}, {
+				BaseInfo:      transit.BaseInfo{Name: "svc2"},
+				MonitoredInfo: transit.MonitoredInfo{Status: transit.ServiceOk, LastCheckTime: transit.NewTimestamp()},
+			}},
+		}}
+	}
+	makeResources := func(svcStatus transit.MonitorStatus, value int64) []transit.MonitoredResource {
+		return makeHost("host1", svcStatus, value)
+	}
+
+	p := &deltaStates{entries: make(map[stateKey]stateEntry), agentGens: make(map[string]int)}
+	refresh := 3
+	ttl := time.Hour
+
+	filter := func(resources []transit.MonitoredResource) []transit.MonitoredResource {
+		res, updates := p.filter("", resources, refresh)
+		p.commit(updates, ttl)
+		return res
+	}
+
+	res, _ := p.filter("", makeResources(transit.ServiceOk, 1), refresh)
+	assert.Len(t, res, 1)
+	res = filter(makeResources(transit.ServiceOk, 1))
+	if assert.Len(t, res, 1, "not committed states should be sent again") {
+		assert.Len(t, res[0].Services, 2)
+	}
+
+	res = filter(makeResources(transit.ServiceOk, 1))
+	assert.Empty(t, res, "unchanged states should be skipped")
+
+	res = filter(makeResources(transit.ServiceOk, 2))
+	if assert.Len(t, res, 1) {
+		assert.Len(t, res[0].Services, 1, "only changed metric should be sent")
+		assert.Equal(t, "svc1", res[0].Services[0].Name)
+	}
+
+	res = filter(makeResources(transit.ServiceOk, 2))
+	if assert.Len(t, res, 1, "full refresh should be forced") {
+		assert.Len(t, res[0].Services, 1)
+		assert.Equal(t, "svc2", res[0].Services[0].Name)
+	}
+
+	/* several subsets per check interval do not drop each other */
+	filter(makeHost("host2", transit.ServiceOk, 1))
+	for range refresh + 1 {
+		filter(makeHost("host3", transit.ServiceOk, 1))
+	}
+	assert.Contains(t, p.entries, stateKey{host: "host2"})
+	res = filter(makeHost("host2", transit.ServiceOk, 1))
+	assert.Empty(t, res, "unchanged states of other subset should be skipped")
+
+	/* entries of gone resources are dropped after ttl */
+	ttl = 0
+	time.Sleep(time.Millisecond)
+	filter(nil)
+	assert.Empty(t, p.entries)
+
+	_, updates := p.filter("", makeResources(transit.ServiceOk, 1), refresh)
+	p.gen++
+	p.commit(updates, ttl)
+	assert.Empty(t, p.entries, "commit before clear should be ignored")
+
+	/* connectors in multi-connector mode keep states apart */
+	res, updates = p.filter("agent1", makeResources(transit.ServiceOk, 1), refresh)
+	assert.Len(t, res, 1)
+	p.commit(updates, time.Hour)
+	res, updates = p.filter("agent2", makeResources(transit.ServiceOk, 1), refresh)
+	assert.Len(t, res, 1, "states of other connector should not suppress")
+	p.commit(updates, time.Hour)
+	p.clear("agent1")
+	res, _ = p.filter("agent2", makeResources(transit.ServiceOk, 1), refresh)
+	assert.Empty(t, res, "clear should keep states of other connector")
+	res, _ = p.filter("agent1", makeResources(transit.ServiceOk, 1), refresh)
+	assert.Len(t, res, 1)
+
+	assert.Equal(t, 8*time.Minute, stateTTL(3, 2*time.Minute))
+	assert.Equal(t, 4*time.Minute, stateTTL(0, 2*time.Minute))
+}
+
+func TestDeltaStatesFor(t *testing.T) {
+	connector := services.GetTransitService().Connector
+	deltaStates, refresh := connector.DeltaStates, connector.DeltaStatesRefresh
+	t.Cleanup(func() {
+		connector.DeltaStates, connector.DeltaStatesRefresh = deltaStates, refresh
+		agentsSettings.m = make(map[string]agentSettings)
+	})
+	connector.DeltaStates, connector.DeltaStatesRefresh = false, 10
+
+	ctx := context.Background()
+	ctx1, ctx2 := services.CtxWithAgent(ctx, "agent1"), services.CtxWithAgent(ctx, "agent2")
+	assert.NoError(t, UnmarshalAgentConfig(ctx1, []byte(`{"monitorConnection":{"extensions":{
+		"deltaStates":true,"deltaStatesRefresh":3}}}`),
+		&transit.MetricsProfile{}, &transit.MonitorConnection{}))
+	assert.NoError(t, UnmarshalAgentConfig(ctx2, []byte(`{"monitorConnection":{"extensions":{}}}`),
+		&transit.MetricsProfile{}, &transit.MonitorConnection{}))
+
+	enabled, n := deltaStatesFor(ctx1)
+	assert.True(t, enabled)
+	assert.Equal(t, 3, n)
+	enabled, n = deltaStatesFor(ctx2)
+	assert.False(t, enabled)
+	assert.Equal(t, 10, n)
+}
@@ -0,0 +1,83 @@
+package connectors
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/gwos/tcg/sdk/transit"
+	"github.com/gwos/tcg/services"
+)
+
+// StatusPolicy comes from extensions field,
+// the empty one keeps the host status set by connector,
+// connectors in multi-connector mode keep own policies
+var StatusPolicy transit.StatusPolicy
+
+// HostGroupStatusPolicies comes from extensions field,
+// overrides StatusPolicy for hosts in listed host groups
+var HostGroupStatusPolicies map[string]transit.StatusPolicy
+
+// hostGroups holds host groups of hosts by connector name
+// collected from SendInventory and SendMetrics groups
+var hostGroups = struct {
+	sync.RWMutex
+	m map[string]map[string][]string
+}{m: make(map[string]map[string][]string)}
+
+// learnHostGroups updates hostGroups of connector marked in ctx with groups of HostGroup type,
+// inventory brings the full set of groups, so it replaces learned ones
+func learnHostGroups(ctx context.Context, groups []transit.ResourceGroup, replace bool) {
+	hostGroups.Lock()
+	defer hostGroups.Unlock()
+	name := services.AgentFromCtx(ctx)
+	m := hostGroups.m[name]
+	if replace || m == nil {
+		m = make(map[string][]string)
+		hostGroups.m[name] = m
+	}
+	for _, g := range groups {
+		if g.Type != transit.HostGroup {
+			continue
+		}
+		for _, res := range g.Resources {
+			if !slices.Contains(m[res.Name], g.GroupName) {
+				m[res.Name] = append(m[res.Name], g.GroupName)
+				slices.Sort(m[res.Name])
+			}
+		}
+	}
+}
+
+// statusPolicyFor returns the policy of the first host group of host
+// in name order having the one, or StatusPolicy of connector otherwise
+func statusPolicyFor(ctx context.Context, host string) transit.StatusPolicy {
+	st := settingsFor(ctx)
+	if len(st.hostGroupStatusPolicies) > 0 {
+		hostGroups.RLock()
+		defer hostGroups.RUnlock()
+		for _, g := range hostGroups.m[services.AgentFromCtx(ctx)][host] {
+			if p, ok := st.hostGroupStatusPolicies[g]; ok {
+				return p
+			}
+		}
+	}
+	return st.statusPolicy
+}
+
+// applyStatusPolicy sets host status by configured policy,
+// status text explains which rule fired
+func applyStatusPolicy(ctx context.Context, res *transit.MonitoredResource) {
+	text := buildHostStatusText(res.Services)
+	if p := statusPolicyFor(ctx, res.Name); p.Type != "" {
+		var policyText string
+		res.Status, policyText = p.Calculate(res.Services)
+		switch {
+		case text == "":
+			text = policyText
+		case policyText != "":
+			text = policyText + " " + text
+		}
+	}
+	res.LastPluginOutput = text
+}
@@ -0,0 +1,99 @@
+package connectors
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gwos/tcg/sdk/transit"
+	"github.com/gwos/tcg/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyStatusPolicy(t *testing.T) {
+	t.Cleanup(func() {
+		StatusPolicy, HostGroupStatusPolicies = transit.StatusPolicy{}, nil
+		learnHostGroups(context.Background(), nil, true)
+	})
+
+	assert.NoError(t, UnmarshalConfig([]byte(`{"monitorConnection":{"extensions":{
+		"statusPolicy":{"type":"worstOf"},
+		"hostGroupStatusPolicies":{"db":{"type":"hostCheck","services":["ping"]}}}}}`),
+		&transit.MetricsProfile{}, &transit.MonitorConnection{}))
+	assert.Equal(t, transit.StatusPolicyWorstOf, StatusPolicy.Type)
+	assert.Contains(t, HostGroupStatusPolicies, "db")
+
+	assert.ErrorContains(t, UnmarshalConfig([]byte(`{"monitorConnection":{"extensions":{
+		"statusPolicy":{"type":"worst"}}}}`),
+		&transit.MetricsProfile{}, &transit.MonitorConnection{}), "unknown status policy type")
+	assert.ErrorContains(t, UnmarshalConfig([]byte(`{"monitorConnection":{"extensions":{
+		"hostGroupStatusPolicies":{"db":{"type":"percentCritical","percent":0}}}}}`),
+		&transit.MetricsProfile{}, &transit.MonitorConnection{}), "host group db")
+	assert.Equal(t, transit.StatusPolicyWorstOf, StatusPolicy.Type, "invalid policy should not be applied")
+
+	ctx := context.Background()
+	learnHostGroups(ctx, []transit.ResourceGroup{
+		{GroupName: "db", Type: transit.HostGroup, Resources: []transit.ResourceRef{{Name: "host2"}}},
+		{GroupName: "web", Type: transit.ServiceGroup, Resources: []transit.ResourceRef{{Name: "host1"}}},
+	}, true)
+
+	makeResource := func(name string) transit.MonitoredResource {
+		return transit.MonitoredResource{
+			BaseResource:  transit.BaseResource{BaseInfo: transit.BaseInfo{Name: name}},
+			MonitoredInfo: transit.MonitoredInfo{Status: transit.HostUp},
+			Services: []transit.MonitoredService{{
+				BaseInfo:      transit.BaseInfo{Name: "ping"},
+				MonitoredInfo: transit.MonitoredInfo{Status: transit.ServiceOk},
+			}, {
+				BaseInfo:      transit.BaseInfo{Name: "disk"},
+				MonitoredInfo: transit.MonitoredInfo{Status: transit.ServiceWarning},
+			}},
+		}
+	}
+
+	res := makeResource("host1")
+	applyStatusPolicy(ctx, &res)
+	assert.Equal(t, transit.HostWarning, res.Status)
+	assert.Equal(t, "Host status WARNING by worst-of policy: service disk is SERVICE_WARNING. "+
+		"Host has 1 OK, 1 WARNING, 0 CRITICAL and 0 other services.", res.LastPluginOutput)
+
+	res = makeResource("host2")
+	applyStatusPolicy(ctx, &res)
+	assert.Equal(t, transit.HostUp, res.Status)
+	assert.Contains(t, res.LastPluginOutput, "by host-check policy")
+
+	StatusPolicy = transit.StatusPolicy{Type: transit.StatusPolicyUp}
+	res = makeResource("host1")
+	applyStatusPolicy(ctx, &res)
+	assert.Equal(t, transit.HostUp, res.Status)
+	assert.Equal(t, "Host status UP by up policy. Host has 1 OK, 1 WARNING, 0 CRITICAL and 0 other services.",
+		res.LastPluginOutput)
+
+	StatusPolicy, HostGroupStatusPolicies = transit.StatusPolicy{}, nil
+	res = makeResource("host1")
+	applyStatusPolicy(ctx, &res)
+	assert.Equal(t, transit.HostUp, res.Status)
+	assert.Equal(t, "Host has 1 OK, 1 WARNING, 0 CRITICAL and 0 other services.", res.LastPluginOutput)
+
+	/* connectors in multi-connector mode keep own settings */
+	ctx1, ctx2 := services.CtxWithAgent(ctx, "agent1"), services.CtxWithAgent(ctx, "agent2")
+	t.Cleanup(func() {
+		agentsSettings.m = make(map[string]agentSettings)
+	})
+	assert.NoError(t, UnmarshalAgentConfig(ctx1, []byte(`{"monitorConnection":{"extensions":{
+		"checkIntervalMinutes":5,"statusPolicy":{"type":"worstOf"}}}}`),
+		&transit.MetricsProfile{}, &transit.MonitorConnection{}))
+	assert.NoError(t, UnmarshalAgentConfig(ctx2, []byte(`{"monitorConnection":{"extensions":{}}}`),
+		&transit.MetricsProfile{}, &transit.MonitorConnection{}))
+	assert.Equal(t, 5*time.Minute, CheckIntervalFor(ctx1))
+	assert.Equal(t, DefaultCheckInterval, CheckIntervalFor(ctx2))
+	assert.Equal(t, DefaultCheckInterval, CheckInterval)
+	assert.Empty(t, StatusPolicy.Type)
+
+	res = makeResource("host1")
+	applyStatusPolicy(ctx1, &res)
+	assert.Equal(t, transit.HostWarning, res.Status)
+	res = makeResource("host1")
+	applyStatusPolicy(ctx2, &res)
+	assert.Equal(t, transit.HostUp, res.Status)
+}
@@ -0,0 +1,99 @@
+//go:build !codeanalysis
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gwos/tcg/config"
+	"github.com/gwos/tcg/services"
+	"github.com/spf13/pflag"
+)
+
+const (
+	CmdEncrypt    = "encrypt"
+	CmdDecrypt    = "decrypt"
+	CmdRotateKeys = "rotate-keys"
+	CmdHashToken  = "hash-token"
+)
+
+// runEncrypt prints the value from args or stdin encrypted with the secret from $TCG_SECKEY
+func runEncrypt(args []string) int {
+	return runCrypt(CmdEncrypt, args, config.EncryptSecret)
+}
+
+// runDecrypt prints the value from args or stdin decrypted
+// with the secret from $TCG_SECKEY or previous ones from $TCG_SECKEYS_OLD
+func runDecrypt(args []string) int {
+	return runCrypt(CmdDecrypt, args, config.DecryptSecret)
+}
+
+// runHashToken prints the hash of local API token from args or stdin to put in config
+func runHashToken(args []string) int {
+	return runCrypt(CmdHashToken, args, func(s string) (string, error) {
+		return services.HashToken(s), nil
+	})
+}
+
+func runCrypt(cmd string, args []string, fn func(string) (string, error)) int {
+	flags := pflag.NewFlagSet(cmd, pflag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s [value]\nReads value from stdin if omitted\n", os.Args[0], cmd)
+		flags.PrintDefaults()
+	}
+	config.AddEnvFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	config.ApplyEnvFlags()
+
+	var value string
+	switch flags.NArg() {
+	case 0:
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR:", err)
+			return 1
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	case 1:
+		value = flags.Arg(0)
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	res, err := fn(value)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		return 1
+	}
+	fmt.Fprintln(os.Stdout, res)
+	return 0
+}
+
+// runRotateKeys re-encrypts passwords in config file in place with the secret from $TCG_SECKEY
+func runRotateKeys(args []string) int {
+	flags := pflag.NewFlagSet(CmdRotateKeys, pflag.ContinueOnError)
+	configPath := flags.String("config", "",
+		"path to config file, $TCG_CONFIG or tcg_config.yaml in work directory by default")
+	config.AddEnvFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	config.ApplyEnvFlags()
+	if *configPath == "" {
+		*configPath = config.Config{}.ConfigPath()
+	}
+
+	count, err := config.RotateKeys(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s: %v\n", *configPath, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%s: re-encrypted %d value(s)\n", *configPath, count)
+	return 0
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/gwos/tcg/config"
+	"github.com/gwos/tcg/connectors"
 	"github.com/gwos/tcg/connectors/apm"
 	"github.com/gwos/tcg/connectors/azure"
 	"github.com/gwos/tcg/connectors/checker"
@@ -20,6 +21,7 @@ import (
 	"github.com/gwos/tcg/connectors/oracle"
 	"github.com/gwos/tcg/connectors/server"
 	"github.com/gwos/tcg/connectors/snmp"
+	"github.com/rs/zerolog/log"
 )
 
 const (
@@ -37,6 +39,15 @@ const (
 	CmdOracle  = "oracle"
 )
 
+// subcommands are run instead of connector
+var subcommands = map[string]func(args []string) int{
+	CmdValidate:   runValidate,
+	CmdEncrypt:    runEncrypt,
+	CmdDecrypt:    runDecrypt,
+	CmdRotateKeys: runRotateKeys,
+	CmdHashToken:  runHashToken,
+}
+
 var cmdRe = regexp.MustCompile(`^(tcg[_-])?(?P<cmdName>.+?)([_-]connector)?$`)
 
 func matchCmd(cmd, str string) bool {
@@ -48,6 +59,17 @@ func matchCmd(cmd, str string) bool {
 }
 
 func main() {
+	/* handle subcommands before GetConfig as it applies own flags */
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+	if config.GetConfig().IsMulti() {
+		runMulti(config.GetConfig().Connector.Connectors)
+		return
+	}
+
 	args0bs := filepath.Base(os.Args[0])
 	appName := config.GetConfig().Connector.AppName
 
@@ -80,3 +102,23 @@ func main() {
 			" args0bs=" + args0bs + " appName=" + appName)
 	}
 }
+
+// runMulti runs connectors in one process sharing NATS, transport and controller
+func runMulti(names []string) {
+	agents := make([]connectors.Agent, 0, len(names))
+	for _, name := range names {
+		switch {
+		case matchCmd(CmdChecker, name):
+			agents = append(agents, checker.Agent(name))
+		case matchCmd(CmdNSCA, name):
+			agents = append(agents, nsca.Agent(name))
+		case matchCmd(CmdServer, name):
+			agents = append(agents, server.Agent(name))
+		default:
+			log.Error().Str("connector", name).
+				Msg("connector is not supported in multi-connector mode")
+			os.Exit(1)
+		}
+	}
+	connectors.RunAgents(agents...)
+}
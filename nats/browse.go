@@ -0,0 +1,146 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// MaxBrowseLimit caps the number of messages listed at once
+const MaxBrowseLimit = 1000
+
+// StreamMsg defines stream message
+type StreamMsg = jetstream.RawStreamMsg
+
+// ConsumerState defines delivered sequences of durable in stream
+type ConsumerState struct {
+	Durable      string
+	Stream       string
+	DeliveredSeq uint64
+	AckFloorSeq  uint64
+	Pending      uint64
+	AckPending   int
+}
+
+// StreamNames returns names of configured streams
+func StreamNames() []string {
+	return getLayout().streamNames()
+}
+
+func (l *streamLayout) streamNames() []string {
+	names := make([]string, 0, len(l.streams))
+	for _, sc := range l.streams {
+		names = append(names, sc.Name)
+	}
+	return names
+}
+
+// browseStream returns configured stream by name, the first one if name is empty
+func browseStream(ctx context.Context, name string) (jetstream.Stream, error) {
+	s.Lock()
+	nc := s.ncPublisher
+	s.Unlock()
+
+	if nc == nil {
+		return nil, fmt.Errorf("%w: unavailable", ErrNATS)
+	}
+	l := getLayout()
+	if name == "" {
+		name = l.streams[0].Name
+	}
+	if !slices.Contains(l.streamNames(), name) {
+		return nil, fmt.Errorf("%w: unknown stream: %s", ErrNATS, name)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, err
+	}
+	return js.Stream(ctx, name)
+}
+
+// ListMsgs returns stream messages matching subject in sequence range,
+// subject is without configured prefix and may contain wildcards, empty means all,
+// toSeq 0 means the last sequence, limit should be in range 1..MaxBrowseLimit
+func ListMsgs(ctx context.Context, stream, subject string, fromSeq, toSeq uint64, limit int) ([]*StreamMsg, error) {
+	if limit <= 0 || limit > MaxBrowseLimit {
+		return nil, fmt.Errorf("%w: invalid limit: %d, should be 1..%d", ErrNATS, limit, MaxBrowseLimit)
+	}
+	jsStream, err := browseStream(ctx, stream)
+	if err != nil {
+		return nil, err
+	}
+	info, err := jsStream.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*StreamMsg, 0)
+	if info.State.Msgs == 0 {
+		return msgs, nil
+	}
+	if subject == "" {
+		subject = subjAll
+	}
+	lastSeq := info.State.LastSeq
+	if toSeq != 0 {
+		lastSeq = min(toSeq, lastSeq)
+	}
+	seq := max(fromSeq, info.State.FirstSeq)
+	for seq <= lastSeq && len(msgs) < limit {
+		msg, err := jsStream.GetMsg(ctx, seq, jetstream.WithGetMsgSubject(getLayout().subjPrefix+subject))
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if msg.Sequence > lastSeq {
+			break
+		}
+		msgs = append(msgs, msg)
+		seq = msg.Sequence + 1
+	}
+	return msgs, nil
+}
+
+// GetMsg returns stream message by sequence
+func GetMsg(ctx context.Context, stream string, seq uint64) (*StreamMsg, error) {
+	jsStream, err := browseStream(ctx, stream)
+	if err != nil {
+		return nil, err
+	}
+	return jsStream.GetMsg(ctx, seq)
+}
+
+// MsgSubject returns subject of stream message without configured prefix
+func MsgSubject(msg *StreamMsg) string {
+	return strings.TrimPrefix(msg.Subject, getLayout().subjPrefix)
+}
+
+// ConsumerStates returns delivered sequences of durables in all streams
+func ConsumerStates(ctx context.Context) ([]ConsumerState, error) {
+	states := make([]ConsumerState, 0)
+	for _, sc := range getLayout().streams {
+		jsStream, err := browseStream(ctx, sc.Name)
+		if err != nil {
+			return nil, err
+		}
+		lister := jsStream.ListConsumers(ctx)
+		for info := range lister.Info() {
+			states = append(states, ConsumerState{
+				Durable:      info.Name,
+				Stream:       info.Stream,
+				DeliveredSeq: info.Delivered.Stream,
+				AckFloorSeq:  info.AckFloor.Stream,
+				Pending:      info.NumPending,
+				AckPending:   info.NumAckPending,
+			})
+		}
+		if err := lister.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return states, nil
+}
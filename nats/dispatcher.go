@@ -21,7 +21,12 @@ var (
 
 	// RetryDelays is overridden from config package
 	RetryDelays = []time.Duration{time.Second * 30, time.Minute * 1, time.Minute * 5, time.Minute * 20}
-	xFetchID    = new(expvar.Int)
+	// holdDelay is the delay of checking inventory holding other streams,
+	// holding longer than holdWarn is logged, holdMax releases streams held by stuck inventory
+	holdDelay = time.Second
+	holdWarn  = time.Minute
+	holdMax   = time.Minute * 30
+	xFetchID  = new(expvar.Int)
 )
 
 type dispatcherRetry struct {
@@ -30,11 +35,35 @@ type dispatcherRetry struct {
 	Retry     int
 }
 
+type dispatcherActivity struct {
+	LastError     error
+	LastErrorAt   time.Time
+	LastSuccessAt time.Time
+}
+
+// DurableState defines delivery state of durable subscription
+type DurableState struct {
+	Durable string
+	// Pending is the number of messages not yet delivered
+	Pending uint64
+	// AckPending is the number of messages delivered but not acknowledged
+	AckPending int
+	// OldestAge is the age of the oldest not acknowledged message
+	OldestAge time.Duration
+	// Retry is the current step in RetryDelays, -1 if not retrying
+	Retry         int
+	RetryDelay    time.Duration
+	LastError     error
+	LastErrorAt   time.Time
+	LastSuccessAt time.Time
+}
+
 // natsDispatcher provides deliverer for nats messages
 // with retry logic based on subscribe/close durable subscriptions
 type natsDispatcher struct {
 	*state
 
+	activity *cache.Cache
 	duraSeqs *cache.Cache
 	retries  *cache.Cache
 	cancel   context.CancelFunc
@@ -44,6 +73,7 @@ func getDispatcher() *natsDispatcher {
 	onceDispatcher.Do(func() {
 		dispatcher = &natsDispatcher{
 			state:    s,
+			activity: cache.New(-1, -1),
 			duraSeqs: cache.New(-1, -1),
 			retries:  cache.New(time.Minute*30, time.Minute*30),
 		}
@@ -65,32 +95,110 @@ func (d *natsDispatcher) OpenDurable(ctx context.Context, opt DurableCfg) {
 		return
 	}
 
-	//// nats: cannot run concurrent processing using ordered consumer
-	// cons, err := js.OrderedConsumer(ctx, streamName, jetstream.OrderedConsumerConfig{
-	// 	DeliverPolicy:  jetstream.DeliverLastPolicy,
-	// 	FilterSubjects: []string{opt.Subject},
-	// })
-	cons, err := js.CreateOrUpdateConsumer(ctx, streamName, jetstream.ConsumerConfig{
-		AckWait:        d.config.AckWait,
-		AckPolicy:      jetstream.AckExplicitPolicy,
-		DeliverPolicy:  jetstream.DeliverLastPolicy,
-		FilterSubjects: subjects,
-		Durable:        opt.Durable,
-		Name:           opt.Durable,
-	})
-	if err != nil {
-		log.Err(err).
-			Str("durable", opt.Durable).
-			Msg("nats dispatcher failed jetstream Consumer")
-		return
+	/* streams are consumed independently, so events are not delayed by metrics backlog */
+	l := getLayout()
+	consumers := make(map[string]jetstream.Consumer, len(l.streams))
+	for _, sc := range l.streams {
+		//// nats: cannot run concurrent processing using ordered consumer
+		// cons, err := js.OrderedConsumer(ctx, streamName, jetstream.OrderedConsumerConfig{
+		// 	DeliverPolicy:  jetstream.DeliverLastPolicy,
+		// 	FilterSubjects: []string{opt.Subject},
+		// })
+		cons, err := js.CreateOrUpdateConsumer(ctx, sc.Name, jetstream.ConsumerConfig{
+			AckWait:        d.config.AckWait,
+			AckPolicy:      jetstream.AckExplicitPolicy,
+			DeliverPolicy:  jetstream.DeliverLastPolicy,
+			FilterSubjects: sc.Subjects,
+			Durable:        opt.Durable,
+			Name:           opt.Durable,
+		})
+		if err != nil {
+			log.Err(err).
+				Str("durable", opt.Durable).
+				Str("stream", sc.Name).
+				Msg("nats dispatcher failed jetstream Consumer")
+			continue
+		}
+		consumers[sc.Name] = cons
 	}
 
-	go d.fetch(ctx, opt, cons)
+	/* but inventory goes before other streams referencing it */
+	inv := consumers[l.inventoryStream]
+	for _, sc := range l.streams {
+		cons, ok := consumers[sc.Name]
+		if !ok {
+			continue
+		}
+		hold := inv
+		if sc.Name == l.inventoryStream {
+			hold = nil
+		}
+		go d.fetch(ctx, opt, l.consumerKey(opt.Durable, sc.Name), cons, &inventoryHold{inv: hold})
+	}
 }
 
-func (d *natsDispatcher) fetch(ctx context.Context, opt DurableCfg, cons jetstream.Consumer) {
+// heldByInventory checks the inventory consumer of the same durable has messages
+// not acknowledged yet, timestamps of separate streams are not comparable,
+// so any pending inventory holds other streams
+func heldByInventory(ctx context.Context, inv jetstream.Consumer) bool {
+	info, err := inv.Info(ctx)
+	return err == nil && (info.NumPending > 0 || info.NumAckPending > 0)
+}
+
+// inventoryHold tracks holding of consumer by inventory consumer of the same durable
+type inventoryHold struct {
+	inv      jetstream.Consumer
+	since    time.Time
+	warnedAt time.Time
+	released bool
+}
+
+// check returns true if fetched messages should wait for inventory,
+// holding is logged at warn level every holdWarn and is released after holdMax
+// until the inventory is delivered
+func (h *inventoryHold) check(ctx context.Context, logger zerolog.Logger) bool {
+	if h.inv == nil {
+		return false
+	}
+	if !heldByInventory(ctx, h.inv) {
+		h.since, h.released = time.Time{}, false
+		return false
+	}
+	now := time.Now()
+	if h.since.IsZero() {
+		h.since, h.warnedAt = now, now
+	}
+	held := now.Sub(h.since)
+	switch {
+	case h.released:
+		return false
+	case held >= holdMax:
+		h.released = true
+		logger.Warn().Stringer("held", held.Round(time.Second)).
+			Msg("dispatcher releasing hold: inventory is not delivered for too long")
+		return false
+	case now.Sub(h.warnedAt) >= holdWarn:
+		h.warnedAt = now
+		logger.Warn().Stringer("held", held.Round(time.Second)).
+			Msg("dispatcher holding: inventory is not delivered yet")
+	default:
+		logger.Debug().Msg("dispatcher holding: inventory is not delivered yet")
+	}
+	return true
+}
+
+// consumerKey identifies consumer of durable in stream,
+// it is the durable name for the single stream layout
+func (l *streamLayout) consumerKey(durable, stream string) string {
+	if len(l.streams) == 1 {
+		return durable
+	}
+	return durable + "@" + stream
+}
+
+func (d *natsDispatcher) fetch(ctx context.Context, opt DurableCfg, key string, cons jetstream.Consumer, hold *inventoryHold) {
 	xFetchID.Add(1)
-	logger := log.With().Int64("fetchID", xFetchID.Value()).Str("durable", opt.Durable).Logger()
+	logger := log.With().Int64("fetchID", xFetchID.Value()).Str("durable", key).Logger()
 	logger.Trace().Msg("dispatcher fetch begin")
 	defer func() { logger.Trace().Msg("dispatcher fetch end") }()
 
@@ -98,9 +206,9 @@ func (d *natsDispatcher) fetch(ctx context.Context, opt DurableCfg, cons jetstre
 	xFetchedAt.Set(-1)
 	xProcessedAt.Set(-1)
 	xRetryDelay.Set("")
-	xStats.Set(opt.Durable+":fetchedAt", xFetchedAt)
-	xStats.Set(opt.Durable+":processedAt", xProcessedAt)
-	xStats.Set(opt.Durable+":retryDelay", xRetryDelay)
+	xStats.Set(key+":fetchedAt", xFetchedAt)
+	xStats.Set(key+":processedAt", xProcessedAt)
+	xStats.Set(key+":retryDelay", xRetryDelay)
 	for {
 		select {
 		case <-ctx.Done():
@@ -125,23 +233,35 @@ func (d *natsDispatcher) fetch(ctx context.Context, opt DurableCfg, cons jetstre
 
 		/* Process fetched messages and delay next fetching in case of transient error */
 
-		delayRetry, done := &dispatcherRetry{}, true
+		delayRetry, done, held, checked := &dispatcherRetry{}, true, false, false
 		for msg := range msgBatch.Messages() {
-			if !done {
+			/* check inventory once per fetched batch */
+			if !checked {
+				checked, held = true, hold.check(ctx, logger)
+			}
+			if !done || held {
 				_ = msg.Nak()
 
-				logger.Trace().Msg("dispatcher skipping: preparing retry")
+				logger.Trace().Msg("dispatcher skipping: preparing retry or holding")
 				continue
 			}
 
 			xProcessedAt.Set(time.Now().UnixMilli())
-			done = d.processMsg(logger.WithContext(ctx), opt, msg, delayRetry)
+			done = d.processMsg(logger.WithContext(ctx), opt, key, msg, delayRetry)
 			if !done {
 				_ = msg.Nak()
 			} else {
 				_ = msg.Ack()
 			}
 		}
+		if held {
+			select {
+			case <-ctx.Done():
+				logger.Trace().Msg("dispatcher holding: context cancelled")
+			case <-time.After(holdDelay):
+			}
+			continue
+		}
 		if !done {
 			xRetryDelay.Set(fmt.Sprintf("%v / %v / %v", delayRetry.Retry, RetryDelays[delayRetry.Retry], time.Now().UTC().Format(time.RFC3339)))
 			logger.Debug().
@@ -161,8 +281,9 @@ func (d *natsDispatcher) fetch(ctx context.Context, opt DurableCfg, cons jetstre
 }
 
 // processMsg wraps opt.Handler() call,
-// in case of transient error it calculates retry and returns False
-func (d *natsDispatcher) processMsg(ctx context.Context, opt DurableCfg, msg jetstream.Msg, retry *dispatcherRetry) bool {
+// in case of transient error it calculates retry and returns False,
+// the order and retries are tracked per consumer key
+func (d *natsDispatcher) processMsg(ctx context.Context, opt DurableCfg, key string, msg jetstream.Msg, retry *dispatcherRetry) bool {
 	done := true
 	meta, err := msg.Metadata()
 	if err != nil {
@@ -171,7 +292,7 @@ func (d *natsDispatcher) processMsg(ctx context.Context, opt DurableCfg, msg jet
 		return done
 	}
 	doneSeq, lostOrder := uint64(0), false
-	if seq, ok := d.duraSeqs.Get(opt.Durable); ok {
+	if seq, ok := d.duraSeqs.Get(key); ok {
 		if doneSeq = seq.(uint64); doneSeq >= meta.Sequence.Stream {
 			lostOrder = true
 		}
@@ -197,8 +318,9 @@ func (d *natsDispatcher) processMsg(ctx context.Context, opt DurableCfg, msg jet
 	}
 
 	err = opt.Handler(ctx, msg)
+	d.trackActivity(opt.Durable, err)
 	if err == nil {
-		d.duraSeqs.Set(opt.Durable, meta.Sequence.Stream, -1)
+		d.duraSeqs.Set(key, meta.Sequence.Stream, -1)
 		logger.Info().
 			Msg("dispatcher delivered")
 		return done
@@ -219,22 +341,99 @@ func (d *natsDispatcher) processMsg(ctx context.Context, opt DurableCfg, msg jet
 		LastError: err,
 		Retry:     0,
 	}
-	if lastRetry, ok := d.retries.Get(opt.Durable); ok {
+	if lastRetry, ok := d.retries.Get(key); ok {
 		lastRetry := lastRetry.(dispatcherRetry)
 		retry.Retry = lastRetry.Retry + 1
 	}
 
 	if retry.Retry >= len(RetryDelays) {
-		d.retries.Delete(opt.Durable)
+		d.retries.Delete(key)
 		logger.Warn().Err(err).
 			Msg("dispatcher could not deliver: stop retrying")
 		return done
 	}
 
-	d.retries.Set(opt.Durable, *retry, 0)
+	d.retries.Set(key, *retry, 0)
 	logger.Info().Err(err).
 		Int("retry", retry.Retry).
 		Msg("dispatcher could not deliver: will retry")
 
 	return !done
 }
+
+func (d *natsDispatcher) trackActivity(durable string, err error) {
+	a := dispatcherActivity{}
+	if v, ok := d.activity.Get(durable); ok {
+		a = v.(dispatcherActivity)
+	}
+	if err == nil {
+		a.LastSuccessAt = time.Now().UTC()
+	} else {
+		a.LastError, a.LastErrorAt = err, time.Now().UTC()
+	}
+	d.activity.Set(durable, a, -1)
+}
+
+// DurableStates returns delivery state of durables,
+// the state is summed up over streams
+func DurableStates(ctx context.Context, durables []string) ([]DurableState, error) {
+	d := getDispatcher()
+	d.Lock()
+	nc := d.ncPublisher
+	d.Unlock()
+
+	if nc == nil {
+		return nil, fmt.Errorf("%w: unavailable", ErrNATS)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, err
+	}
+	l := getLayout()
+	jsStreams := make([]jetstream.Stream, 0, len(l.streams))
+	for _, sc := range l.streams {
+		stream, err := js.Stream(ctx, sc.Name)
+		if err != nil {
+			return nil, err
+		}
+		jsStreams = append(jsStreams, stream)
+	}
+
+	states := make([]DurableState, 0, len(durables))
+	for _, durable := range durables {
+		st := DurableState{Durable: durable, Retry: -1}
+		if v, ok := d.activity.Get(durable); ok {
+			a := v.(dispatcherActivity)
+			st.LastError, st.LastErrorAt, st.LastSuccessAt = a.LastError, a.LastErrorAt, a.LastSuccessAt
+		}
+		for i, stream := range jsStreams {
+			if v, ok := d.retries.Get(l.consumerKey(durable, l.streams[i].Name)); ok {
+				if r := v.(dispatcherRetry); r.Retry < len(RetryDelays) && r.Retry > st.Retry {
+					st.Retry, st.RetryDelay = r.Retry, RetryDelays[r.Retry]
+				}
+			}
+			cons, err := stream.Consumer(ctx, durable)
+			if errors.Is(err, jetstream.ErrConsumerNotFound) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			info, err := cons.Info(ctx)
+			if err != nil {
+				return nil, err
+			}
+			st.Pending, st.AckPending = st.Pending+info.NumPending, st.AckPending+info.NumAckPending
+			if info.NumPending > 0 || info.NumAckPending > 0 {
+				/* the first message after ack floor is the oldest not acknowledged */
+				msg, err := stream.GetMsg(ctx, info.AckFloor.Stream+1, jetstream.WithGetMsgSubject(l.subjPrefix+subjAll))
+				if err == nil {
+					st.OldestAge = max(st.OldestAge, time.Since(msg.Time))
+				} else if !errors.Is(err, jetstream.ErrMsgNotFound) {
+					return nil, err
+				}
+			}
+		}
+		states = append(states, st)
+	}
+	return states, nil
+}
@@ -0,0 +1,166 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rs/zerolog/log"
+)
+
+// Define dead-letter IDs
+// the dead-letter stream is separated from the main one
+// to keep its messages out of the dispatcher durables
+const (
+	defaultDLQStreamName = "tcg-dlq"
+	dlqSubjPrefix        = "dlq."
+)
+
+var (
+	ErrDLQ = fmt.Errorf("%w: dead-letter", ErrNATS)
+)
+
+// DLQMsg defines dead-letter message
+type DLQMsg = jetstream.RawStreamMsg
+
+func defineDLQStream(ctx context.Context, nc *nats.Conn) error {
+	storage := jetstream.FileStorage
+	if strings.EqualFold(s.config.StoreType, "MEMORY") {
+		storage = jetstream.MemoryStorage
+	}
+	l := getLayout()
+	sc := jetstream.StreamConfig{
+		Name:        l.dlqStreamName,
+		Subjects:    l.dlqSubjects,
+		Storage:     storage,
+		AllowDirect: true,
+		MaxAge:      s.config.StoreMaxAge,
+		MaxMsgs:     s.config.DLQMaxMsgs,
+		Retention:   jetstream.LimitsPolicy,
+		Replicas:    max(s.config.Replicas, 1),
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		log.Err(err).Msg("nats failed JetStream")
+		return err
+	}
+	if _, err := js.CreateOrUpdateStream(ctx, sc); err != nil {
+		log.Err(err).
+			Str("config", fmt.Sprintf("%+v", sc)).
+			Msg("nats failed CreateOrUpdateStream")
+		return err
+	}
+	return nil
+}
+
+func dlqStream(ctx context.Context) (jetstream.Stream, error) {
+	s.Lock()
+	nc := s.ncPublisher
+	s.Unlock()
+
+	if nc == nil {
+		return nil, fmt.Errorf("%w: unavailable", ErrDLQ)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, err
+	}
+	return js.Stream(ctx, getLayout().dlqStreamName)
+}
+
+// PutDLQ stores message in dead-letter stream
+// the original subject without configured prefix is kept as suffix of dead-letter subject
+func PutDLQ(ctx context.Context, subj string, data []byte, header http.Header) error {
+	s.Lock()
+	nc := s.ncPublisher
+	s.Unlock()
+
+	if nc == nil {
+		err := fmt.Errorf("%w: unavailable", ErrDLQ)
+		log.Err(err).Msg("nats dead-letter failed")
+		return err
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		log.Err(err).Msg("nats dead-letter failed JetStream")
+		return err
+	}
+	l := getLayout()
+	msg := nats.NewMsg(l.subjPrefix + dlqSubjPrefix + strings.TrimPrefix(subj, l.subjPrefix))
+	msg.Data = data
+	maps.Copy(msg.Header, header)
+	if _, err := js.PublishMsg(ctx, msg); err != nil {
+		log.Err(err).Msg("nats dead-letter failed PublishMsg")
+		return err
+	}
+	return nil
+}
+
+// ListDLQ returns dead-letter messages starting from sequence
+// limit 0 means no limit
+func ListDLQ(ctx context.Context, fromSeq uint64, limit int) ([]*DLQMsg, error) {
+	stream, err := dlqStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*DLQMsg, 0)
+	if info.State.Msgs == 0 {
+		return msgs, nil
+	}
+	subj := getLayout().dlqSubjects[0]
+	seq := max(fromSeq, info.State.FirstSeq)
+	for seq <= info.State.LastSeq && (limit == 0 || len(msgs) < limit) {
+		msg, err := stream.GetMsg(ctx, seq, jetstream.WithGetMsgSubject(subj))
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+		seq = msg.Sequence + 1
+	}
+	return msgs, nil
+}
+
+// GetDLQ returns dead-letter message by sequence
+func GetDLQ(ctx context.Context, seq uint64) (*DLQMsg, error) {
+	stream, err := dlqStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stream.GetMsg(ctx, seq)
+}
+
+// DeleteDLQ removes dead-letter message by sequence
+func DeleteDLQ(ctx context.Context, seq uint64) error {
+	stream, err := dlqStream(ctx)
+	if err != nil {
+		return err
+	}
+	return stream.DeleteMsg(ctx, seq)
+}
+
+// PurgeDLQ removes all dead-letter messages
+func PurgeDLQ(ctx context.Context) error {
+	stream, err := dlqStream(ctx)
+	if err != nil {
+		return err
+	}
+	return stream.Purge(ctx)
+}
+
+// OriginalSubject returns subject the dead-letter message was received on
+// without configured prefix, so it is suitable for Pub
+func OriginalSubject(msg *DLQMsg) string {
+	return strings.TrimPrefix(strings.TrimPrefix(msg.Subject, getLayout().subjPrefix), dlqSubjPrefix)
+}
@@ -1,6 +1,7 @@
 package nats
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"expvar"
@@ -8,8 +9,10 @@ import (
 	"maps"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nats-io/nats-server/v2/logger"
@@ -22,8 +25,13 @@ import (
 )
 
 // Define NATS IDs
+// stream names and subjects are configurable
+// to separate agents sharing external NATS
 const (
-	streamName = "tcg-stream"
+	defaultStreamName = "tcg-stream"
+	subjAll           = "tcg.>"
+	// subjInventory matches inventory subjects of agents
+	subjInventory = "tcg.inventory.*"
 )
 
 var (
@@ -31,7 +39,9 @@ var (
 	ErrDispatcher = fmt.Errorf("%w: dispatcher", ErrNATS)
 	ErrPayloadLim = fmt.Errorf("%w: payload oversized limit", ErrNATS)
 
-	subjects = []string{"tcg.>"}
+	// layout of streams is replaced as a whole on reconfiguring
+	layout atomic.Pointer[streamLayout]
+	_      = func() int { layout.Store(newStreamLayout(Config{})); return 0 }()
 
 	xClientURL = expvar.NewString("tcgNatsClientURL")
 	xStats     = expvar.NewMap("tcgNatsStats")
@@ -45,6 +55,10 @@ type state struct {
 
 	config Config
 	server *server.Server
+	// url and opts of client connections
+	// either the embedded server or external NATS
+	url  string
+	opts []nats.Option
 	// if a client is too slow the server will eventually cut them off by closing the connection
 	ncDispatcher *nats.Conn
 	ncPublisher  *nats.Conn
@@ -66,8 +80,38 @@ type Config struct {
 	StoreMaxAge        time.Duration
 	StoreMaxBytes      int64
 	StoreMaxMsgs       int64
+	DLQMaxMsgs         int64
 
 	ConfigFile string
+
+	// URLs of external NATS, if set the embedded server is not started
+	URLs          []string
+	CredsFile     string
+	NKeyFile      string
+	User          string
+	Password      string
+	Token         string
+	TLSCAFile     string
+	TLSCertFile   string
+	TLSKeyFile    string
+	StreamName    string
+	SubjectPrefix string
+	Replicas      int
+
+	// Streams splits subjects into separate streams,
+	// if empty all subjects are kept in the single stream
+	Streams []StreamCfg
+}
+
+// StreamCfg defines separate stream of subjects with own retention,
+// zero limits fall back to Store* limits
+type StreamCfg struct {
+	Name              string
+	Subjects          []string
+	MaxAge            time.Duration
+	MaxBytes          int64
+	MaxMsgs           int64
+	MaxMsgsPerSubject int64
 }
 
 // DurableCfg defines subscription
@@ -77,13 +121,68 @@ type DurableCfg struct {
 }
 
 // StartServer runs NATS
+// connects to external NATS if URLs are configured
 func StartServer(config Config) error {
-	if s.server != nil {
+	if IsStartedServer() {
 		log.Info().
-			Msgf("nats already started at: %s", s.server.ClientURL())
+			Msgf("nats already started at: %s", s.url)
 		return nil
 	}
 
+	s.Lock()
+	defer s.Unlock()
+
+	s.config = config
+	setStreams(config)
+	if len(config.URLs) == 0 {
+		if err := startEmbedded(config); err != nil {
+			return err
+		}
+		s.url, s.opts = s.server.ClientURL(), nil
+	} else {
+		/* agents sharing NATS must not touch streams of each other */
+		if config.StreamName == "" || config.SubjectPrefix == "" {
+			err := fmt.Errorf("%w: stream name and subject prefix are required with external NATS", ErrNATS)
+			log.Err(err).Msg("nats failed config")
+			return err
+		}
+		opts, err := clientOptions(config)
+		if err != nil {
+			log.Err(err).Msg("nats failed client options")
+			return err
+		}
+		s.url, s.opts = strings.Join(config.URLs, ","), opts
+		log.Info().Msgf("nats external at: %s", s.url)
+	}
+	xClientURL.Set(s.url)
+
+	nc, err := connect()
+	if err != nil {
+		log.Err(err).Msg("nats failed Connect")
+		if s.server == nil {
+			s.url = ""
+			xClientURL.Set("")
+		}
+		return err
+	}
+	s.ncPublisher = nc
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	if err := defineStreams(ctx, nc); err != nil {
+		log.Err(err).Msg("nats failed defineStreams")
+		return err
+	}
+	if err := defineDLQStream(ctx, nc); err != nil {
+		log.Err(err).Msg("nats failed defineDLQStream")
+		return err
+	}
+
+	go handlePubchan(ctx)
+	return nil
+}
+
+func startEmbedded(config Config) error {
 	opts := new(server.Options)
 	if config.ConfigFile != "" {
 		if _, err := os.Open(config.ConfigFile); err != nil {
@@ -106,10 +205,6 @@ func StartServer(config Config) error {
 		opts.JetStreamMaxStore = config.StoreMaxBytes
 	}
 
-	s.Lock()
-	defer s.Unlock()
-
-	s.config = config
 	if s.server == nil {
 		if natsServer, err := server.NewServer(opts); err == nil {
 			s.server = natsServer
@@ -133,27 +228,43 @@ func StartServer(config Config) error {
 			}
 		}).
 		Msgf("nats started at: %s", s.server.ClientURL())
-	xClientURL.Set(s.server.ClientURL())
+	return nil
+}
 
-	nc, err := nats.Connect(s.server.ClientURL())
-	if err != nil {
-		log.Err(err).Msg("nats failed Connect")
-		return err
-	}
-	s.ncPublisher = nc
+// streamLayout defines stream names and subjects,
+// it is immutable after creation and may be read concurrently
+type streamLayout struct {
+	streamName    string
+	dlqStreamName string
+	subjPrefix    string
+	streams       []jetstream.StreamConfig
+	dlqSubjects   []string
+	// inventoryStream is set if inventory is kept separately,
+	// other streams are delivered after it then
+	inventoryStream string
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	s.cancel = cancel
-	if err := defineStream(ctx, nc); err != nil {
-		log.Err(err).Msg("nats failed defineStream")
-		return err
-	}
+// getLayout returns current layout of streams
+func getLayout() *streamLayout {
+	return layout.Load()
+}
 
-	go handlePubchan(ctx)
-	return nil
+// setStreams applies configured stream names, subject prefix and retention
+func setStreams(config Config) {
+	layout.Store(newStreamLayout(config))
 }
 
-func defineStream(ctx context.Context, nc *nats.Conn) error {
+func newStreamLayout(config Config) *streamLayout {
+	l := &streamLayout{streamName: defaultStreamName, dlqStreamName: defaultDLQStreamName}
+	if config.StreamName != "" {
+		l.streamName, l.dlqStreamName = config.StreamName, config.StreamName+"-dlq"
+	}
+	l.subjPrefix = config.SubjectPrefix
+	if l.subjPrefix != "" && !strings.HasSuffix(l.subjPrefix, ".") {
+		l.subjPrefix += "."
+	}
+	l.dlqSubjects = []string{l.subjPrefix + dlqSubjPrefix + ">"}
+
 	storage := func(arg string) jetstream.StorageType {
 		switch strings.ToUpper(arg) {
 		case "MEMORY":
@@ -161,26 +272,182 @@ func defineStream(ctx context.Context, nc *nats.Conn) error {
 		default:
 			return jetstream.FileStorage
 		}
-	}(s.config.StoreType)
-	sc := jetstream.StreamConfig{
-		Name:        streamName,
-		Subjects:    subjects,
-		Storage:     storage,
-		AllowDirect: true,
-		MaxAge:      s.config.StoreMaxAge,
-		MaxBytes:    s.config.StoreMaxBytes,
-		MaxMsgs:     s.config.StoreMaxMsgs,
-		Retention:   jetstream.LimitsPolicy,
+	}(config.StoreType)
+	cfgs := config.Streams
+	if len(cfgs) == 0 {
+		cfgs = []StreamCfg{{Subjects: []string{subjAll}}}
+	}
+	l.streams = make([]jetstream.StreamConfig, 0, len(cfgs))
+	for _, c := range cfgs {
+		name := l.streamName
+		if c.Name != "" {
+			name = l.streamName + "-" + c.Name
+		}
+		subjs := make([]string, 0, len(c.Subjects))
+		for _, subj := range c.Subjects {
+			subjs = append(subjs, l.subjPrefix+subj)
+		}
+		l.streams = append(l.streams, jetstream.StreamConfig{
+			Name:              name,
+			Subjects:          subjs,
+			Storage:           storage,
+			AllowDirect:       true,
+			MaxAge:            cmp.Or(c.MaxAge, config.StoreMaxAge),
+			MaxBytes:          cmp.Or(c.MaxBytes, config.StoreMaxBytes),
+			MaxMsgs:           cmp.Or(c.MaxMsgs, config.StoreMaxMsgs),
+			MaxMsgsPerSubject: cmp.Or(c.MaxMsgsPerSubject, -1),
+			Discard:           jetstream.DiscardOld,
+			Retention:         jetstream.LimitsPolicy,
+			Replicas:          max(config.Replicas, 1),
+		})
+	}
+
+	if len(l.streams) > 1 {
+		for _, sc := range l.streams {
+			if slices.ContainsFunc(sc.Subjects, func(filter string) bool {
+				return SubjectMatch(filter, l.subjPrefix+subjInventory)
+			}) {
+				l.inventoryStream = sc.Name
+				break
+			}
+		}
+	}
+	return l
+}
+
+// SubjectMatch checks subject against filter with "*" and ">" wildcards
+func SubjectMatch(filter, subj string) bool {
+	ft, st := strings.Split(filter, "."), strings.Split(subj, ".")
+	for i, t := range ft {
+		if t == ">" {
+			return len(st) > i
+		}
+		if i >= len(st) || (t != "*" && t != st[i]) {
+			return false
+		}
+	}
+	return len(ft) == len(st)
+}
+
+// clientOptions returns connection options of external NATS
+func clientOptions(config Config) ([]nats.Option, error) {
+	opts := []nats.Option{nats.MaxReconnects(-1)}
+	switch {
+	case config.CredsFile != "":
+		opts = append(opts, nats.UserCredentials(config.CredsFile))
+	case config.NKeyFile != "":
+		opt, err := nats.NkeyOptionFromSeed(config.NKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	case config.User != "":
+		opts = append(opts, nats.UserInfo(config.User, config.Password))
+	case config.Token != "":
+		opts = append(opts, nats.Token(config.Token))
+	}
+	if config.TLSCAFile != "" {
+		opts = append(opts, nats.RootCAs(config.TLSCAFile))
+	}
+	if config.TLSCertFile != "" || config.TLSKeyFile != "" {
+		opts = append(opts, nats.ClientCert(config.TLSCertFile, config.TLSKeyFile))
 	}
+	return opts, nil
+}
+
+// connect opens client connection to NATS
+func connect() (*nats.Conn, error) {
+	return nats.Connect(s.url, s.opts...)
+}
 
+func defineStreams(ctx context.Context, nc *nats.Conn) error {
 	js, err := jetstream.New(nc)
 	if err != nil {
 		log.Err(err).Msg("nats failed JetStream")
 		return err
 	}
+	l := getLayout()
+	if err := removeStaleStreams(ctx, js, l); err != nil {
+		return err
+	}
+	for _, sc := range l.streams {
+		if err := defineStream(ctx, js, sc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownStreamNames returns names of existing streams of the agent capturing TCG subjects,
+// the streams of other agents sharing NATS are not touched even if subjects overlap
+func ownStreamNames(ctx context.Context, js jetstream.JetStream, l *streamLayout) ([]string, error) {
+	names := js.StreamNames(ctx, jetstream.WithStreamListSubject(l.subjPrefix+subjAll))
+	own := make([]string, 0)
+	for name := range names.Name() {
+		if name == l.streamName || (strings.HasPrefix(name, l.streamName+"-") && name != l.dlqStreamName) {
+			own = append(own, name)
+		}
+	}
+	if err := names.Err(); err != nil {
+		log.Err(err).Msg("nats failed StreamNames")
+		return nil, err
+	}
+	return own, nil
+}
+
+// removeStaleStreams deletes own streams which are not configured,
+// that happens on changing the streams layout, as subjects of streams may not overlap.
+// It refuses while the stale stream has messages not delivered by its consumers
+func removeStaleStreams(ctx context.Context, js jetstream.JetStream, l *streamLayout) error {
+	names, err := ownStreamNames(ctx, js, l)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if slices.ContainsFunc(l.streams, func(sc jetstream.StreamConfig) bool { return sc.Name == name }) {
+			continue
+		}
+		stream, err := js.Stream(ctx, name)
+		if err != nil {
+			log.Err(err).Str("stream", name).Msg("nats failed Stream")
+			return err
+		}
+		pending, err := pendingMsgs(ctx, stream)
+		if err != nil {
+			log.Err(err).Str("stream", name).Msg("nats failed Consumer Info")
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("%w: stream %s of previous layout has %d undelivered messages, "+
+				"restore the layout until they are delivered or remove them with /reset-nats",
+				ErrNATS, name, pending)
+		}
+		ze := log.Warn().Str("stream", name)
+		if info, err := stream.Info(ctx); err == nil {
+			ze = ze.Uint64("droppedMsgs", info.State.Msgs)
+		}
+		if err := js.DeleteStream(ctx, name); err != nil {
+			log.Err(err).Str("stream", name).Msg("nats failed DeleteStream")
+			return err
+		}
+		ze.Msg("nats deleted stream not matching configured streams")
+	}
+	return nil
+}
+
+// pendingMsgs returns the number of messages not delivered by stream consumers
+func pendingMsgs(ctx context.Context, stream jetstream.Stream) (uint64, error) {
+	var pending uint64
+	consumers := stream.ListConsumers(ctx)
+	for info := range consumers.Info() {
+		pending += info.NumPending + uint64(info.NumAckPending)
+	}
+	return pending, consumers.Err()
+}
 
+func defineStream(ctx context.Context, js jetstream.JetStream, sc jetstream.StreamConfig) error {
 	fn, fnDesc := js.UpdateStream, "UpdateStream"
-	if stream, err := js.Stream(ctx, streamName); err == nil {
+	if stream, err := js.Stream(ctx, sc.Name); err == nil {
 		if info, err := stream.Info(ctx); err == nil {
 			if equalStreamConfig(sc, info.Config) {
 				return nil
@@ -196,17 +463,17 @@ func defineStream(ctx context.Context, nc *nats.Conn) error {
 		return err
 	}
 
-	_, err = fn(ctx, sc)
+	_, err := fn(ctx, sc)
 	if err == nil {
 		return nil
-	} else if !isJSStorageErr(err) || sc.Storage != jetstream.FileStorage {
+	} else if !isJSStorageErr(err) || sc.Storage != jetstream.FileStorage || s.server == nil {
 		log.Err(err).
 			Str("config", fmt.Sprintf("%+v", sc)).
 			Msgf("nats failed %v", fnDesc)
 		return err
 	}
 
-	/* retry with smaller storage, 5/8 that smaller then 3/4
+	/* retry embedded server with smaller storage, 5/8 that smaller then 3/4
 	NATS Server allows up to 75% of available storage.
 	https://github.com/nats-io/nats-server/blob/v2.9.19/server/disk_avail.go */
 	u, errUsage := disk.Usage(s.config.StoreDir)
@@ -249,7 +516,10 @@ func equalStreamConfig(c1, c2 jetstream.StreamConfig) bool {
 	return c1.MaxAge == c2.MaxAge &&
 		c1.MaxBytes == c2.MaxBytes &&
 		c1.MaxMsgs == c2.MaxMsgs &&
-		c1.Storage == c2.Storage
+		c1.MaxMsgsPerSubject == c2.MaxMsgsPerSubject &&
+		c1.Storage == c2.Storage &&
+		c1.Replicas == c2.Replicas &&
+		slices.Equal(c1.Subjects, c2.Subjects)
 }
 
 func isJSStorageErr(err error) bool {
@@ -295,6 +565,7 @@ func StopServer() {
 		s.server.Shutdown()
 		s.server = nil
 	}
+	s.url, s.opts = "", nil
 	log.Info().Msg("nats stopped")
 	xClientURL.Set("")
 }
@@ -308,13 +579,13 @@ func StartDispatcher(options []DurableCfg) error {
 	d.Lock()
 	defer d.Unlock()
 
-	if d.server == nil {
+	if d.url == "" {
 		err := fmt.Errorf("%w: unavailable", ErrNATS)
 		log.Err(err).Msg("nats dispatcher failed")
 		return err
 	}
 	if d.ncDispatcher == nil {
-		nc, err := nats.Connect(s.server.ClientURL())
+		nc, err := connect()
 		if err != nil {
 			log.Err(err).Msg("nats dispatcher failed Connect")
 			return err
@@ -348,8 +619,10 @@ func StopDispatcher() error {
 	var wg sync.WaitGroup
 	if d.ncDispatcher != nil {
 		if js, err := d.ncDispatcher.JetStream(); err == nil {
-			if info, err := js.StreamInfo(streamName); err == nil {
-				ze = ze.Str("streamState", fmt.Sprintf("%+v", info.State))
+			for _, sc := range getLayout().streams {
+				if info, err := js.StreamInfo(sc.Name); err == nil {
+					ze = ze.Str("streamState:"+sc.Name, fmt.Sprintf("%+v", info.State))
+				}
 			}
 		}
 		wg.Add(1)
@@ -372,7 +645,7 @@ func Pub(subj string, data []byte, header http.Header) error {
 		log.Err(err).Msg("nats publisher failed")
 		return err
 	}
-	msg := nats.NewMsg(subj)
+	msg := nats.NewMsg(getLayout().subjPrefix + subj)
 	msg.Data = data
 	maps.Copy(msg.Header, header)
 	// use goroutine as L2 buffer
@@ -394,13 +667,13 @@ func Publish(subj string, data []byte, header http.Header) error {
 	s.Lock()
 	defer s.Unlock()
 
-	if s.server == nil {
+	if s.url == "" {
 		err := fmt.Errorf("%w: unavailable", ErrNATS)
 		log.Err(err).Msg("nats publisher failed")
 		return err
 	}
 	if s.ncPublisher == nil {
-		nc, err := nats.Connect(s.server.ClientURL())
+		nc, err := connect()
 		if err != nil {
 			log.Err(err).Msg("nats publisher failed Connect")
 			return err
@@ -408,18 +681,81 @@ func Publish(subj string, data []byte, header http.Header) error {
 		s.ncPublisher = nc
 	}
 
-	msg := nats.NewMsg(subj)
+	msg := nats.NewMsg(getLayout().subjPrefix + subj)
 	msg.Data = data
 	maps.Copy(msg.Header, header)
 	return s.ncPublisher.PublishMsg(msg)
 }
 
+// StreamInfos returns state of streams
+func StreamInfos(ctx context.Context) ([]*jetstream.StreamInfo, error) {
+	s.Lock()
+	nc := s.ncPublisher
+	s.Unlock()
+
+	if nc == nil {
+		return nil, fmt.Errorf("%w: unavailable", ErrNATS)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, err
+	}
+	l := getLayout()
+	infos := make([]*jetstream.StreamInfo, 0, len(l.streams))
+	for _, sc := range l.streams {
+		stream, err := js.Stream(ctx, sc.Name)
+		if err != nil {
+			return nil, err
+		}
+		info, err := stream.Info(ctx)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// DeleteStreams removes streams and dead-letter stream with their messages,
+// they are defined again on start
+func DeleteStreams(ctx context.Context) error {
+	s.Lock()
+	nc := s.ncPublisher
+	s.Unlock()
+
+	if nc == nil {
+		return fmt.Errorf("%w: unavailable", ErrNATS)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return err
+	}
+	/* streams of previous layout are removed as well */
+	l := getLayout()
+	names, err := ownStreamNames(ctx, js, l)
+	if err != nil {
+		return err
+	}
+	names = append(names, l.dlqStreamName)
+	for _, sc := range l.streams {
+		if !slices.Contains(names, sc.Name) {
+			names = append(names, sc.Name)
+		}
+	}
+	for _, name := range names {
+		if err := js.DeleteStream(ctx, name); err != nil && !errors.Is(err, jetstream.ErrStreamNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
 func IsStartedDispatcher() bool {
 	return s != nil && s.ncDispatcher != nil
 }
 
 func IsStartedServer() bool {
-	return s != nil && s.server != nil
+	return s != nil && s.url != ""
 }
 
 func handlePubchan(ctx context.Context) {
@@ -432,7 +768,7 @@ func handlePubchan(ctx context.Context) {
 				log.Warn().Err(err).
 					Str("header", fmt.Sprintf("%+v", msg.Header)).
 					Msg("nats failed PublishMsg: reconnecting")
-				if nc, err := nats.Connect(s.server.ClientURL()); err == nil {
+				if nc, err := connect(); err == nil {
 					s.ncPublisher = nc
 					if err := s.ncPublisher.PublishMsg(msg); err != nil {
 						log.Warn().Err(err).
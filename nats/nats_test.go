@@ -0,0 +1,300 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	tcgerr "github.com/gwos/tcg/sdk/errors"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+)
+
+func runServer(t *testing.T) *server.Server {
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		NoSigs:    true,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	assert.NoError(t, err)
+	srv.Start()
+	assert.True(t, srv.ReadyForConnections(time.Second*5))
+	return srv
+}
+
+func TestExternalServer(t *testing.T) {
+	srv := runServer(t)
+	defer srv.Shutdown()
+
+	assert.NoError(t, StartServer(Config{
+		MaxPayload:    1024 * 1024,
+		StoreType:     "MEMORY",
+		StoreMaxMsgs:  100,
+		DLQMaxMsgs:    100,
+		URLs:          []string{srv.ClientURL()},
+		StreamName:    "agent1",
+		SubjectPrefix: "agent1",
+	}))
+	defer StopServer()
+	assert.True(t, IsStartedServer())
+
+	ctx := context.Background()
+	assert.NoError(t, Publish("tcg.events", []byte("event"), nil))
+	assert.Eventually(t, func() bool {
+		infos, err := StreamInfos(ctx)
+		return err == nil && len(infos) == 1 &&
+			infos[0].Config.Name == "agent1" && infos[0].State.Msgs == 1
+	}, time.Second*5, time.Millisecond*100)
+
+	js, err := jetstream.New(s.ncPublisher)
+	assert.NoError(t, err)
+	stream, err := js.Stream(ctx, "agent1")
+	assert.NoError(t, err)
+	msg, err := stream.GetLastMsgForSubject(ctx, "agent1.tcg.events")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("event"), msg.Data)
+
+	/* dead-letter keeps the subject without prefix for replay */
+	assert.NoError(t, PutDLQ(ctx, "agent1.tcg.events", []byte("event"), nil))
+	msgs, err := ListDLQ(ctx, 0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "agent1.dlq.tcg.events", msgs[0].Subject)
+		assert.Equal(t, "tcg.events", OriginalSubject(msgs[0]))
+	}
+
+	assert.NoError(t, DeleteStreams(ctx))
+	_, err = js.Stream(ctx, "agent1-dlq")
+	assert.ErrorIs(t, err, jetstream.ErrStreamNotFound)
+}
+
+func TestSeparateStreams(t *testing.T) {
+	srv := runServer(t)
+	defer srv.Shutdown()
+
+	cfg := Config{
+		MaxPayload:    1024 * 1024,
+		StoreType:     "MEMORY",
+		StoreMaxAge:   time.Hour,
+		StoreMaxMsgs:  100,
+		DLQMaxMsgs:    100,
+		URLs:          []string{srv.ClientURL()},
+		StreamName:    "agent1",
+		SubjectPrefix: "agent1",
+	}
+	ctx := context.Background()
+
+	/* agents sharing NATS are separated */
+	assert.ErrorContains(t, StartServer(Config{URLs: cfg.URLs, StreamName: "agent1"}),
+		"stream name and subject prefix are required")
+
+	/* the single stream with undelivered messages is kept on changing the layout */
+	assert.NoError(t, StartServer(cfg))
+	assert.NoError(t, Publish("tcg.metrics", []byte("metrics"), nil))
+	js, err := jetstream.New(s.ncPublisher)
+	assert.NoError(t, err)
+	_, err = js.CreateConsumer(ctx, "agent1", jetstream.ConsumerConfig{Durable: "gw", DeliverPolicy: jetstream.DeliverAllPolicy})
+	assert.NoError(t, err)
+	StopServer()
+
+	layout := []StreamCfg{
+		{Name: "events", Subjects: []string{"tcg.events", "tcg.downtimes"}, MaxAge: time.Hour * 24},
+		{Name: "metrics", Subjects: []string{"tcg.metrics"}, MaxMsgs: 10},
+		{Name: "inventory", Subjects: []string{"tcg.inventory.>"}, MaxMsgsPerSubject: 1},
+	}
+	assert.ErrorContains(t, StartServer(Config{
+		MaxPayload: cfg.MaxPayload, StoreType: cfg.StoreType, URLs: cfg.URLs,
+		StreamName: "agent1", SubjectPrefix: "agent1", Streams: layout,
+	}), "stream agent1 of previous layout has 1 undelivered messages")
+	StopServer()
+
+	/* the single stream is replaced on changing the layout */
+	nc, err := nats.Connect(srv.ClientURL())
+	assert.NoError(t, err)
+	defer nc.Close()
+	js, err = jetstream.New(nc)
+	assert.NoError(t, err)
+	assert.NoError(t, js.DeleteConsumer(ctx, "agent1", "gw"))
+	cfg.Streams = layout
+	assert.NoError(t, StartServer(cfg))
+	StopServer()
+	_, err = js.Stream(ctx, "agent1")
+	assert.ErrorIs(t, err, jetstream.ErrStreamNotFound)
+
+	/* the stream of other agent is not touched */
+	_, err = js.CreateStream(ctx, jetstream.StreamConfig{Name: "other", Subjects: []string{"agent1.tcg.other"}})
+	assert.NoError(t, err)
+	assert.NoError(t, StartServer(cfg))
+	defer StopServer()
+	_, err = js.Stream(ctx, "other")
+	assert.NoError(t, err)
+
+	for _, subj := range []string{"tcg.events", "tcg.downtimes", "tcg.inventory.a1", "tcg.inventory.a1", "tcg.inventory.a2"} {
+		assert.NoError(t, Publish(subj, []byte(subj), nil))
+	}
+	for range 12 {
+		assert.NoError(t, Publish("tcg.metrics", []byte("metrics"), nil))
+	}
+	assert.Eventually(t, func() bool {
+		infos, err := StreamInfos(ctx)
+		if err != nil || len(infos) != 3 {
+			return false
+		}
+		return infos[0].Config.Name == "agent1-events" && infos[0].State.Msgs == 2 &&
+			infos[1].Config.Name == "agent1-metrics" && infos[1].State.Msgs == 10 &&
+			infos[2].Config.Name == "agent1-inventory" && infos[2].State.Msgs == 2
+	}, time.Second*5, time.Millisecond*100)
+
+	assert.True(t, SubjectMatch("tcg.>", "tcg.events"))
+	assert.True(t, SubjectMatch("tcg.*", "tcg.events"))
+	assert.False(t, SubjectMatch("tcg.*", "tcg.events.x"))
+	assert.False(t, SubjectMatch("tcg.>", "tcg"))
+	assert.False(t, SubjectMatch("tcg.metrics", "tcg.events"))
+}
+
+func TestInventoryHold(t *testing.T) {
+	srv := runServer(t)
+	defer srv.Shutdown()
+
+	retryDelays, delay := RetryDelays, holdDelay
+	RetryDelays, holdDelay = []time.Duration{time.Millisecond * 300}, time.Millisecond*50
+	defer func() { RetryDelays, holdDelay = retryDelays, delay }()
+
+	assert.NoError(t, StartServer(Config{
+		MaxPayload:    1024 * 1024,
+		StoreType:     "MEMORY",
+		StoreMaxMsgs:  100,
+		DLQMaxMsgs:    100,
+		URLs:          []string{srv.ClientURL()},
+		StreamName:    "agent1",
+		SubjectPrefix: "agent1",
+		Streams: []StreamCfg{
+			{Name: "metrics", Subjects: []string{"tcg.metrics"}},
+			{Name: "inventory", Subjects: []string{"tcg.inventory.>"}, MaxMsgsPerSubject: 1},
+		},
+	}))
+	defer StopServer()
+
+	var mu sync.Mutex
+	var handled []string
+	assert.NoError(t, StartDispatcher([]DurableCfg{{
+		Durable: "gw",
+		Handler: func(_ context.Context, msg jetstream.Msg) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled = append(handled, string(msg.Data()))
+			if len(handled) == 1 && string(msg.Data()) == "inventory" {
+				return fmt.Errorf("%w: gw is down", tcgerr.ErrTransient)
+			}
+			return nil
+		},
+	}}))
+	defer func() { _ = StopDispatcher() }()
+
+	/* metrics wait for the inventory stored before */
+	js, err := jetstream.New(s.ncPublisher)
+	assert.NoError(t, err)
+	_, err = js.Publish(context.Background(), "agent1.tcg.inventory.a1", []byte("inventory"))
+	assert.NoError(t, err)
+	assert.NoError(t, Publish("tcg.metrics", []byte("metrics"), nil))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 3
+	}, time.Second*5, time.Millisecond*50)
+	assert.Equal(t, []string{"inventory", "inventory", "metrics"}, handled)
+}
+
+func TestInventoryHoldRelease(t *testing.T) {
+	srv := runServer(t)
+	defer srv.Shutdown()
+
+	retryDelays, delay, hMax := RetryDelays, holdDelay, holdMax
+	RetryDelays, holdDelay, holdMax = []time.Duration{time.Millisecond * 100}, time.Millisecond*50, time.Millisecond*300
+	defer func() { RetryDelays, holdDelay, holdMax = retryDelays, delay, hMax }()
+
+	assert.NoError(t, StartServer(Config{
+		MaxPayload:    1024 * 1024,
+		StoreType:     "MEMORY",
+		StoreMaxMsgs:  100,
+		DLQMaxMsgs:    100,
+		URLs:          []string{srv.ClientURL()},
+		StreamName:    "agent1",
+		SubjectPrefix: "agent1",
+		Streams: []StreamCfg{
+			{Name: "metrics", Subjects: []string{"tcg.metrics"}},
+			{Name: "inventory", Subjects: []string{"tcg.inventory.>"}, MaxMsgsPerSubject: 1},
+		},
+	}))
+	defer StopServer()
+
+	var mu sync.Mutex
+	var handled []string
+	assert.NoError(t, StartDispatcher([]DurableCfg{{
+		Durable: "gw",
+		Handler: func(_ context.Context, msg jetstream.Msg) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled = append(handled, string(msg.Data()))
+			if string(msg.Data()) == "inventory" {
+				return fmt.Errorf("%w: gw is down", tcgerr.ErrTransient)
+			}
+			return nil
+		},
+	}}))
+	defer func() { _ = StopDispatcher() }()
+
+	/* stuck inventory holds metrics not longer than holdMax */
+	js, err := jetstream.New(s.ncPublisher)
+	assert.NoError(t, err)
+	_, err = js.Publish(context.Background(), "agent1.tcg.inventory.a1", []byte("inventory"))
+	assert.NoError(t, err)
+	assert.NoError(t, Publish("tcg.metrics", []byte("metrics"), nil))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, h := range handled {
+			if h == "metrics" {
+				return true
+			}
+		}
+		return false
+	}, time.Second*5, time.Millisecond*50)
+}
+
+func TestSetStreamsConcurrent(t *testing.T) {
+	defer setStreams(Config{})
+
+	cfg := Config{StreamName: "agent1", SubjectPrefix: "agent1", Streams: []StreamCfg{
+		{Name: "metrics", Subjects: []string{"tcg.metrics"}},
+		{Name: "inventory", Subjects: []string{"tcg.inventory.>"}},
+	}}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			setStreams(cfg)
+			setStreams(Config{})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			/* layout is read consistently while being replaced */
+			l := getLayout()
+			assert.Len(t, l.streamNames(), len(l.streams))
+			if len(l.streams) > 1 {
+				assert.Equal(t, "agent1.", l.subjPrefix)
+				assert.Equal(t, "agent1-inventory", l.inventoryStream)
+			}
+		}
+	}()
+	wg.Wait()
+}
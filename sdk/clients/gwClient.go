@@ -9,6 +9,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -67,7 +68,7 @@ type GWConnection struct {
 	// used as `url.URL{HostName}`
 	HostName            string `env:"HOSTNAME" yaml:"hostName"`
 	UserName            string `env:"USERNAME" yaml:"userName"`
-	Password            string `env:"PASSWORD" yaml:"password"`
+	Password            string `env:"PASSWORD" yaml:"password" secret:"true"`
 	Enabled             bool   `env:"ENABLED" yaml:"enabled"`
 	IsChild             bool   `env:"ISCHILD" yaml:"isChild"`
 	DisplayName         string `env:"DISPLAYNAME" yaml:"displayName"`
@@ -79,6 +80,33 @@ type GWConnection struct {
 	SendAllInventory    bool   `env:"SENDALLINVENTORY" yaml:"sendAllInventory"`
 	IsDynamicInventory  bool   `env:"ISDYNAMICINVENTORY" yaml:"-"`
 	HTTPEncode          bool   `env:"HTTPENCODE" yaml:"-"`
+	// Routing limits the data sent to connection, sends everything if empty
+	Routing RoutingRules `yaml:"routing,omitempty"`
+}
+
+// RoutingRules defines include and exclude rules
+// the data is sent if it matches any include rule (or there are no include rules)
+// and does not match any exclude rule
+type RoutingRules struct {
+	Include []RoutingRule `yaml:"include,omitempty"`
+	Exclude []RoutingRule `yaml:"exclude,omitempty"`
+}
+
+// IsEmpty checks rules
+func (rr RoutingRules) IsEmpty() bool {
+	return len(rr.Include) == 0 && len(rr.Exclude) == 0
+}
+
+// RoutingRule matches the data if all non-empty fields match,
+// field matches if any of its values match, values support path.Match patterns
+type RoutingRule struct {
+	Hosts []string `yaml:"hosts,omitempty"`
+	// HostGroups membership is learned from inventory,
+	// hosts with unknown membership do not match
+	HostGroups []string `yaml:"hostGroups,omitempty"`
+	Services   []string `yaml:"services,omitempty"`
+	// PayloadTypes accepts values: downtimes, events, inventory, metrics
+	PayloadTypes []string `yaml:"payloadTypes,omitempty"`
 }
 
 // GWHostGroups defines collection
@@ -130,6 +158,27 @@ var tokens = new(sync.Map)
 // connectMu serializes Connect across GWClient instances that share a token key
 var connectMu = new(sync.Map) // tokenKey -> *sync.Mutex
 
+// connectedAt keeps the time of getting token, shared like tokens
+var connectedAt = new(sync.Map) // tokenKey -> time.Time
+
+// acceptEncodings keeps content codings advertised by GroundWork hosts
+var acceptEncodings = new(sync.Map) // HostName -> []string
+
+// errUnsupportedMediaType marks 415 response on rejected Content-Encoding
+var errUnsupportedMediaType = errors.New("unsupported media type")
+
+// parseAcceptEncoding returns codings of Accept-Encoding header value
+func parseAcceptEncoding(s string) []string {
+	var codings []string
+	for _, part := range strings.Split(s, ",") {
+		coding, _, _ := strings.Cut(part, ";")
+		if coding = strings.ToLower(strings.TrimSpace(coding)); coding != "" {
+			codings = append(codings, coding)
+		}
+	}
+	return codings
+}
+
 // tokenKey identifies a shared token in the tokens map
 func (client *GWClient) tokenKey() string {
 	return client.AppName + ";" + client.UserName + ";" + client.HostName
@@ -160,6 +209,7 @@ func (client *GWClient) Connect() error {
 		token, err := client.connectLocal()
 		if err == nil {
 			tokens.Store(k, token)
+			connectedAt.Store(k, time.Now())
 		}
 		return err
 	}
@@ -168,10 +218,20 @@ func (client *GWClient) Connect() error {
 		client.GWConnection.Password)
 	if err == nil {
 		tokens.Store(k, token)
+		connectedAt.Store(k, time.Now())
 	}
 	return err
 }
 
+// ConnectedAt returns the time of the last successful Connect,
+// zero if not connected
+func (client *GWClient) ConnectedAt() time.Time {
+	if v, ok := connectedAt.Load(client.tokenKey()); ok {
+		return v.(time.Time)
+	}
+	return time.Time{}
+}
+
 func (client *GWClient) connectLocal() (string, error) {
 	formValues := map[string]string{
 		"gwos-app-name": client.AppName,
@@ -395,53 +455,69 @@ func (client *GWClient) ValidateToken(appName, apiToken string) error {
 	return err
 }
 
-// SynchronizeInventory calls API
-func (client *GWClient) SynchronizeInventory(ctx context.Context, payload []byte) ([]byte, error) {
-	headers := []string{}
-	encoding := ""
+// encode compresses payload if HTTPEncode is set, returns used encoding:
+// the encoder advertised by GroundWork with Accept-Encoding or gzip
+func (client *GWClient) encode(ctx context.Context, payload []byte) (context.Context, []byte, string, error) {
 	if IsGZipped(payload) {
-		encoding = "gzip"
+		return ctx, payload, "gzip", nil
 	}
-	if client.GWConnection.HTTPEncode && encoding == "" {
-		var buf bytes.Buffer
-		var err error
-		if ctx, err = GZip(ctx, &buf, payload); err != nil {
-			return nil, err
+	if !client.GWConnection.HTTPEncode {
+		return ctx, payload, "", nil
+	}
+	encoding, encode := "gzip", GZip
+	if v, ok := acceptEncodings.Load(client.HostName); ok {
+		for _, enc := range Encoders {
+			if slices.Contains(v.([]string), enc.Name) {
+				encoding, encode = enc.Name, enc.Encode
+				break
+			}
 		}
-		payload = buf.Bytes()
-		encoding = "gzip"
 	}
+	var buf bytes.Buffer
+	var err error
+	if ctx, err = encode(ctx, &buf, payload); err != nil {
+		return ctx, nil, "", err
+	}
+	return ctx, buf.Bytes(), encoding, nil
+}
+
+// sendEncoded sends payload compressed with encode,
+// falls back to gzip if GroundWork rejects the advertised encoding with 415
+func (client *GWClient) sendEncoded(ctx context.Context, httpMethod string, entrypoint GWEntrypoint, queryStr string,
+	payload []byte, additionalHeaders ...string) ([]byte, error) {
+	encCtx, encoded, encoding, err := client.encode(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	headers := slices.Clone(additionalHeaders)
 	if encoding != "" {
 		headers = append(headers, "Content-Encoding", encoding)
 	}
+	response, err := client.SendRequest(encCtx, httpMethod, entrypoint, queryStr, encoded, headers...)
+	if errors.Is(err, errUnsupportedMediaType) && encoding != "" && encoding != "gzip" {
+		sdklog.Logger.LogAttrs(ctx, slog.LevelWarn, "content encoding rejected: falling back to gzip",
+			slog.String("encoding", encoding))
+		acceptEncodings.Delete(client.HostName)
+		return client.sendEncoded(ctx, httpMethod, entrypoint, queryStr, payload, additionalHeaders...)
+	}
+	return response, err
+}
+
+// SynchronizeInventory calls API
+func (client *GWClient) SynchronizeInventory(ctx context.Context, payload []byte) ([]byte, error) {
+	headers := []string{}
 	if client.PrefixResourceNames && client.ResourceNamePrefix != "" {
 		headers = append(headers, "HostNamePrefix", client.ResourceNamePrefix)
 	}
 	mergeParam := make(map[string]string)
 	mergeParam["merge"] = strconv.FormatBool(client.GWConnection.MergeHosts)
-	return client.SendRequest(ctx, http.MethodPost, GWEntrypointSynchronizer, BuildQueryParams(mergeParam),
+	return client.sendEncoded(ctx, http.MethodPost, GWEntrypointSynchronizer, BuildQueryParams(mergeParam),
 		payload, headers...)
 }
 
 // SendResourcesWithMetrics calls API
 func (client *GWClient) SendResourcesWithMetrics(ctx context.Context, payload []byte) ([]byte, error) {
 	headers := []string{}
-	encoding := ""
-	if IsGZipped(payload) {
-		encoding = "gzip"
-	}
-	if client.GWConnection.HTTPEncode && encoding == "" {
-		var buf bytes.Buffer
-		var err error
-		if ctx, err = GZip(ctx, &buf, payload); err != nil {
-			return nil, err
-		}
-		payload = buf.Bytes()
-		encoding = "gzip"
-	}
-	if encoding != "" {
-		headers = append(headers, "Content-Encoding", encoding)
-	}
 	if client.PrefixResourceNames && client.ResourceNamePrefix != "" {
 		headers = append(headers, "HostNamePrefix", client.ResourceNamePrefix)
 	}
@@ -449,7 +525,7 @@ func (client *GWClient) SendResourcesWithMetrics(ctx context.Context, payload []
 	if client.IsDynamicInventory {
 		entrypoint = GWEntrypointMonitoringDyn
 	}
-	return client.SendRequest(ctx, http.MethodPost, entrypoint, "", payload, headers...)
+	return client.sendEncoded(ctx, http.MethodPost, entrypoint, "", payload, headers...)
 }
 
 // ClearInDowntime calls API
@@ -608,6 +684,12 @@ func (client *GWClient) SendRequest(ctx context.Context, httpMethod string, entr
 		sdklog.Logger.LogAttrs(ctx, slog.LevelWarn, "could not send request", req.LogAttrs()...)
 		return nil, eee
 
+	case req.Status == 415:
+		eee := fmt.Errorf("%w: %w: %v", tcgerr.ErrUndecided, errUnsupportedMediaType, string(req.Response))
+		req.Err = eee
+		sdklog.Logger.LogAttrs(ctx, slog.LevelWarn, "could not send request", req.LogAttrs()...)
+		return nil, eee
+
 	case req.Status == 502 || req.Status == 504:
 		eee := fmt.Errorf("%w: %v", tcgerr.ErrGateway, string(req.Response))
 		req.Err = eee
@@ -678,7 +760,15 @@ func (client *GWClient) doReq(ctx context.Context, req *Req, httpMethod string,
 		Form:    form,
 		Payload: payload,
 	}
-	return req.SetClient(HttpClientGW).SendWithContext(ctx)
+	err := req.SetClient(HttpClientGW).SendWithContext(ctx)
+	if err == nil {
+		if v := req.ResponseHeader.Get("Accept-Encoding"); v != "" {
+			acceptEncodings.Store(client.HostName, parseAcceptEncoding(v))
+		} else {
+			acceptEncodings.Delete(client.HostName)
+		}
+	}
+	return err
 }
 
 func (client *GWClient) buildURIs() {
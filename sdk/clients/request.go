@@ -79,6 +79,16 @@ var GZip = func(ctx context.Context, w io.Writer, p []byte) (context.Context, er
 	return ctx, err
 }
 
+// Encoder defines compression for uploads with Content-Encoding
+type Encoder struct {
+	Name   string
+	Encode func(ctx context.Context, w io.Writer, p []byte) (context.Context, error)
+}
+
+// Encoders lists compressions for uploads preferred over gzip, like "zstd",
+// applied if GroundWork advertises support with Accept-Encoding response header (RFC 7694)
+var Encoders []Encoder
+
 // IsGZipped detects if payload was compressed with gzip
 // by magic number: 1st byte is 0x1f and 2nd is 0x8b
 func IsGZipped(p []byte) bool {
@@ -144,6 +154,8 @@ type Req struct {
 	Response []byte
 	Status   int
 	URL      string
+	// ResponseHeader keeps headers of the response
+	ResponseHeader http.Header
 
 	client   *http.Client
 	duration time.Duration
@@ -216,7 +228,7 @@ func (q *Req) SendWithContext(ctx context.Context) error {
 		q.Status, q.Err = -1, err
 		return err
 	}
-	q.Status, q.Response = response.StatusCode, responseBody
+	q.Status, q.Response, q.ResponseHeader = response.StatusCode, responseBody, response.Header
 	return nil
 }
 
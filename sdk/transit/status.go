@@ -0,0 +1,164 @@
+package transit
+
+import (
+	"fmt"
+	"path"
+)
+
+// StatusPolicyType defines the rule of host status rollup
+type StatusPolicyType string
+
+// Status policy types
+const (
+	// StatusPolicyUp keeps host UP regardless of services
+	StatusPolicyUp StatusPolicyType = "up"
+	// StatusPolicyWorstOf takes the worst status of services
+	StatusPolicyWorstOf StatusPolicyType = "worstOf"
+	// StatusPolicyPercentCritical sets host DOWN if the percentage of critical services reached
+	StatusPolicyPercentCritical StatusPolicyType = "percentCritical"
+	// StatusPolicyHostCheck takes the worst status of named host-check services
+	StatusPolicyHostCheck StatusPolicyType = "hostCheck"
+)
+
+// StatusPolicy defines host status rollup
+type StatusPolicy struct {
+	Type StatusPolicyType `json:"type"`
+	// Percent of critical services, used by percentCritical policy
+	Percent float64 `json:"percent,omitempty"`
+	// Services names of host-check services, used by hostCheck policy,
+	// support path.Match patterns
+	Services []string `json:"services,omitempty"`
+}
+
+// DefaultStatusPolicy is used by CalculateResourceStatus
+var DefaultStatusPolicy = StatusPolicy{Type: StatusPolicyWorstOf}
+
+// Validate checks policy type and its parameters,
+// the empty type is valid and means no policy
+func (p StatusPolicy) Validate() error {
+	switch p.Type {
+	case "", StatusPolicyUp, StatusPolicyWorstOf:
+	case StatusPolicyPercentCritical:
+		if p.Percent <= 0 || p.Percent > 100 {
+			return fmt.Errorf("status policy %s: percent should be in range (0, 100]: %v", p.Type, p.Percent)
+		}
+	case StatusPolicyHostCheck:
+		if len(p.Services) == 0 {
+			return fmt.Errorf("status policy %s: services are not set", p.Type)
+		}
+		for _, pattern := range p.Services {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("status policy %s: %w: %q", p.Type, err, pattern)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown status policy type: %q", p.Type)
+	}
+	return nil
+}
+
+// Calculate returns host status with text explaining the rule fired,
+// services with UNKNOWN and PENDING statuses are not taken into account
+func (p StatusPolicy) Calculate(services []MonitoredService) (MonitorStatus, string) {
+	switch p.Type {
+	case StatusPolicyUp:
+		return HostUp, "Host status UP by up policy."
+
+	case StatusPolicyWorstOf:
+		status, svc := worstOf(services)
+		if svc == nil {
+			return status, "Host status UP by worst-of policy: no problem services."
+		}
+		return status, fmt.Sprintf("Host status %s by worst-of policy: service %s is %s.",
+			hostStatusName(status), svc.Name, svc.Status)
+
+	case StatusPolicyPercentCritical:
+		critical, total := 0, 0
+		for _, s := range services {
+			switch s.Status {
+			case ServiceScheduledCritical, ServiceUnscheduledCritical:
+				critical++
+				total++
+			case ServiceOk, ServiceWarning:
+				total++
+			}
+		}
+		percent := 0.0
+		if total > 0 {
+			percent = float64(critical) * 100 / float64(total)
+		}
+		if total > 0 && percent >= p.Percent {
+			return HostUnscheduledDown, fmt.Sprintf(
+				"Host status DOWN by percent-critical policy: %d of %d services critical (%.0f%% >= %.0f%%).",
+				critical, total, percent, p.Percent)
+		}
+		return HostUp, fmt.Sprintf(
+			"Host status UP by percent-critical policy: %d of %d services critical (%.0f%% < %.0f%%).",
+			critical, total, percent, p.Percent)
+
+	case StatusPolicyHostCheck:
+		checks := make([]MonitoredService, 0, len(p.Services))
+		for _, s := range services {
+			for _, pattern := range p.Services {
+				if ok, _ := path.Match(pattern, s.Name); ok {
+					checks = append(checks, s)
+					break
+				}
+			}
+		}
+		if len(checks) == 0 {
+			return HostUp, "Host status UP by host-check policy: no host-check services."
+		}
+		status, svc := worstOf(checks)
+		if svc == nil {
+			return status, fmt.Sprintf("Host status UP by host-check policy: %d host-check services OK.", len(checks))
+		}
+		return status, fmt.Sprintf("Host status %s by host-check policy: service %s is %s.",
+			hostStatusName(status), svc.Name, svc.Status)
+	}
+	return HostUp, ""
+}
+
+// worstOf returns the worst host status with the service that caused it
+func worstOf(services []MonitoredService) (MonitorStatus, *MonitoredService) {
+	rank := func(status MonitorStatus) int {
+		switch status {
+		case ServiceWarning:
+			return 1
+		case ServiceScheduledCritical:
+			return 2
+		case ServiceUnscheduledCritical:
+			return 3
+		}
+		return 0
+	}
+	var worst *MonitoredService
+	for i := range services {
+		if rank(services[i].Status) > 0 &&
+			(worst == nil || rank(services[i].Status) > rank(worst.Status)) {
+			worst = &services[i]
+		}
+	}
+	if worst == nil {
+		return HostUp, nil
+	}
+	switch worst.Status {
+	case ServiceWarning:
+		return HostWarning, worst
+	case ServiceScheduledCritical:
+		return HostScheduledDown, worst
+	}
+	return HostUnscheduledDown, worst
+}
+
+func hostStatusName(status MonitorStatus) string {
+	switch status {
+	case HostUp:
+		return "UP"
+	case HostWarning:
+		return "WARNING"
+	case HostScheduledDown, HostUnscheduledDown:
+		return "DOWN"
+	}
+	return string(status)
+}
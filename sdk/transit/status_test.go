@@ -0,0 +1,69 @@
+package transit
+
+import (
+	"testing"
+)
+
+func TestStatusPolicy_Calculate(t *testing.T) {
+	services := []MonitoredService{
+		{BaseInfo: BaseInfo{Name: "cpu"}, MonitoredInfo: MonitoredInfo{Status: ServiceWarning}},
+		{BaseInfo: BaseInfo{Name: "disk"}, MonitoredInfo: MonitoredInfo{Status: ServiceUnscheduledCritical}},
+		{BaseInfo: BaseInfo{Name: "ping"}, MonitoredInfo: MonitoredInfo{Status: ServiceOk}},
+		{BaseInfo: BaseInfo{Name: "mem"}, MonitoredInfo: MonitoredInfo{Status: ServiceUnknown}},
+	}
+
+	tests := []struct {
+		name   string
+		policy StatusPolicy
+		status MonitorStatus
+		text   string
+	}{
+		{"up", StatusPolicy{Type: StatusPolicyUp}, HostUp, "Host status UP by up policy."},
+		{"worstOf", StatusPolicy{Type: StatusPolicyWorstOf}, HostUnscheduledDown,
+			"Host status DOWN by worst-of policy: service disk is SERVICE_UNSCHEDULED_CRITICAL."},
+		{"percentCritical below", StatusPolicy{Type: StatusPolicyPercentCritical, Percent: 50}, HostUp,
+			"Host status UP by percent-critical policy: 1 of 3 services critical (33% < 50%)."},
+		{"percentCritical reached", StatusPolicy{Type: StatusPolicyPercentCritical, Percent: 30}, HostUnscheduledDown,
+			"Host status DOWN by percent-critical policy: 1 of 3 services critical (33% >= 30%)."},
+		{"hostCheck ok", StatusPolicy{Type: StatusPolicyHostCheck, Services: []string{"pi*"}}, HostUp,
+			"Host status UP by host-check policy: 1 host-check services OK."},
+		{"hostCheck warning", StatusPolicy{Type: StatusPolicyHostCheck, Services: []string{"ping", "cpu"}}, HostWarning,
+			"Host status WARNING by host-check policy: service cpu is SERVICE_WARNING."},
+		{"hostCheck none", StatusPolicy{Type: StatusPolicyHostCheck, Services: []string{"http"}}, HostUp,
+			"Host status UP by host-check policy: no host-check services."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, text := tt.policy.Calculate(services)
+			if status != tt.status || text != tt.text {
+				t.Errorf("Calculate returned %v %q want %v %q", status, text, tt.status, tt.text)
+			}
+		})
+	}
+
+	if status := CalculateResourceStatus(services); status != HostUnscheduledDown {
+		t.Errorf("CalculateResourceStatus returned %v want %v", status, HostUnscheduledDown)
+	}
+}
+
+func TestStatusPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		policy StatusPolicy
+		valid  bool
+	}{
+		{StatusPolicy{}, true},
+		{StatusPolicy{Type: StatusPolicyWorstOf}, true},
+		{StatusPolicy{Type: "worst"}, false},
+		{StatusPolicy{Type: StatusPolicyPercentCritical}, false},
+		{StatusPolicy{Type: StatusPolicyPercentCritical, Percent: 101}, false},
+		{StatusPolicy{Type: StatusPolicyPercentCritical, Percent: 50}, true},
+		{StatusPolicy{Type: StatusPolicyHostCheck}, false},
+		{StatusPolicy{Type: StatusPolicyHostCheck, Services: []string{"[ping"}}, false},
+		{StatusPolicy{Type: StatusPolicyHostCheck, Services: []string{"ping*"}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate %+v returned %v", tt.policy, err)
+		}
+	}
+}
@@ -724,11 +724,10 @@ type AgentIdentity struct {
 	AppType string `env:"APPTYPE" json:"appType" yaml:"appType"`
 }
 
+// CalculateResourceStatus calculates host status with DefaultStatusPolicy
 func CalculateResourceStatus(services []MonitoredService) MonitorStatus {
-
-	// TODO: implement logic
-
-	return HostUp
+	status, _ := DefaultStatusPolicy.Calculate(services)
+	return status
 }
 
 func CalculateServiceStatus(metrics *[]TimeSeries) (MonitorStatus, error) {
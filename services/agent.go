@@ -8,15 +8,19 @@ import (
 	"errors"
 	"expvar"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/gwos/tcg/codec"
 	"github.com/gwos/tcg/config"
 	"github.com/gwos/tcg/logzer"
 	"github.com/gwos/tcg/nats"
@@ -43,6 +47,7 @@ type AgentService struct {
 	agentStatus *AgentStatus
 	dsClient    clients.DSClient
 	gwClients   []clients.GWClient
+	muClients   sync.RWMutex
 	quitChan    chan struct{}
 	taskQueue   *taskqueue.TaskQueue
 
@@ -53,6 +58,13 @@ type AgentService struct {
 	configHandler func([]byte)
 	exitHandler   func()
 
+	// draining is set on exit to stop accepting data
+	draining atomic.Bool
+
+	// agents holds connectors in multi-connector mode
+	agents   map[string]*agentEntry
+	muAgents sync.RWMutex
+
 	stats *Stats
 }
 
@@ -60,7 +72,9 @@ type taskSubject string
 
 const (
 	taskConfig          taskSubject = "config"
+	taskConfigAgent     taskSubject = "configAgent"
 	taskExit            taskSubject = "exit"
+	taskReload          taskSubject = "reload"
 	taskResetNats       taskSubject = "resetNats"
 	taskStartController taskSubject = "startController"
 	taskStopController  taskSubject = "stopController"
@@ -72,6 +86,7 @@ const (
 
 const (
 	ckTracerToken        = "ckTraceToken"
+	drainCheckInterval   = time.Millisecond * 500
 	taskQueueAlarm       = time.Second * 9
 	taskQueueCapacity    = 8
 	traceOnDemandAgentID = "#traceOnDemandAgentID#"
@@ -98,10 +113,19 @@ func isConfiguredAppType(t string) bool {
 	return t != "" && t != traceOnDemandAppType
 }
 
-// isConnectorConfigured reports whether the connector has a usable AgentID and AppType.
+// isConnectorConfigured reports whether the connector has a usable AgentID and AppType,
+// in multi-connector mode reports whether any connector has.
 func (service *AgentService) isConnectorConfigured() bool {
-	return isConfiguredAgentID(service.Connector.AgentID) &&
-		isConfiguredAppType(service.Connector.AppType)
+	if isConfiguredAgentID(service.Connector.AgentID) &&
+		isConfiguredAppType(service.Connector.AppType) {
+		return true
+	}
+	for _, a := range service.Connector.Agents {
+		if isConfiguredAgentID(a.AgentID) && isConfiguredAppType(a.AppType) {
+			return true
+		}
+	}
+	return false
 }
 
 // AllowSignalHandlers defines setting the signal handlers
@@ -148,6 +172,8 @@ func GetAgentService() *AgentService {
 		agentService.initOTEL()
 		agentService.initProM()
 		agentService.handleTasks()
+		agentService.watchConfig()
+		agentService.watchWebhooks()
 		if AllowSignalHandlers {
 			agentService.hookInterrupt()
 		}
@@ -176,32 +202,46 @@ func (service *AgentService) DemandConfig() error {
 	// 	/* expect the config api call */
 	// 	return nil
 	// }
-	if !isConfiguredAgentID(service.AgentID) || len(service.dsClient.HostName) == 0 {
+	agentIDs := make([]string, 0, 1)
+	if isConfiguredAgentID(service.AgentID) {
+		agentIDs = append(agentIDs, service.AgentID)
+	}
+	for _, a := range service.agentList() {
+		if agentID := service.Connector.Agents[a.Name].AgentID; isConfiguredAgentID(agentID) {
+			agentIDs = append(agentIDs, agentID)
+		}
+	}
+	if len(agentIDs) == 0 || len(service.dsClient.HostName) == 0 {
 		log.Info().Msg("config server is not configured")
 		/* expect the config api call */
 		return nil
 	}
 
-	go func() {
-		for i := 0; ; i++ {
-			err := service.dsClient.Reload(service.AgentID)
-			if err == nil {
-				log.Info().Msg("config server found and connected")
-				return
-			}
-			if errors.Is(err, tcgerr.ErrNotFound) {
-				/* agent is not configured in DalekServices (unknown AgentID):
-				stop requesting reload and wait for pushed config via POST /config */
-				log.Warn().Err(err).Msg("connector is not configured in DalekServices, waiting for configuration")
-				return
-			}
-			log.Warn().Err(err).Msg("config server is not available, will retry")
-			time.Sleep(demandConfigBackoff(i))
-		}
-	}()
+	for _, agentID := range agentIDs {
+		go service.demandConfig(agentID)
+	}
 	return nil
 }
 
+func (service *AgentService) demandConfig(agentID string) {
+	for i := 0; ; i++ {
+		err := service.dsClient.Reload(agentID)
+		if err == nil {
+			log.Info().Str("agentID", agentID).Msg("config server found and connected")
+			return
+		}
+		if errors.Is(err, tcgerr.ErrNotFound) {
+			/* agent is not configured in DalekServices (unknown AgentID):
+			stop requesting reload and wait for pushed config via POST /config */
+			log.Warn().Err(err).Str("agentID", agentID).
+				Msg("connector is not configured in DalekServices, waiting for configuration")
+			return
+		}
+		log.Warn().Err(err).Msg("config server is not available, will retry")
+		time.Sleep(demandConfigBackoff(i))
+	}
+}
+
 // MakeTracerContext implements AgentServices.MakeTracerContext interface
 func (service *AgentService) MakeTracerContext() transit.TracerContext {
 	/* combine TraceToken from fixed and incremental parts */
@@ -350,6 +390,7 @@ func (service *AgentService) Stats() AgentStatsExt {
 	return AgentStatsExt{
 		AgentIdentity: service.Connector.AgentIdentity,
 		Stats:         *service.stats,
+		Deliveries:    service.deliveryStats(),
 		LastErrors:    logzer.LastErrors(),
 	}
 }
@@ -372,6 +413,7 @@ func (service *AgentService) handleTasks() {
 	}
 	hTask := func(task *taskqueue.Task) error {
 		service.agentStatus.task = task
+		transport0 := service.agentStatus.Transport.Value()
 		var err error
 
 		defer func() {
@@ -382,8 +424,12 @@ func (service *AgentService) handleTasks() {
 		switch task.Subject {
 		case taskConfig:
 			err = service.config(task.Args[0].([]byte))
+		case taskConfigAgent:
+			err = service.configAgent(task.Args[0].(string), task.Args[1].([]byte))
 		case taskExit:
 			err = service.exit()
+		case taskReload:
+			err = service.reload()
 		case taskResetNats:
 			err = service.resetNats()
 		case taskStartController:
@@ -400,6 +446,24 @@ func (service *AgentService) handleTasks() {
 			err = service.stopTransport()
 		}
 		service.agentStatus.task = nil
+
+		switch task.Subject {
+		case taskConfig:
+			if err == nil {
+				service.notify(EventConfigReceived, "")
+			}
+		case taskConfigAgent:
+			if err == nil {
+				service.notify(EventConfigReceived, fmt.Sprintf("agent: %v", task.Args[0]))
+			}
+		}
+		if transport := service.agentStatus.Transport.Value(); transport != transport0 {
+			if transport == StatusRunning {
+				service.notify(EventTransportStarted, "")
+			} else {
+				service.notify(EventTransportStopped, "")
+			}
+		}
 		return err
 	}
 
@@ -408,7 +472,9 @@ func (service *AgentService) handleTasks() {
 		taskqueue.WithCapacity(taskQueueCapacity),
 		taskqueue.WithHandlers(map[taskqueue.Subject]taskqueue.Handler{
 			taskConfig:          hTask,
+			taskConfigAgent:     hTask,
 			taskExit:            hTask,
+			taskReload:          hTask,
 			taskResetNats:       hTask,
 			taskStartController: hTask,
 			taskStopController:  hTask,
@@ -422,6 +488,13 @@ func (service *AgentService) handleTasks() {
 }
 
 func (service *AgentService) config(data []byte) error {
+	return service.applyConfig(data, config.GetConfig().LoadConnectorDTO, service.configHandler)
+}
+
+// applyConfig loads connector config and restarts services as needed,
+// then calls handler for extended fields
+func (service *AgentService) applyConfig(data []byte,
+	load func([]byte) (*config.ConnectorDTO, error), handler func([]byte)) error {
 	natsChk0, err := service.Connector.Nats.Hashsum()
 	if err != nil {
 		log.Err(err).Msg("error getting nats config checksum")
@@ -436,7 +509,7 @@ func (service *AgentService) config(data []byte) error {
 	// TODO: add logic to avoid processing previous inventory in case of callback fails
 
 	// load general config data
-	if _, err := config.GetConfig().LoadConnectorDTO(data); err != nil {
+	if _, err := load(data); err != nil {
 		log.Err(err).Msg("error on processing connector config")
 		return err
 	}
@@ -447,6 +520,7 @@ func (service *AgentService) config(data []byte) error {
 		Str("AppName", service.AppName).
 		Stringer("BatchEvents", service.BatchEvents).
 		Stringer("BatchMetrics", service.BatchMetrics).
+		Stringer("BatchInventory", service.BatchInventory).
 		Int("BatchMaxBytes", service.BatchMaxBytes).
 		Str("ControllerAddr", service.ControllerAddr).
 		Str("DSClient", service.dsClient.HostName).
@@ -458,6 +532,7 @@ func (service *AgentService) config(data []byte) error {
 
 	// ensure nested services properly initialized
 	GetTransitService().eventsBatcher.Reset(service.Connector.BatchEvents, service.Connector.BatchMaxBytes)
+	GetTransitService().inventoryBatcher.Reset(service.Connector.BatchInventory, service.Connector.BatchMaxBytes)
 	GetTransitService().metricsBatcher.Reset(service.Connector.BatchMetrics, service.Connector.BatchMaxBytes)
 	GetController().authCache.Flush()
 	// flush uploading telemetry and configure provider while processing stopped
@@ -493,37 +568,52 @@ func (service *AgentService) config(data []byte) error {
 
 	if !service.isConnectorConfigured() {
 		GetTransitService().eventsBatcher.Clear()
+		GetTransitService().inventoryBatcher.Clear()
 		GetTransitService().metricsBatcher.Clear()
 		_ = service.resetNats()
 		service.resetErrorLogs()
 	}
 
 	// custom connector may provide additional handler for extended fields
-	service.configHandler(data)
+	handler(data)
 	return nil
 }
 
 func (service *AgentService) exit() error {
+	service.draining.Store(true)
+	defer service.draining.Store(false)
+
+	exitHandlers := []func(){service.exitHandler}
+	for _, a := range service.agentList() {
+		if a.ExitHandler != nil {
+			exitHandlers = append(exitHandlers, a.ExitHandler)
+		}
+	}
+	for _, exitHandler := range exitHandlers {
+		/* wrap exitHandler with recover */
+		c := make(chan struct{}, 1)
+		go func(fn func()) {
+			defer func() {
+				if err := recover(); err != nil {
+					log.Err(err.(error)).Msg("handleExit")
+				}
+				c <- struct{}{}
+			}()
+			fn()
+		}(exitHandler)
+		/* wait for exitHandler done */
+		<-c
+	}
+
+	service.drain()
 	GetTransitService().eventsBatcher.Exit()
+	GetTransitService().inventoryBatcher.Exit()
 	GetTransitService().metricsBatcher.Exit()
 
 	if service.tracerProvider != nil {
 		service.tracerProvider.ForceFlush(context.Background())
 	}
 
-	/* wrap exitHandler with recover */
-	c := make(chan struct{}, 1)
-	go func(fn func()) {
-		defer func() {
-			if err := recover(); err != nil {
-				log.Err(err.(error)).Msg("handleExit")
-			}
-		}()
-		fn()
-		c <- struct{}{}
-	}(service.exitHandler)
-	/* wait for exitHandler done */
-	<-c
 	if err := service.stopController(); err != nil {
 		log.Err(err).Msg("handleExit")
 	}
@@ -538,11 +628,78 @@ func (service *AgentService) exit() error {
 	return nil
 }
 
+// drain flushes batchers and waits for deliveries
+// until durables have no pending messages or DrainTimeout exceeded
+func (service *AgentService) drain() {
+	GetTransitService().eventsBatcher.Batch()
+	GetTransitService().inventoryBatcher.Batch()
+	GetTransitService().metricsBatcher.Batch()
+
+	hosts := service.gwHostNames()
+	if service.Connector.DrainTimeout <= 0 || !nats.IsStartedDispatcher() || len(hosts) == 0 {
+		return
+	}
+	gwHosts := make(map[string]string, len(hosts))
+	durables := make([]string, 0, len(hosts))
+	for _, gwHost := range hosts {
+		durable := gwDurableName(gwHost)
+		gwHosts[durable] = gwHost
+		durables = append(durables, durable)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), service.Connector.DrainTimeout)
+	defer cancel()
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+	for {
+		states, err := nats.DurableStates(ctx, durables)
+		if err == nil && !slices.ContainsFunc(states, func(st nats.DurableState) bool {
+			return st.Pending > 0 || st.AckPending > 0
+		}) {
+			log.Info().Msg("drained deliveries")
+			return
+		}
+		select {
+		case <-ticker.C:
+			continue
+		case <-ctx.Done():
+		}
+		/* log what was left in the stream */
+		ctx, cancel := context.WithTimeout(context.Background(), drainCheckInterval)
+		defer cancel()
+		if states, err = nats.DurableStates(ctx, durables); err != nil {
+			log.Warn().Err(err).Msg("could not drain deliveries")
+			return
+		}
+		for _, st := range states {
+			if st.Pending > 0 || st.AckPending > 0 {
+				log.Warn().
+					Str("gwHost", gwHosts[st.Durable]).
+					Uint64("pending", st.Pending).
+					Int("ackPending", st.AckPending).
+					Stringer("oldestUnackedAge", st.OldestAge).
+					Stringer("drainTimeout", service.Connector.DrainTimeout).
+					Msg("could not drain deliveries in time, messages left in stream")
+			}
+		}
+		return
+	}
+}
+
 func (service *AgentService) resetNats() error {
 	isNatsRunning, isTransportRunning :=
 		service.agentStatus.Nats.Value() == StatusRunning,
 		service.agentStatus.Transport.Value() == StatusRunning
 
+	if len(service.Connector.NatsURLs) != 0 {
+		/* external NATS keeps the agent streams, nothing to remove locally */
+		if err := service.stopTransport(); err != nil {
+			log.Warn().Err(err).Msg("could not stop nats dispatcher")
+		}
+		if err := nats.DeleteStreams(context.Background()); err != nil {
+			log.Warn().Err(err).Msg("could not delete nats streams")
+		}
+	}
 	if err := service.stopNats(); err != nil {
 		log.Warn().Err(err).Msg("could not stop nats")
 	}
@@ -576,6 +733,26 @@ func (service *AgentService) stopController() error {
 }
 
 func (service *AgentService) startNats() error {
+	password, err := config.ResolveSecret(service.Connector.NatsPassword)
+	if err != nil {
+		return err
+	}
+	token, err := config.ResolveSecret(service.Connector.NatsToken)
+	if err != nil {
+		return err
+	}
+	streams := make([]nats.StreamCfg, 0, len(service.Connector.NatsStreams))
+	for _, st := range service.Connector.NatsStreams {
+		streams = append(streams, nats.StreamCfg(st))
+	}
+	/* published subjects not captured by any stream would be lost */
+	for _, subj := range []string{subjDowntimes, subjEvents, subjInventory + ".*", subjMetrics} {
+		if len(streams) != 0 && !slices.ContainsFunc(streams, func(st nats.StreamCfg) bool {
+			return slices.ContainsFunc(st.Subjects, func(filter string) bool { return nats.SubjectMatch(filter, subj) })
+		}) {
+			return fmt.Errorf("%w: subject %s is not covered by natsStreams", tcgerr.ErrNotConfigured, subj)
+		}
+	}
 	return nats.StartServer(nats.Config{
 		AckWait:            service.Connector.NatsAckWait,
 		LogColors:          service.Connector.LogColors,
@@ -588,8 +765,23 @@ func (service *AgentService) startNats() error {
 		StoreMaxAge:        service.Connector.NatsStoreMaxAge,
 		StoreMaxBytes:      service.Connector.NatsStoreMaxBytes,
 		StoreMaxMsgs:       service.Connector.NatsStoreMaxMsgs,
+		DLQMaxMsgs:         service.Connector.NatsDLQMaxMsgs,
 
 		ConfigFile: service.Connector.NatsServerConfigFile,
+
+		URLs:          service.Connector.NatsURLs,
+		CredsFile:     service.Connector.NatsCredsFile,
+		NKeyFile:      service.Connector.NatsNKeyFile,
+		User:          service.Connector.NatsUser,
+		Password:      password,
+		Token:         token,
+		TLSCAFile:     service.Connector.NatsTLSCAFile,
+		TLSCertFile:   service.Connector.NatsTLSCertFile,
+		TLSKeyFile:    service.Connector.NatsTLSKeyFile,
+		StreamName:    service.Connector.NatsStreamName,
+		SubjectPrefix: service.Connector.NatsSubjectPrefix,
+		Replicas:      service.Connector.NatsReplicas,
+		Streams:       streams,
 	})
 }
 
@@ -631,9 +823,32 @@ func (service *AgentService) startTransport() error {
 		log.Warn().Msg("empty GWConnections")
 		return nil
 	}
+	service.muClients.Lock()
 	service.gwClients = gwClients
+	service.muClients.Unlock()
 	/* Process dispatcher */
-	return nats.StartDispatcher(makeSubscriptions(service.gwClients))
+	return nats.StartDispatcher(makeSubscriptions(gwClients))
+}
+
+// gwHostNames returns host names of started GroundWork connections
+func (service *AgentService) gwHostNames() []string {
+	service.muClients.RLock()
+	defer service.muClients.RUnlock()
+	hosts := make([]string, 0, len(service.gwClients))
+	for i := range service.gwClients {
+		hosts = append(hosts, service.gwClients[i].HostName)
+	}
+	return hosts
+}
+
+// gwClient returns the first started GroundWork connection or nil
+func (service *AgentService) gwClient() *clients.GWClient {
+	service.muClients.RLock()
+	defer service.muClients.RUnlock()
+	if len(service.gwClients) == 0 {
+		return nil
+	}
+	return &service.gwClients[0]
 }
 
 func (service *AgentService) stopTransport() error {
@@ -690,7 +905,7 @@ func (service *AgentService) fixTracerContext(payloadJSON []byte) []byte {
 }
 
 // hookInterrupt gracefully handles syscalls
-func (service AgentService) hookInterrupt() {
+func (service *AgentService) hookInterrupt() {
 	c := make(chan os.Signal, 2)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
@@ -732,6 +947,12 @@ func (service *AgentService) initOTEL() {
 		return tracing.HookRequestContext(ctx, req)
 	}
 	clients.GZip = tracing.GZip
+	clients.Encoders = []clients.Encoder{{
+		Name: codec.Zstd,
+		Encode: func(ctx context.Context, w io.Writer, p []byte) (context.Context, error) {
+			return ctx, compress(ctx, codec.Zstd, w, p)
+		},
+	}}
 }
 
 // initProM inits Prometheus metrics
@@ -742,7 +963,12 @@ func (service *AgentService) initProM() {
 	}
 	exports := make(map[string]*prometheus.Desc)
 	expvar.Do(func(kv expvar.KeyValue) {
-		exports[kv.Key] = prometheus.NewDesc("expvar_"+kv.Key, kv.Key, nil, nil)
+		var labels []string
+		if strings.HasPrefix(kv.Key, "tcgDelivery") {
+			/* delivery expvars are maps keyed by GroundWork connection */
+			labels = []string{deliveryLabel}
+		}
+		exports[kv.Key] = prometheus.NewDesc("expvar_"+kv.Key, kv.Key, labels, nil)
 	})
 	expvarCollector := collectors.NewExpvarCollector(exports)
 	if err := prometheus.Register(expvarCollector); err != nil {
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gwos/tcg/config"
+	"github.com/gwos/tcg/sdk/transit"
+	"github.com/rs/zerolog/log"
+)
+
+type ctxAgentKeyType int
+
+const ctxAgent ctxAgentKeyType = iota
+
+// CtxWithAgent marks context with connector name in multi-connector mode,
+// the payloads sent with such context get the identity of connector
+func CtxWithAgent(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, ctxAgent, name)
+}
+
+// AgentFromCtx returns connector name set with CtxWithAgent
+func AgentFromCtx(ctx context.Context) string {
+	name, _ := ctx.Value(ctxAgent).(string)
+	return name
+}
+
+// Agent describes connector run in multi-connector mode,
+// connectors share NATS, transport and controller,
+// but have own identity, config delivery, entrypoints and status
+type Agent struct {
+	Name          string
+	ConfigHandler func([]byte)
+	ExitHandler   func()
+	// Entrypoints are namespaced by connector: /api/v1/<Name>/<URL>
+	Entrypoints []Entrypoint
+}
+
+// AgentInfo describes connector state in multi-connector mode
+type AgentInfo struct {
+	Name string `json:"name"`
+	config.AgentConfig
+	ConfiguredAt time.Time `json:"configuredAt,omitzero"`
+	Status       Status    `json:"connectorStatus"`
+}
+
+type agentEntry struct {
+	Agent
+	configuredAt time.Time
+}
+
+// RegisterAgent adds connector in multi-connector mode,
+// should be called before starting controller to serve its entrypoints
+func (service *AgentService) RegisterAgent(agent Agent) {
+	service.muAgents.Lock()
+	defer service.muAgents.Unlock()
+	if service.agents == nil {
+		service.agents = make(map[string]*agentEntry)
+	}
+	service.agents[agent.Name] = &agentEntry{Agent: agent}
+}
+
+// Agents returns state of connectors in multi-connector mode
+func (service *AgentService) Agents() []AgentInfo {
+	service.muAgents.RLock()
+	defer service.muAgents.RUnlock()
+	infos := make([]AgentInfo, 0, len(service.agents))
+	for name := range service.agents {
+		infos = append(infos, service.agentInfo(name))
+	}
+	slices.SortFunc(infos, func(a, b AgentInfo) int { return strings.Compare(a.Name, b.Name) })
+	return infos
+}
+
+// AgentInfo returns state of connector in multi-connector mode
+func (service *AgentService) AgentInfo(name string) (AgentInfo, bool) {
+	service.muAgents.RLock()
+	defer service.muAgents.RUnlock()
+	if _, ok := service.agents[name]; !ok {
+		return AgentInfo{}, false
+	}
+	return service.agentInfo(name), true
+}
+
+func (service *AgentService) agentInfo(name string) AgentInfo {
+	info := AgentInfo{
+		Name:         name,
+		AgentConfig:  service.Connector.Agents[name],
+		ConfiguredAt: service.agents[name].configuredAt,
+		Status:       StatusStopped,
+	}
+	if task := service.agentStatus.task; task != nil &&
+		task.Subject == taskConfigAgent && task.Args[0] == name {
+		info.Status = StatusProcessing
+	} else if info.Enabled && isConfiguredAgentID(info.AgentID) &&
+		service.agentStatus.Transport.Value() == StatusRunning {
+		info.Status = StatusRunning
+	}
+	return info
+}
+
+func (service *AgentService) agentList() []Agent {
+	service.muAgents.RLock()
+	defer service.muAgents.RUnlock()
+	agents := make([]Agent, 0, len(service.agents))
+	for _, a := range service.agents {
+		agents = append(agents, a.Agent)
+	}
+	slices.SortFunc(agents, func(a, b Agent) int { return strings.Compare(a.Name, b.Name) })
+	return agents
+}
+
+// MakeAgentTracerContext makes tracer context with the identity of connector
+// in multi-connector mode, falls back to MakeTracerContext
+func (service *AgentService) MakeAgentTracerContext(name string) transit.TracerContext {
+	tc := service.MakeTracerContext()
+	if a, ok := service.Connector.Agents[name]; ok &&
+		isConfiguredAgentID(a.AgentID) && isConfiguredAppType(a.AppType) {
+		tc.AgentID, tc.AppType = a.AgentID, a.AppType
+	}
+	return tc
+}
+
+// configAgent processes config of connector in multi-connector mode
+func (service *AgentService) configAgent(name string, data []byte) error {
+	service.muAgents.RLock()
+	entry, ok := service.agents[name]
+	service.muAgents.RUnlock()
+	if !ok {
+		log.Warn().Str("agent", name).Msg("could not process config of unknown connector")
+		return nil
+	}
+
+	handler := entry.ConfigHandler
+	if handler == nil {
+		handler = defaultConfigHandler
+	}
+	load := func(data []byte) (*config.ConnectorDTO, error) {
+		return config.GetConfig().LoadAgentDTO(name, data)
+	}
+	if err := service.applyConfig(data, load, handler); err != nil {
+		return err
+	}
+	service.muAgents.Lock()
+	entry.configuredAt = time.Now()
+	service.muAgents.Unlock()
+	return nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/gwos/tcg/config"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestAgents(t *testing.T) {
+	cfg := *config.GetConfig()
+	t.Cleanup(func() {
+		*config.GetConfig() = cfg
+		GetAgentService().agents = nil
+	})
+
+	/* keep the current config in file to not restart nats */
+	data, err := yaml.Marshal(config.GetConfig())
+	assert.NoError(t, err)
+	tmpFile, err := os.CreateTemp("", "config")
+	assert.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, tmpFile.Close())
+	t.Setenv(config.ConfigEnv, tmpFile.Name())
+
+	var configured []byte
+	agentService := GetAgentService()
+	agentService.RegisterAgent(Agent{Name: "checker", ConfigHandler: func(b []byte) { configured = b }})
+	agentService.RegisterAgent(Agent{Name: "server"})
+
+	dto := []byte(`{"agentId":"CHECKER-ID","appName":"checker","appType":"CHECKER","enabled":true}`)
+	assert.NoError(t, agentService.configAgent("checker", dto))
+	assert.Equal(t, dto, configured)
+	assert.Equal(t, cfg.Connector.AgentID, agentService.Connector.AgentID)
+	assert.Equal(t, "CHECKER-ID", agentService.Connector.Agents["checker"].AgentID)
+	assert.True(t, agentService.Connector.Enabled)
+	assert.True(t, agentService.Connector.IsDynamicInventory)
+
+	infos := agentService.Agents()
+	assert.Len(t, infos, 2)
+	assert.Equal(t, "checker", infos[0].Name)
+	assert.Equal(t, "CHECKER", infos[0].AppType)
+	assert.False(t, infos[0].ConfiguredAt.IsZero())
+	assert.Equal(t, "server", infos[1].Name)
+	assert.True(t, infos[1].ConfiguredAt.IsZero())
+
+	tc := agentService.MakeAgentTracerContext(AgentFromCtx(CtxWithAgent(context.Background(), "checker")))
+	assert.Equal(t, "CHECKER-ID", tc.AgentID)
+	assert.Equal(t, "CHECKER", tc.AppType)
+	tc = agentService.MakeAgentTracerContext("server")
+	assert.Equal(t, agentService.MakeTracerContext().AgentID, tc.AgentID)
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gwos/tcg/logzer"
+	"github.com/rs/zerolog/log"
+)
+
+const ctxKeyPrincipal = "tcg-principal"
+
+// AuditEntry defines record of mutating controller operation
+type AuditEntry struct {
+	Time        time.Time `json:"time"`
+	Principal   string    `json:"principal"`
+	Method      string    `json:"method"`
+	Route       string    `json:"route"`
+	SourceIP    string    `json:"sourceIP"`
+	PayloadHash string    `json:"payloadHash,omitempty"`
+	Status      int       `json:"status"`
+}
+
+// auditLog writes entries into separate rotated file
+var auditLog struct {
+	mu   sync.Mutex
+	file *logzer.LogFile
+}
+
+// writeAudit appends entry as JSON line into AuditLogFile
+func (controller *Controller) writeAudit(entry AuditEntry) error {
+	filePath := controller.Connector.AuditLogFile
+	if filePath == "" {
+		return nil
+	}
+	p, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	auditLog.mu.Lock()
+	defer auditLog.mu.Unlock()
+	if auditLog.file == nil || auditLog.file.FilePath != filePath {
+		if auditLog.file != nil {
+			_ = auditLog.file.Close()
+		}
+		auditLog.file = &logzer.LogFile{FilePath: filePath}
+	}
+	auditLog.file.MaxSize = controller.Connector.LogFileMaxSize
+	auditLog.file.Rotate = controller.Connector.LogFileRotate
+	_, err = auditLog.file.Write(append(p, '\n'))
+	return err
+}
+
+// readAudit returns entries from AuditLogFile and rotated files
+// ordered from old to new, filtered with match, up to last limit entries
+func (controller *Controller) readAudit(match func(AuditEntry) bool, limit int) ([]AuditEntry, error) {
+	filePath := controller.Connector.AuditLogFile
+	if filePath == "" {
+		return nil, fmt.Errorf("audit log is not configured")
+	}
+	paths := make([]string, 0, controller.Connector.LogFileRotate+1)
+	for i := controller.Connector.LogFileRotate; i > 0; i-- {
+		paths = append(paths, fmt.Sprintf("%s.%d", filePath, i))
+	}
+	paths = append(paths, filePath)
+
+	/* prevent reading partially written lines */
+	auditLog.mu.Lock()
+	defer auditLog.mu.Unlock()
+
+	entries := make([]AuditEntry, 0)
+	for _, p := range paths {
+		file, err := os.Open(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var entry AuditEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				log.Warn().Err(err).Str("file", p).Msg("could not parse audit entry")
+				continue
+			}
+			if match(entry) {
+				entries = append(entries, entry)
+			}
+		}
+		err = scanner.Err()
+		_ = file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
+
+// audit records the request with authenticated principal and result status
+func (controller *Controller) audit(c *gin.Context) {
+	if controller.Connector.AuditLogFile == "" {
+		return
+	}
+	var payloadHash string
+	if c.Request.Body != nil {
+		payload, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(payload))
+		sum := sha256.Sum256(payload)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	if !c.IsAborted() {
+		c.Next()
+	}
+	if err := controller.writeAudit(AuditEntry{
+		Time:        time.Now().UTC(),
+		Principal:   c.GetString(ctxKeyPrincipal),
+		Method:      c.Request.Method,
+		Route:       c.FullPath(),
+		SourceIP:    c.ClientIP(),
+		PayloadHash: payloadHash,
+		Status:      c.Writer.Status(),
+	}); err != nil {
+		log.Err(err).Str("url", c.Request.URL.Redacted()).Msg("could not write audit entry")
+	}
+}
+
+// auditRejected records the request rejected on access check
+func (controller *Controller) auditRejected(c *gin.Context) {
+	if controller.Connector.AuditLogFile == "" || !c.IsAborted() {
+		return
+	}
+	if status := c.Writer.Status(); status != http.StatusUnauthorized && status != http.StatusForbidden {
+		return
+	}
+	if err := controller.writeAudit(AuditEntry{
+		Time:      time.Now().UTC(),
+		Principal: c.GetString(ctxKeyPrincipal),
+		Method:    c.Request.Method,
+		Route:     c.FullPath(),
+		SourceIP:  c.ClientIP(),
+		Status:    c.Writer.Status(),
+	}); err != nil {
+		log.Err(err).Str("url", c.Request.URL.Redacted()).Msg("could not write audit entry")
+	}
+}
+
+// @Description The following API endpoint can be used to list audit log of mutating operations.
+// @Tags    agent, connector
+// @Accept  json
+// @Produce json
+// @Success 200 {array} services.AuditEntry
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 500 {string} string "Internal server error"
+// @Router  /audit [get]
+// @Param   principal        query     string     false       "Principal"
+// @Param   route            query     string     false       "Route"
+// @Param   since            query     string     false       "RFC3339 time"
+// @Param   limit            query     int        false       "Max records"
+// @Param   GWOS-APP-NAME    header    string     true        "Auth header"
+// @Param   GWOS-API-TOKEN   header    string     true        "Auth header"
+func (controller *Controller) listAudit(c *gin.Context) {
+	var since time.Time
+	if s := c.Query("since"); s != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, s); err != nil {
+			c.JSON(http.StatusBadRequest, "invalid since")
+			return
+		}
+	}
+	principal, route := c.Query("principal"), c.Query("route")
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	entries, err := controller.readAudit(func(e AuditEntry) bool {
+		return (principal == "" || principal == e.Principal) &&
+			(route == "" || route == e.Route) &&
+			!e.Time.Before(since)
+	}, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
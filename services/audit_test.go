@@ -0,0 +1,105 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gwos/tcg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudit(t *testing.T) {
+	controller := GetController()
+	auditLogFile, rotate := controller.Connector.AuditLogFile, controller.Connector.LogFileRotate
+	defer func() {
+		controller.Connector.AuditLogFile, controller.Connector.LogFileRotate = auditLogFile, rotate
+	}()
+	controller.Connector.AuditLogFile = filepath.Join(t.TempDir(), "audit.log")
+	controller.Connector.LogFileRotate = 2
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	setPrincipal := func(c *gin.Context) { c.Set(ctxKeyPrincipal, "basic:admin") }
+	router.POST("/api/v1/stop", setPrincipal, controller.audit, func(c *gin.Context) {
+		c.Status(http.StatusConflict)
+	})
+	router.POST("/api/v1/downtime-set", setPrincipal, controller.audit, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/api/v1/audit", controller.listAudit)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.RemoteAddr = "10.0.0.1:1000"
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/api/v1/stop", "").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/downtime-set", `{"hosts":[]}`).Code)
+
+	w := do(http.MethodGet, "/api/v1/audit", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var entries []AuditEntry
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "basic:admin", entries[0].Principal)
+		assert.Equal(t, "/api/v1/stop", entries[0].Route)
+		assert.Equal(t, "10.0.0.1", entries[0].SourceIP)
+		assert.Equal(t, http.StatusConflict, entries[0].Status)
+		assert.Equal(t, HashToken(`{"hosts":[]}`), entries[1].PayloadHash)
+	}
+
+	w = do(http.MethodGet, "/api/v1/audit?route=/api/v1/downtime-set", "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	assert.Len(t, entries, 1)
+
+	w = do(http.MethodGet, "/api/v1/audit?since=bad", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAuditRejected(t *testing.T) {
+	controller := GetController()
+	auditLogFile, tokens := controller.Connector.AuditLogFile, controller.Connector.ControllerTokens
+	defer func() {
+		controller.Connector.AuditLogFile, controller.Connector.ControllerTokens = auditLogFile, tokens
+	}()
+	controller.Connector.AuditLogFile = filepath.Join(t.TempDir(), "audit.log")
+	controller.Connector.ControllerTokens = []config.APIToken{
+		{Name: "reader", Hash: HashToken("READ-TOKEN"), Scopes: []string{ScopeReadStats}},
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	controller.registerAPI1(router, "localhost:0", nil)
+
+	for _, tc := range []struct {
+		method, url, token string
+		code               int
+	}{
+		{http.MethodDelete, "/api/v1/dlq", "WRONG-TOKEN", http.StatusUnauthorized},
+		{http.MethodPost, "/api/v1/replay-transit", "READ-TOKEN", http.StatusForbidden},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.url, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, tc.method+" "+tc.url+" "+tc.token)
+	}
+
+	entries, err := controller.readAudit(func(AuditEntry) bool { return true }, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "", entries[0].Principal)
+		assert.Equal(t, "/api/v1/dlq", entries[0].Route)
+		assert.Equal(t, http.StatusUnauthorized, entries[0].Status)
+		assert.Equal(t, "token:reader", entries[1].Principal)
+		assert.Equal(t, "/api/v1/replay-transit", entries[1].Route)
+		assert.Equal(t, http.StatusForbidden, entries[1].Status)
+	}
+}
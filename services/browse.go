@@ -0,0 +1,118 @@
+package services
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	tcgnats "github.com/gwos/tcg/nats"
+	"github.com/gwos/tcg/sdk/clients"
+)
+
+// StreamRecord describes message kept in NATS stream
+type StreamRecord struct {
+	Stream      string              `json:"stream"`
+	Sequence    uint64              `json:"sequence"`
+	Subject     string              `json:"subject"`
+	PayloadType string              `json:"payloadType"`
+	Compressed  string              `json:"compressed,omitempty"`
+	SpanID      string              `json:"spanId,omitempty"`
+	TraceID     string              `json:"traceId,omitempty"`
+	Size        int                 `json:"size"`
+	Timestamp   time.Time           `json:"timestamp"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Payload     json.RawMessage     `json:"payload,omitempty"`
+}
+
+// StreamConsumer describes delivery position of durable in NATS stream
+type StreamConsumer struct {
+	Durable      string `json:"durable"`
+	Stream       string `json:"stream"`
+	DeliveredSeq uint64 `json:"deliveredSeq"`
+	AckFloorSeq  uint64 `json:"ackFloorSeq"`
+	Pending      uint64 `json:"pending"`
+	AckPending   int    `json:"ackPending"`
+}
+
+func makeStreamRecord(stream string, msg *tcgnats.StreamMsg, withPayload bool) (StreamRecord, error) {
+	header := http.Header(msg.Header)
+	rec := StreamRecord{
+		Stream:      stream,
+		Sequence:    msg.Sequence,
+		Subject:     tcgnats.MsgSubject(msg),
+		PayloadType: header.Get(clients.HdrPayloadType),
+		Compressed:  header.Get(clients.HdrCompressed),
+		SpanID:      header.Get(clients.HdrSpanSpanID),
+		TraceID:     header.Get(clients.HdrSpanTraceID),
+		Size:        len(msg.Data),
+		Timestamp:   msg.Time,
+	}
+	data := msg.Data
+	/* legacy flow wraps payload with type and span context */
+	if rec.Compressed == "" && len(data) != 0 {
+		p := natsPayload{}
+		if err := p.Unmarshal(data); err == nil {
+			data = p.Payload
+			rec.PayloadType = p.Type.String()
+			rec.SpanID = p.SpanContext.SpanID().String()
+			rec.TraceID = p.SpanContext.TraceID().String()
+		}
+	}
+	if !withPayload {
+		return rec, nil
+	}
+	rec.Header = header
+	payload, err := decodePayload(data, header)
+	if err != nil {
+		return rec, err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, payload, "", "  "); err == nil {
+		payload = buf.Bytes()
+	}
+	rec.Payload = payload
+	return rec, nil
+}
+
+// ListStreamMsgs returns records of stream messages without payloads,
+// stream is the first one if empty, subject may contain wildcards
+func (service *AgentService) ListStreamMsgs(ctx context.Context, stream, subject string, fromSeq, toSeq uint64, limit int) ([]StreamRecord, error) {
+	stream = cmp.Or(stream, tcgnats.StreamNames()[0])
+	msgs, err := tcgnats.ListMsgs(ctx, stream, subject, fromSeq, toSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]StreamRecord, 0, len(msgs))
+	for _, msg := range msgs {
+		rec, _ := makeStreamRecord(stream, msg, false)
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// GetStreamMsg returns record of stream message with headers and decompressed payload
+func (service *AgentService) GetStreamMsg(ctx context.Context, stream string, seq uint64) (*StreamRecord, error) {
+	stream = cmp.Or(stream, tcgnats.StreamNames()[0])
+	msg, err := tcgnats.GetMsg(ctx, stream, seq)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := makeStreamRecord(stream, msg, true)
+	return &rec, err
+}
+
+// ListStreamConsumers returns delivery positions of durables in streams
+func (service *AgentService) ListStreamConsumers(ctx context.Context) ([]StreamConsumer, error) {
+	states, err := tcgnats.ConsumerStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	consumers := make([]StreamConsumer, 0, len(states))
+	for _, st := range states {
+		consumers = append(consumers, StreamConsumer(st))
+	}
+	return consumers, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gwos/tcg/codec"
+	"github.com/gwos/tcg/config"
+	tcgnats "github.com/gwos/tcg/nats"
+	"github.com/gwos/tcg/sdk/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamBrowser(t *testing.T) {
+	compression := GetAgentService().Connector.NatsCompression
+	t.Cleanup(func() {
+		GetAgentService().Connector.NatsCompression = compression
+		assert.NoError(t, GetAgentService().StopNats())
+		assert.NoError(t, os.RemoveAll(filepath.Join(GetAgentService().Connector.NatsStoreDir, "jetstream")))
+	})
+	GetAgentService().Connector.NatsCompression = []config.CompressionRule{
+		{Subjects: []string{subjMetrics}, Codec: codec.Zstd},
+	}
+
+	ctx := context.Background()
+	assert.NoError(t, GetAgentService().StartNats())
+
+	ctxEvents := clients.CtxWithHeader(ctx, http.Header{clients.HdrPayloadType: {typeEvents.String()}})
+	ctxMetrics := clients.CtxWithHeader(ctx, http.Header{clients.HdrPayloadType: {typeMetrics.String()}})
+	assert.NoError(t, Put2Nats(ctxEvents, subjEvents, []byte(`{"events":[]}`)))
+	assert.NoError(t, Put2Nats(ctxMetrics, subjMetrics, []byte(`{"resources":[{"name":"host"}]}`)))
+
+	var records []StreamRecord
+	assert.Eventually(t, func() bool {
+		var err error
+		records, err = GetAgentService().ListStreamMsgs(ctx, "", "", 0, 0, 100)
+		return err == nil && len(records) == 2
+	}, time.Second*5, time.Millisecond*100)
+	assert.Equal(t, subjEvents, records[0].Subject)
+	assert.Equal(t, typeEvents.String(), records[0].PayloadType)
+	assert.NotEmpty(t, records[0].TraceID)
+	assert.Equal(t, codec.Zstd, records[1].Compressed)
+	assert.Nil(t, records[1].Payload)
+
+	records, err := GetAgentService().ListStreamMsgs(ctx, "", "tcg.metrics", 0, 0, 100)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	record, err := GetAgentService().GetStreamMsg(ctx, "", records[0].Sequence)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"resources\": [\n    {\n      \"name\": \"host\"\n    }\n  ]\n}", string(record.Payload))
+
+	_, err = GetAgentService().ListStreamMsgs(ctx, "unknown", "", 0, 0, 100)
+	assert.ErrorContains(t, err, "unknown stream")
+	_, err = GetAgentService().ListStreamMsgs(ctx, "", "", 0, 0, 0)
+	assert.ErrorContains(t, err, "invalid limit")
+	_, err = GetAgentService().ListStreamMsgs(ctx, "", "", 0, 0, tcgnats.MaxBrowseLimit+1)
+	assert.ErrorContains(t, err, "invalid limit")
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.GET("/api/v1/nats/messages", GetController().listStreamMsgs)
+	for target, code := range map[string]int{
+		"/api/v1/nats/messages?limit=2":    http.StatusOK,
+		"/api/v1/nats/messages?limit=0":    http.StatusBadRequest,
+		"/api/v1/nats/messages?limit=1001": http.StatusBadRequest,
+		"/api/v1/nats/messages?from=x":     http.StatusBadRequest,
+		"/api/v1/nats/messages?to=-1":      http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, code, w.Code, target)
+	}
+
+	/* durables of previous runs are kept in the store */
+	consumers, err := GetAgentService().ListStreamConsumers(ctx)
+	assert.NoError(t, err)
+	for _, c := range consumers {
+		assert.Equal(t, "tcg-stream", c.Stream)
+		assert.LessOrEqual(t, c.AckFloorSeq, c.DeliveredSeq)
+	}
+}
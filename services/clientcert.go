@@ -0,0 +1,72 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/gwos/tcg/config"
+)
+
+// clientTLSConfig returns TLS config verifying client certificates with CA bundle,
+// certificates are optional so other access methods keep working
+func clientTLSConfig(caFile string) (*tls.Config, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  pool,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// clientCertIdentity returns certificate identity for logs and traces
+func clientCertIdentity(cert *x509.Certificate) string {
+	if len(cert.DNSNames) > 0 {
+		return fmt.Sprintf("%s (%s)", cert.Subject.String(), strings.Join(cert.DNSNames, ","))
+	}
+	return cert.Subject.String()
+}
+
+// clientCertSANs returns DNS, email and URI SANs of certificate
+func clientCertSANs(cert *x509.Certificate) []string {
+	sans := slices.Concat(cert.DNSNames, cert.EmailAddresses)
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	return sans
+}
+
+// matchClientCert checks rules for certificate,
+// returns true if any matched rule permits the API path relative to /api/v1
+func matchClientCert(rules []config.ClientCertRule, cert *x509.Certificate, apiPath string) bool {
+	subjects := []string{cert.Subject.String()}
+	if cert.Subject.CommonName != "" {
+		subjects = append(subjects, cert.Subject.CommonName)
+	}
+	sans := clientCertSANs(cert)
+	for _, rule := range rules {
+		if !matchAny(rule.Subjects, subjects...) && !matchAny(rule.SANs, sans...) {
+			continue
+		}
+		for _, p := range rule.Permissions {
+			if p == "*" {
+				return true
+			}
+			if ok, _ := path.Match(p, apiPath); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
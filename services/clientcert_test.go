@@ -0,0 +1,71 @@
+package services
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gwos/tcg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchClientCert(t *testing.T) {
+	apmCert := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "apm-agent", Organization: []string{"GWOS"}},
+		DNSNames: []string{"apm.example.com"},
+	}
+	nscaCert := &x509.Certificate{
+		Subject: pkix.Name{CommonName: "nsca"},
+		URIs:    []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/nsca"}},
+	}
+	otherCert := &x509.Certificate{Subject: pkix.Name{CommonName: "other"}}
+	rules := []config.ClientCertRule{
+		{SANs: []string{"*.example.com"}, Permissions: []string{"/metrics", "/events*"}},
+		{SANs: []string{"spiffe://example.com/nsca"}, Permissions: []string{"/nsca/*"}},
+		{Subjects: []string{"CN=admin,O=GWOS"}, Permissions: []string{"*"}},
+	}
+
+	assert.True(t, matchClientCert(rules, apmCert, "/metrics"))
+	assert.True(t, matchClientCert(rules, apmCert, "/events-ack"))
+	assert.False(t, matchClientCert(rules, apmCert, "/config"))
+	assert.True(t, matchClientCert(rules, nscaCert, "/nsca/check"))
+	assert.False(t, matchClientCert(rules, nscaCert, "/metrics"))
+	assert.False(t, matchClientCert(rules, otherCert, "/metrics"))
+	assert.True(t, matchClientCert(rules,
+		&x509.Certificate{Subject: pkix.Name{CommonName: "admin", Organization: []string{"GWOS"}}}, "/stop"))
+
+	t.Run("checkClientCert", func(t *testing.T) {
+		controller := GetController()
+		rules0 := controller.Connector.ControllerClientCerts
+		controller.Connector.ControllerClientCerts = rules
+		t.Cleanup(func() { controller.Connector.ControllerClientCerts = rules0 })
+
+		/* no matching rule falls through to other methods */
+		for path, allowed := range map[string]bool{
+			"/api/v1/metrics": true,
+			"/api/v1/config":  false,
+		} {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, path, nil)
+			assert.Equal(t, allowed, controller.checkClientCert(c, apmCert), path)
+			assert.False(t, c.IsAborted(), path)
+			_, ok := c.Get(ctxKeyPrincipal)
+			assert.Equal(t, allowed, ok, path)
+		}
+	})
+
+	t.Run("bad CA bundle", func(t *testing.T) {
+		controller := GetController()
+		connector := *controller.Connector
+		t.Cleanup(func() { *controller.Connector = connector })
+		controller.Connector.ControllerCertFile = "cert.pem"
+		controller.Connector.ControllerKeyFile = "key.pem"
+		controller.Connector.ControllerClientCAFile = "/not/existing/ca.pem"
+		assert.ErrorContains(t, controller.startController(), "could not load client CA bundle")
+	})
+}
@@ -2,6 +2,8 @@ package services
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/hex"
 	"encoding/json"
 	"expvar"
@@ -11,6 +13,7 @@ import (
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -21,8 +24,10 @@ import (
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 	"github.com/gwos/tcg/config"
+	tcgnats "github.com/gwos/tcg/nats"
 	tcgerr "github.com/gwos/tcg/sdk/errors"
 	_ "github.com/gwos/tcg/sdk/transit"
+	"github.com/gwos/tcg/taskqueue"
 	"github.com/gwos/tcg/tracing"
 	"github.com/patrickmn/go-cache"
 	"github.com/prometheus/client_golang/prometheus"
@@ -56,6 +61,8 @@ type Entrypoint struct {
 	URL     string
 	Method  string
 	Handler func(c *gin.Context)
+	// Scope limits access with local API tokens, ScopeAdmin if empty
+	Scope string
 }
 
 const startRetryDelay = time.Millisecond * 200
@@ -105,11 +112,23 @@ func (controller *Controller) startController() error {
 	router.Use(gin.Recovery())
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowAllOrigins = true
-	corsConfig.AllowHeaders = []string{"GWOS-APP-NAME", "GWOS-API-TOKEN", "Content-Type"}
+	corsConfig.AllowHeaders = []string{"GWOS-APP-NAME", "GWOS-API-TOKEN", "Authorization", "Content-Type"}
 	router.Use(cors.New(corsConfig))
 	router.Use(sessions.Sessions("tcg-session", cookie.NewStore([]byte("secret"))))
 	controller.registerAPI1(router, addr, controller.entrypoints)
 
+	var tlsConfig *tls.Config
+	if caFile := controller.Connector.ControllerClientCAFile; caFile != "" {
+		if certFile == "" || keyFile == "" {
+			log.Warn().Msg("controller omits client certificates verification on non-TLS")
+		} else {
+			var err error
+			if tlsConfig, err = clientTLSConfig(caFile); err != nil {
+				return fmt.Errorf("controller could not load client CA bundle: %w", err)
+			}
+		}
+	}
+
 	/* set a short timer to wait for http.Server starting */
 	idleTimer := time.NewTimer(startRetryDelay * 2)
 	go func() {
@@ -119,6 +138,7 @@ func (controller *Controller) startController() error {
 			Handler:      router,
 			ReadTimeout:  controller.Connector.ControllerReadTimeout,
 			WriteTimeout: controller.Connector.ControllerWriteTimeout,
+			TLSConfig:    tlsConfig,
 		}
 		lc := net.ListenConfig{
 			Control: func(network, address string, c syscall.RawConn) error {
@@ -202,6 +222,26 @@ func (controller *Controller) stopController() error {
 // @Param   GWOS-APP-NAME    header    string     true        "Auth header"
 // @Param   GWOS-API-TOKEN   header    string     true        "Auth header"
 func (controller *Controller) config(c *gin.Context) {
+	controller.handleConfig(c, "")
+}
+
+// @Description The following API endpoint can be used to configure connector in multi-connector mode.
+// @Tags    agent, connector
+// @Accept  json
+// @Produce json
+// @Success 200
+// @Failure 401 {string} string "Unauthorized"
+// @Router  /{agent}/config [post]
+// @Param   agent            path      string     true        "Connector name"
+// @Param   GWOS-APP-NAME    header    string     true        "Auth header"
+// @Param   GWOS-API-TOKEN   header    string     true        "Auth header"
+func (controller *Controller) agentConfig(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		controller.handleConfig(c, name)
+	}
+}
+
+func (controller *Controller) handleConfig(c *gin.Context, agentName string) {
 	payload, err := c.GetRawData()
 	if err != nil {
 		c.JSON(http.StatusBadRequest, err.Error())
@@ -221,7 +261,12 @@ func (controller *Controller) config(c *gin.Context) {
 		return
 	}
 	/* process payload */
-	task, err := controller.taskQueue.PushAsync(taskConfig, payload)
+	var task *taskqueue.Task
+	if agentName == "" {
+		task, err = controller.taskQueue.PushAsync(taskConfig, payload)
+	} else {
+		task, err = controller.taskQueue.PushAsync(taskConfigAgent, agentName, payload)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, err.Error())
 		return
@@ -623,10 +668,367 @@ func (controller *Controller) version(c *gin.Context) {
 	c.JSON(http.StatusOK, config.GetBuildInfo())
 }
 
+// @Description The following API endpoint can be used to get connectors state in multi-connector mode.
+// @Tags    agent, connector
+// @Accept  json
+// @Produce json
+// @Success 200 {array} services.AgentInfo
+// @Router  /agents [get]
+func (controller *Controller) agents(c *gin.Context) {
+	c.JSON(http.StatusOK, controller.Agents())
+}
+
+// @Description The following API endpoint can be used to get connector status in multi-connector mode.
+// @Tags    agent, connector
+// @Accept  json
+// @Produce json
+// @Success 200 {object} services.ConnectorStatusDTO
+// @Failure 404 {string} string "Not found"
+// @Router  /{agent}/status [get]
+// @Param   agent            path      string     true        "Connector name"
+func (controller *Controller) agentConnectorStatus(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info, ok := controller.AgentInfo(name)
+		if !ok {
+			c.JSON(http.StatusNotFound, "unknown connector")
+			return
+		}
+		statusDTO := ConnectorStatusDTO{Status: info.Status}
+		if task := controller.agentStatus.task; info.Status == StatusProcessing && task != nil {
+			statusDTO.JobID = task.Idx
+		}
+		c.JSON(http.StatusOK, statusDTO)
+	}
+}
+
+// @Description The following API endpoint can be used to get connector identity in multi-connector mode.
+// @Tags    agent, connector
+// @Accept  json
+// @Produce json
+// @Success 200 {object} transit.AgentIdentity
+// @Failure 404 {string} string "Not found"
+// @Router  /{agent}/identity [get]
+// @Param   agent            path      string     true        "Connector name"
+func (controller *Controller) agentIdentityOf(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info, ok := controller.AgentInfo(name)
+		if !ok {
+			c.JSON(http.StatusNotFound, "unknown connector")
+			return
+		}
+		c.JSON(http.StatusOK, info.AgentIdentity)
+	}
+}
+
+// @Description The following API endpoint can be used to list dead-letter messages.
+// @Tags    agent, connector
+// @Accept  json
+// @Produce json
+// @Success 200 {array} services.DLQRecord
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 500 {string} string "Internal server error"
+// @Router  /dlq [get]
+// @Param   from             query     int        false       "Start sequence"
+// @Param   limit            query     int        false       "Max records"
+// @Param   GWOS-APP-NAME    header    string     true        "Auth header"
+// @Param   GWOS-API-TOKEN   header    string     true        "Auth header"
+func (controller *Controller) listDLQ(c *gin.Context) {
+	fromSeq, err := queryUint(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "invalid from")
+		return
+	}
+	limit, err := queryLimit(c, 100, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	records, err := controller.ListDLQ(c.Request.Context(), fromSeq, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
+
+// queryUint parses optional unsigned query parameter, 0 if absent
+func queryUint(c *gin.Context, key string) (uint64, error) {
+	v := c.Query(key)
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(v, 10, 64)
+}
+
+// queryLimit parses positive limit query parameter,
+// returns def if absent, maxLimit 0 means no cap
+func queryLimit(c *gin.Context, def, maxLimit int) (int, error) {
+	v := c.Query("limit")
+	if v == "" {
+		return def, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("invalid limit: %q", v)
+	}
+	if maxLimit > 0 && limit > maxLimit {
+		return 0, fmt.Errorf("invalid limit: %d exceeds %d", limit, maxLimit)
+	}
+	return limit, nil
+}
+
+// @Description The following API endpoint can be used to inspect dead-letter message.
+// @Tags    agent, connector
+// @Accept  json
+// @Produce json
+// @Success 200 {object} services.DLQRecord
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "Not found"
+// @Router  /dlq/{seq} [get]
+// @Param   seq              path      int        true        "Sequence"
+// @Param   GWOS-APP-NAME    header    string     true        "Auth header"
+// @Param   GWOS-API-TOKEN   header    string     true        "Auth header"
+func (controller *Controller) getDLQ(c *gin.Context) {
+	seq, err := strconv.ParseUint(c.Param("seq"), 10, 64)
+	if err != nil || seq == 0 {
+		c.JSON(http.StatusBadRequest, "invalid sequence")
+		return
+	}
+	record, err := controller.GetDLQ(c.Request.Context(), seq)
+	if err != nil {
+		c.JSON(http.StatusNotFound, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// @Description The following API endpoint can be used to replay dead-letter messages.
+// @Description Replays all messages if sequence is omitted.
+// @Description Sends each message to the GroundWork connection it failed on.
+// @Description Replays messages of the given GroundWork connection only if gwHost is set.
+// @Tags    agent, connector
+// @Accept  json
+// @Produce json
+// @Success 200
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 500 {string} string "Internal server error"
+// @Router  /dlq/{seq}/replay [post]
+// @Router  /dlq/replay [post]
+// @Param   seq              path      int        false       "Sequence"
+// @Param   gwHost           query     string     false       "GroundWork connection host name"
+// @Param   GWOS-APP-NAME    header    string     true        "Auth header"
+// @Param   GWOS-API-TOKEN   header    string     true        "Auth header"
+func (controller *Controller) replayDLQ(c *gin.Context) {
+	var seq uint64
+	if p := c.Param("seq"); p != "" {
+		var err error
+		if seq, err = strconv.ParseUint(p, 10, 64); err != nil || seq == 0 {
+			c.JSON(http.StatusBadRequest, "invalid sequence")
+			return
+		}
+	}
+	cnt, err := controller.ReplayDLQ(c.Request.Context(), seq, c.Query("gwHost"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"replayed": cnt, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"replayed": cnt})
+}
+
+// @Description The following API endpoint can be used to purge dead-letter messages.
+// @Description Purges all messages if sequence is omitted.
+// @Tags    agent, connector
+// @Accept  json
+// @Produce json
+// @Success 200
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 500 {string} string "Internal server error"
+// @Router  /dlq/{seq} [delete]
+// @Router  /dlq [delete]
+// @Param   seq              path      int        false       "Sequence"
+// @Param   GWOS-APP-NAME    header    string     true        "Auth header"
+// @Param   GWOS-API-TOKEN   header    string     true        "Auth header"
+func (controller *Controller) purgeDLQ(c *gin.Context) {
+	var seq uint64
+	if p := c.Param("seq"); p != "" {
+		var err error
+		if seq, err = strconv.ParseUint(p, 10, 64); err != nil || seq == 0 {
+			c.JSON(http.StatusBadRequest, "invalid sequence")
+			return
+		}
+	}
+	if err := controller.PurgeDLQ(c.Request.Context(), seq); err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, nil)
+}
+
+// @Description The following API endpoint can be used to list messages kept in NATS stream.
+// @Description Uses the first stream if stream is omitted.
+// @Tags    agent, connector
+// @Accept  json
+// @Produce json
+// @Success 200 {array} services.StreamRecord
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 500 {string} string "Internal server error"
+// @Router  /nats/messages [get]
+// @Param   stream           query     string     false       "Stream name"
+// @Param   subject          query     string     false       "Subject, may contain wildcards"
+// @Param   from             query     int        false       "Start sequence"
+// @Param   to               query     int        false       "End sequence"
+// @Param   limit            query     int        false       "Max records, up to 1000"
+// @Param   GWOS-APP-NAME    header    string     true        "Auth header"
+// @Param   GWOS-API-TOKEN   header    string     true        "Auth header"
+func (controller *Controller) listStreamMsgs(c *gin.Context) {
+	fromSeq, err := queryUint(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "invalid from")
+		return
+	}
+	toSeq, err := queryUint(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "invalid to")
+		return
+	}
+	limit, err := queryLimit(c, 100, tcgnats.MaxBrowseLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	records, err := controller.ListStreamMsgs(c.Request.Context(),
+		c.Query("stream"), c.Query("subject"), fromSeq, toSeq, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
+
+// @Description The following API endpoint can be used to inspect message kept in NATS stream
+// @Description with decoded headers and decompressed payload.
+// @Tags    agent, connector
+// @Accept  json
+// @Produce json
+// @Success 200 {object} services.StreamRecord
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "Not found"
+// @Router  /nats/messages/{seq} [get]
+// @Param   seq              path      int        true        "Sequence"
+// @Param   stream           query     string     false       "Stream name"
+// @Param   GWOS-APP-NAME    header    string     true        "Auth header"
+// @Param   GWOS-API-TOKEN   header    string     true        "Auth header"
+func (controller *Controller) getStreamMsg(c *gin.Context) {
+	seq, err := strconv.ParseUint(c.Param("seq"), 10, 64)
+	if err != nil || seq == 0 {
+		c.JSON(http.StatusBadRequest, "invalid sequence")
+		return
+	}
+	record, err := controller.GetStreamMsg(c.Request.Context(), c.Query("stream"), seq)
+	if err != nil {
+		c.JSON(http.StatusNotFound, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// @Description The following API endpoint can be used to list delivered sequences of durables in NATS streams.
+// @Tags    agent, connector
+// @Accept  json
+// @Produce json
+// @Success 200 {array} services.StreamConsumer
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 500 {string} string "Internal server error"
+// @Router  /nats/consumers [get]
+// @Param   GWOS-APP-NAME    header    string     true        "Auth header"
+// @Param   GWOS-API-TOKEN   header    string     true        "Auth header"
+func (controller *Controller) listStreamConsumers(c *gin.Context) {
+	consumers, err := controller.ListStreamConsumers(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, consumers)
+}
+
+// @Description The following API endpoint can be used to replay payloads exported into ExportTransitDir.
+// @Description Keeps the original order and operation type of exported files.
+// @Description Uses ExportTransitDir if dir is omitted, dir should be inside ExportTransitDir.
+// @Tags    agent, connector
+// @Accept  json
+// @Produce json
+// @Param   options          body      ReplayOptions  false   "Replay options"
+// @Success 200 {object} ReplayResult
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 500 {string} string "Internal server error"
+// @Router  /replay-transit [post]
+// @Param   GWOS-APP-NAME    header    string     true        "Auth header"
+// @Param   GWOS-API-TOKEN   header    string     true        "Auth header"
+func (controller *Controller) replayTransit(c *gin.Context) {
+	payload, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	var opts ReplayOptions
+	if len(payload) != 0 {
+		if err := json.Unmarshal(payload, &opts); err != nil {
+			c.JSON(http.StatusBadRequest, "could not unmarshal replay options")
+			return
+		}
+	}
+	if opts.Dir, err = controller.replayDir(opts.Dir); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	res, err := controller.ReplayTransitDir(c.Request.Context(), opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"replayed": res.Replayed, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// checkDraining rejects writes while draining on exit
+func (controller *Controller) checkDraining(c *gin.Context) {
+	if !GetAgentService().draining.Load() {
+		return
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return
+	}
+	log.Debug().Str("url", c.Request.URL.Redacted()).
+		Msg("rejected write while draining")
+	c.Header("Retry-After", strconv.Itoa(int(controller.Connector.DrainTimeout.Seconds())))
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable,
+		gin.H{"error": "service is draining"})
+}
+
 func (controller *Controller) checkAccess(c *gin.Context) {
-	if len(controller.dsClient.HostName) == 0 && len(controller.gwClients) == 0 {
+	/* record rejected access including tokens out of scope checked next in chain */
+	defer func() {
+		c.Next()
+		controller.auditRejected(c)
+	}()
+
+	/* check local token */
+	if controller.checkToken(c) {
+		return
+	}
+
+	gwClient := controller.gwClient()
+	if len(controller.dsClient.HostName) == 0 && gwClient == nil {
 		log.Info().Str("url", c.Request.URL.Redacted()).
 			Msg("omit access check on empty config")
+		c.Set(ctxKeyPrincipal, "anonymous")
 		return
 	}
 
@@ -635,6 +1037,14 @@ func (controller *Controller) checkAccess(c *gin.Context) {
 	if len(pin) > 0 && pin == c.Request.Header.Get("X-PIN") {
 		log.Debug().Func(func(e *zerolog.Event) { e.Str("url", c.Request.URL.Redacted()) }).
 			Msg("access allowed with X-PIN")
+		c.Set(ctxKeyPrincipal, "pin")
+		return
+	}
+
+	/* check client certificate verified on TLS handshake,
+	falls through to other methods if no rule matches */
+	if c.Request.TLS != nil && len(c.Request.TLS.VerifiedChains) > 0 &&
+		controller.checkClientCert(c, c.Request.TLS.VerifiedChains[0][0]) {
 		return
 	}
 
@@ -660,6 +1070,7 @@ func (controller *Controller) checkAccess(c *gin.Context) {
 				log.Debug().Func(func(e *zerolog.Event) { e.Str("url", c.Request.URL.Redacted()) }).
 					Str("username", username).
 					Msg("access allowed with BASIC")
+				c.Set(ctxKeyPrincipal, "basic:"+username)
 				return
 			}
 			log.Warn().Err(err).Str("url", c.Request.URL.Redacted()).
@@ -669,7 +1080,7 @@ func (controller *Controller) checkAccess(c *gin.Context) {
 				gin.H{"error": err.Error()})
 		}()
 
-		if len(username) == 0 || len(password) == 0 || len(controller.gwClients) == 0 {
+		if len(username) == 0 || len(password) == 0 || gwClient == nil {
 			err = fmt.Errorf("misconfigured BASIC auth")
 			return
 		}
@@ -679,7 +1090,7 @@ func (controller *Controller) checkAccess(c *gin.Context) {
 				/* restrict by mutex for one-thread at one-time */
 				controller.muBASIC.Lock()
 				if _, isCached := controller.authCache.Get(ck); !isCached {
-					if _, err = controller.gwClients[0].AuthenticatePassword(username, password); err == nil {
+					if _, err = gwClient.AuthenticatePassword(username, password); err == nil {
 						err = controller.authCache.Add(ck, true, time.Hour)
 					}
 				}
@@ -698,6 +1109,7 @@ func (controller *Controller) checkAccess(c *gin.Context) {
 			log.Debug().Func(func(e *zerolog.Event) { e.Str("url", c.Request.URL.Redacted()) }).
 				Str("gwosAppName", gwosAppName).
 				Msg("access allowed with GWOS")
+			c.Set(ctxKeyPrincipal, "gwos:"+gwosAppName)
 			return
 		}
 		log.Warn().Err(err).Str("url", c.Request.URL.Redacted()).
@@ -726,45 +1138,98 @@ func (controller *Controller) checkAccess(c *gin.Context) {
 	}
 }
 
+// checkClientCert allows access by ControllerClientCerts rules,
+// the certificate identity is recorded in logs and traces,
+// returns false if no rule matches
+func (controller *Controller) checkClientCert(c *gin.Context, cert *x509.Certificate) bool {
+	identity := clientCertIdentity(cert)
+	apiPath := strings.TrimPrefix(c.Request.URL.Path, "/api/v1")
+	if !matchClientCert(controller.Connector.ControllerClientCerts, cert, apiPath) {
+		log.Debug().Func(func(e *zerolog.Event) { e.Str("url", c.Request.URL.Redacted()) }).
+			Str("clientCert", identity).
+			Msg("no client certificate rule matched, checking other methods")
+		return false
+	}
+	log.Debug().Func(func(e *zerolog.Event) { e.Str("url", c.Request.URL.Redacted()) }).
+		Str("clientCert", identity).
+		Msg("access allowed with client certificate")
+	c.Set(ctxKeyPrincipal, "cert:"+identity)
+
+	/* wrap handler spans with the span recording identity */
+	ctx, span := tracing.StartTraceSpan(c.Request.Context(), "controller", "clientCert")
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+	tracing.EndTraceSpan(span,
+		tracing.TraceAttrStr("clientCert", identity),
+		tracing.TraceAttrEntrypoint(c.FullPath()),
+	)
+	return true
+}
+
 func (controller *Controller) registerAPI1(router *gin.Engine, addr string, entrypoints []Entrypoint) {
 	swaggerURL := ginSwagger.URL("http://" + addr + "/swagger/doc.json")
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, swaggerURL))
 
 	/* private entrypoints */
 	apiV1Group := router.Group("/api/v1")
-	apiV1Group.Use(controller.checkAccess)
-
-	apiV1Group.POST("/config", controller.config)
-	apiV1Group.POST("/downtime-clear", controller.clearInDowntime)
-	apiV1Group.POST("/downtime-set", controller.setInDowntime)
-	apiV1Group.POST("/events", controller.sendEvents)
-	apiV1Group.POST("/events-ack", controller.sendEventsAck)
-	apiV1Group.POST("/events-unack", controller.sendEventsUnack)
-	apiV1Group.POST("/inventory", controller.syncInventory)
-	apiV1Group.POST("/metrics", controller.sendMetrics)
-	apiV1Group.GET("/metrics", controller.listMetrics)
-	apiV1Group.POST("/reset-nats", controller.resetNats)
-	apiV1Group.POST("/start", controller.start)
-	apiV1Group.POST("/stop", controller.stop)
+	limiter := newRequestLimiter(controller.Connector)
+	apiV1Group.Use(controller.checkDraining, limiter.handleIP, controller.checkAccess, limiter.handle)
 
-	for _, entrypoint := range entrypoints {
-		switch entrypoint.Method {
-		case http.MethodGet:
-			apiV1Group.GET(entrypoint.URL, entrypoint.Handler)
-		case http.MethodPost:
-			apiV1Group.POST(entrypoint.URL, entrypoint.Handler)
-		case http.MethodPut:
-			apiV1Group.PUT(entrypoint.URL, entrypoint.Handler)
-		case http.MethodDelete:
-			apiV1Group.DELETE(entrypoint.URL, entrypoint.Handler)
-		}
+	/* route groups limit access with local API tokens by scope */
+	adminGroup := apiV1Group.Group("", requireScope(ScopeAdmin))
+	adminGroup.GET("/audit", controller.listAudit)
+	adminGroup.POST("/config", controller.audit, controller.config)
+	adminGroup.POST("/dlq/replay", controller.audit, controller.replayDLQ)
+	adminGroup.POST("/dlq/:seq/replay", controller.audit, controller.replayDLQ)
+	adminGroup.DELETE("/dlq", controller.audit, controller.purgeDLQ)
+	adminGroup.DELETE("/dlq/:seq", controller.audit, controller.purgeDLQ)
+	adminGroup.POST("/replay-transit", controller.audit, controller.replayTransit)
+	adminGroup.POST("/reset-nats", controller.audit, controller.resetNats)
+	adminGroup.POST("/start", controller.audit, controller.start)
+	adminGroup.POST("/stop", controller.audit, controller.stop)
+
+	downtimesGroup := apiV1Group.Group("", requireScope(ScopeIngestDowntimes))
+	downtimesGroup.POST("/downtime-clear", controller.audit, controller.clearInDowntime)
+	downtimesGroup.POST("/downtime-set", controller.audit, controller.setInDowntime)
+
+	eventsGroup := apiV1Group.Group("", requireScope(ScopeIngestEvents))
+	eventsGroup.POST("/events", controller.sendEvents)
+	eventsGroup.POST("/events-ack", controller.sendEventsAck)
+	eventsGroup.POST("/events-unack", controller.sendEventsUnack)
+
+	apiV1Group.POST("/inventory", requireScope(ScopeIngestInventory), controller.syncInventory)
+	apiV1Group.POST("/metrics", requireScope(ScopeIngestMetrics), controller.sendMetrics)
+
+	readGroup := apiV1Group.Group("", requireScope(ScopeReadStats))
+	readGroup.GET("/dlq", controller.listDLQ)
+	readGroup.GET("/dlq/:seq", controller.getDLQ)
+	readGroup.GET("/nats/consumers", controller.listStreamConsumers)
+	readGroup.GET("/nats/messages", controller.listStreamMsgs)
+	readGroup.GET("/nats/messages/:seq", controller.getStreamMsg)
+	readGroup.GET("/metrics", controller.listMetrics)
+
+	registerEntrypoints(apiV1Group, entrypoints)
+
+	/* connectors entrypoints in multi-connector mode */
+	agents := controller.agentList()
+	for _, a := range agents {
+		agentGroup := apiV1Group.Group("/" + a.Name)
+		agentGroup.POST("/config", requireScope(ScopeAdmin), controller.audit, controller.agentConfig(a.Name))
+		registerEntrypoints(agentGroup, a.Entrypoints)
 	}
 
 	/* public entrypoints */
+	router.GET("/healthz", controller.healthz)
+	router.GET("/readyz", controller.readyz)
 	router.GET("/api/v1/identity", controller.agentIdentity)
 	router.GET("/api/v1/stats", controller.stats)
 	router.GET("/api/v1/status", controller.status)
 	router.GET("/api/v1/version", controller.version)
+	router.GET("/api/v1/agents", controller.agents)
+	for _, a := range agents {
+		router.GET("/api/v1/"+a.Name+"/identity", controller.agentIdentityOf(a.Name))
+		router.GET("/api/v1/"+a.Name+"/status", controller.agentConnectorStatus(a.Name))
+	}
 
 	apiV1Debug := router.Group("/api/v1/debug")
 	apiV1Debug.GET("/config", func(c *gin.Context) {
@@ -798,3 +1263,22 @@ func (controller *Controller) registerAPI1(router *gin.Engine, addr string, entr
 	pprofGroup.GET("/mutex", gin.WrapF(pprof.Handler("mutex").ServeHTTP))
 	pprofGroup.GET("/threadcreate", gin.WrapF(pprof.Handler("threadcreate").ServeHTTP))
 }
+
+func registerEntrypoints(group *gin.RouterGroup, entrypoints []Entrypoint) {
+	for _, entrypoint := range entrypoints {
+		scope := entrypoint.Scope
+		if scope == "" {
+			scope = ScopeAdmin
+		}
+		switch entrypoint.Method {
+		case http.MethodGet:
+			group.GET(entrypoint.URL, requireScope(scope), entrypoint.Handler)
+		case http.MethodPost:
+			group.POST(entrypoint.URL, requireScope(scope), entrypoint.Handler)
+		case http.MethodPut:
+			group.PUT(entrypoint.URL, requireScope(scope), entrypoint.Handler)
+		case http.MethodDelete:
+			group.DELETE(entrypoint.URL, requireScope(scope), entrypoint.Handler)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/gin-gonic/gin"
 	"github.com/gwos/tcg/config"
 	"github.com/stretchr/testify/assert"
 )
@@ -28,6 +29,7 @@ func init() {
 
 func TestController(t *testing.T) {
 	t.Cleanup(func() {
+		assert.NoError(t, GetController().StopNats())
 		assert.NoError(t, os.RemoveAll(filepath.Join(GetController().Connector.NatsStoreDir, "jetstream")))
 		assert.NoError(t, os.RemoveAll(filepath.Join(GetController().Connector.NatsStoreDir, "inventory.json")))
 		assert.NoError(t, os.RemoveAll(filepath.Join(GetController().Connector.NatsStoreDir, "inventory1.json")))
@@ -102,3 +104,23 @@ func TestController(t *testing.T) {
 		assert.Equal(t, http.StatusOK, res.StatusCode, "status code should match the expected response")
 	})
 }
+
+func TestCheckDraining(t *testing.T) {
+	t.Cleanup(func() { GetAgentService().draining.Store(false) })
+
+	check := func(method string) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(method, "/api/v1/events", nil)
+		GetController().checkDraining(c)
+		if c.IsAborted() {
+			return w.Code
+		}
+		return http.StatusOK
+	}
+
+	assert.Equal(t, http.StatusOK, check(http.MethodPost))
+	GetAgentService().draining.Store(true)
+	assert.Equal(t, http.StatusServiceUnavailable, check(http.MethodPost))
+	assert.Equal(t, http.StatusOK, check(http.MethodGet))
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"expvar"
+	"strconv"
+	"sync"
+	"time"
+
+	tcgnats "github.com/gwos/tcg/nats"
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	expvar.Publish("tcgDeliveryPending", expvar.Func(func() any {
+		return deliveryGauge(func(p DeliveryStats) float64 { return float64(p.Pending + uint64(p.AckPending)) })
+	}))
+	expvar.Publish("tcgDeliveryOldestUnackedSeconds", expvar.Func(func() any {
+		return deliveryGauge(func(p DeliveryStats) float64 { return float64(p.OldestUnackedAge) / 1000 })
+	}))
+	expvar.Publish("tcgDeliveryRetry", expvar.Func(func() any {
+		return deliveryGauge(func(p DeliveryStats) float64 { return float64(p.Retry) })
+	}))
+	expvar.Publish("tcgDeliveryLastSuccessAt", expvar.Func(func() any {
+		return deliveryGauge(func(p DeliveryStats) float64 {
+			if p.LastSuccessAt == "" {
+				return 0
+			}
+			v, _ := strconv.ParseInt(p.LastSuccessAt, 10, 64)
+			return float64(v)
+		})
+	}))
+}
+
+// deliveryLabel defines the label of delivery expvars on Prometheus export
+const deliveryLabel = "gwHost"
+
+// deliveryCacheTTL limits requests to NATS on frequent reads of delivery stats
+const deliveryCacheTTL = time.Second
+
+var deliveryCache struct {
+	sync.Mutex
+	ts    time.Time
+	stats []DeliveryStats
+}
+
+// DeliveryStats defines delivery state of GroundWork connection
+type DeliveryStats struct {
+	GWHost string `json:"gwHost"`
+	// Pending is the number of messages not yet delivered
+	Pending uint64 `json:"pending"`
+	// AckPending is the number of messages delivered but not acknowledged
+	AckPending int `json:"ackPending"`
+	// OldestUnackedAge is the age of the oldest undelivered message in millis
+	OldestUnackedAge int64 `json:"oldestUnackedAge"`
+	// Retry is the current step in RetryDelays, -1 if not retrying
+	Retry int `json:"retry"`
+	// RetryDelay is the current delay before retry in millis
+	RetryDelay    int64  `json:"retryDelay,omitempty"`
+	LastError     string `json:"lastError,omitempty"`
+	LastErrorAt   string `json:"lastErrorAt,omitempty"`
+	LastSuccessAt string `json:"lastSuccessAt,omitempty"`
+}
+
+// deliveryStats returns delivery state for configured GroundWork connections
+func (service *AgentService) deliveryStats() []DeliveryStats {
+	deliveryCache.Lock()
+	defer deliveryCache.Unlock()
+	if time.Since(deliveryCache.ts) < deliveryCacheTTL {
+		return deliveryCache.stats
+	}
+
+	hosts := service.gwHostNames()
+	stats := make([]DeliveryStats, 0, len(hosts))
+	if !tcgnats.IsStartedServer() || len(hosts) == 0 {
+		return stats
+	}
+	gwHosts := make(map[string]string, len(hosts))
+	durables := make([]string, 0, len(hosts))
+	for _, gwHost := range hosts {
+		durable := gwDurableName(gwHost)
+		gwHosts[durable] = gwHost
+		durables = append(durables, durable)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	states, err := tcgnats.DurableStates(ctx, durables)
+	if err != nil {
+		log.Warn().Err(err).Msg("could not get delivery stats")
+		return stats
+	}
+	for _, st := range states {
+		/* timestamps should be presented as string of millis like other stats */
+		p := DeliveryStats{
+			GWHost:           gwHosts[st.Durable],
+			Pending:          st.Pending,
+			AckPending:       st.AckPending,
+			OldestUnackedAge: st.OldestAge.Milliseconds(),
+			Retry:            st.Retry,
+			RetryDelay:       st.RetryDelay.Milliseconds(),
+		}
+		if st.LastError != nil {
+			p.LastError = st.LastError.Error()
+			p.LastErrorAt = strconv.FormatInt(st.LastErrorAt.UnixMilli(), 10)
+		}
+		if !st.LastSuccessAt.IsZero() {
+			p.LastSuccessAt = strconv.FormatInt(st.LastSuccessAt.UnixMilli(), 10)
+		}
+		stats = append(stats, p)
+	}
+	deliveryCache.ts, deliveryCache.stats = time.Now(), stats
+	return stats
+}
+
+func deliveryGauge(fn func(DeliveryStats) float64) map[string]float64 {
+	stats := GetAgentService().deliveryStats()
+	m := make(map[string]float64, len(stats))
+	for _, p := range stats {
+		m[p.GWHost] = fn(p)
+	}
+	return m
+}
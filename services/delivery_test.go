@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tcgnats "github.com/gwos/tcg/nats"
+	"github.com/gwos/tcg/sdk/clients"
+	tcgerr "github.com/gwos/tcg/sdk/errors"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryStats(t *testing.T) {
+	gwClients := GetAgentService().gwClients
+	t.Cleanup(func() {
+		GetAgentService().gwClients = gwClients
+		assert.NoError(t, tcgnats.StopDispatcher())
+		assert.NoError(t, GetAgentService().StopNats())
+		assert.NoError(t, os.RemoveAll(filepath.Join(GetAgentService().Connector.NatsStoreDir, "jetstream")))
+	})
+
+	assert.NoError(t, GetAgentService().StartNats())
+	GetAgentService().gwClients = []clients.GWClient{{GWConnection: clients.GWConnection{HostName: "gw-host-1"}}}
+
+	handled := make(chan struct{}, 1)
+	assert.NoError(t, tcgnats.StartDispatcher([]tcgnats.DurableCfg{
+		makeDurable(gwDurableName("gw-host-1"), func(context.Context, jetstream.Msg) error {
+			select {
+			case handled <- struct{}{}:
+			default:
+			}
+			return fmt.Errorf("%w: gw is down", tcgerr.ErrTransient)
+		}),
+	}))
+
+	header := make(http.Header)
+	header.Set(clients.HdrPayloadType, typeEvents.String())
+	assert.NoError(t, tcgnats.Pub(subjEvents, []byte(`{"events":[]}`), header))
+
+	select {
+	case <-handled:
+	case <-time.After(10 * time.Second):
+		t.Fatal("message was not handled")
+	}
+	time.Sleep(deliveryCacheTTL)
+
+	stats := GetAgentService().deliveryStats()
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, "gw-host-1", stats[0].GWHost)
+		assert.Equal(t, uint64(1), stats[0].Pending+uint64(stats[0].AckPending))
+		assert.Positive(t, stats[0].OldestUnackedAge)
+		assert.Equal(t, 0, stats[0].Retry)
+		assert.Contains(t, stats[0].LastError, "gw is down")
+		assert.Empty(t, stats[0].LastSuccessAt)
+	}
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gwos/tcg/codec"
+	tcgnats "github.com/gwos/tcg/nats"
+	"github.com/gwos/tcg/sdk/clients"
+	"github.com/rs/zerolog/log"
+)
+
+// Define dead-letter headers
+// keep the delivery details beside original headers
+const (
+	hdrDLQError  = "Dlq-Error"
+	hdrDLQGWHost = "Dlq-Gw-Host"
+	hdrDLQTime   = "Dlq-Timestamp"
+)
+
+// DLQRecord describes dead-letter message
+type DLQRecord struct {
+	Sequence    uint64              `json:"sequence"`
+	Subject     string              `json:"subject"`
+	PayloadType string              `json:"payloadType"`
+	GWHost      string              `json:"gwHost"`
+	Error       string              `json:"error"`
+	Timestamp   time.Time           `json:"timestamp"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Payload     json.RawMessage     `json:"payload,omitempty"`
+}
+
+func makeDLQRecord(msg *tcgnats.DLQMsg, withPayload bool) (DLQRecord, error) {
+	rec := DLQRecord{
+		Sequence:    msg.Sequence,
+		Subject:     tcgnats.OriginalSubject(msg),
+		PayloadType: msg.Header.Get(clients.HdrPayloadType),
+		GWHost:      msg.Header.Get(hdrDLQGWHost),
+		Error:       msg.Header.Get(hdrDLQError),
+		Timestamp:   msg.Time,
+	}
+	if !withPayload {
+		return rec, nil
+	}
+	rec.Header = msg.Header
+	var err error
+	rec.Payload, err = decodePayload(msg.Data, http.Header(msg.Header))
+	return rec, err
+}
+
+// decodePayload decompresses payload and wraps non-JSON data into JSON string
+func decodePayload(data []byte, header http.Header) (json.RawMessage, error) {
+	if codecName := header.Get(clients.HdrCompressed); codecName != "" {
+		var err error
+		if data, err = codec.Decode(codecName, data); err != nil {
+			return nil, err
+		}
+	}
+	if clients.IsJSON(bytes.TrimSpace(data)) {
+		return data, nil
+	}
+	return json.Marshal(string(data))
+}
+
+// putDLQ moves the undelivered message into dead-letter stream
+func putDLQ(ctx context.Context, gwHost, subj string, data []byte, header http.Header, cause error) {
+	header = header.Clone()
+	header.Set(hdrDLQError, cause.Error())
+	header.Set(hdrDLQGWHost, gwHost)
+	header.Set(hdrDLQTime, time.Now().UTC().Format(time.RFC3339Nano))
+	if err := tcgnats.PutDLQ(ctx, subj, data, header); err != nil {
+		log.Err(err).
+			Str("gwHost", gwHost).
+			Str("subject", subj).
+			Msg("could not put message into dead-letter stream")
+		return
+	}
+	agentService.stats.x.Add("dlq:"+gwHost, 1)
+	log.Info().
+		Str("gwHost", gwHost).
+		Str("subject", subj).
+		Msg("message moved into dead-letter stream")
+}
+
+// ListDLQ returns dead-letter records without payloads
+func (service *AgentService) ListDLQ(ctx context.Context, fromSeq uint64, limit int) ([]DLQRecord, error) {
+	msgs, err := tcgnats.ListDLQ(ctx, fromSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]DLQRecord, 0, len(msgs))
+	for _, msg := range msgs {
+		rec, _ := makeDLQRecord(msg, false)
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// GetDLQ returns dead-letter record with headers and payload
+func (service *AgentService) GetDLQ(ctx context.Context, seq uint64) (*DLQRecord, error) {
+	msg, err := tcgnats.GetDLQ(ctx, seq)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := makeDLQRecord(msg, true)
+	return &rec, err
+}
+
+// PurgeDLQ removes dead-letter records
+// removes all records if seq is 0
+func (service *AgentService) PurgeDLQ(ctx context.Context, seq uint64) error {
+	if seq == 0 {
+		return tcgnats.PurgeDLQ(ctx)
+	}
+	return tcgnats.DeleteDLQ(ctx, seq)
+}
+
+// gwClientByHost returns GroundWork connection by host name, nil if unknown
+func (service *AgentService) gwClientByHost(gwHost string) *clients.GWClient {
+	service.muClients.RLock()
+	defer service.muClients.RUnlock()
+	for i := range service.gwClients {
+		if service.gwClients[i].HostName == gwHost {
+			return &service.gwClients[i]
+		}
+	}
+	return nil
+}
+
+// ReplayDLQ redelivers dead-letter records and removes them on success
+// replays all records if seq is 0,
+// sends each record only to the GroundWork connection it failed on,
+// replays records of the given connection only if gwHost is set
+func (service *AgentService) ReplayDLQ(ctx context.Context, seq uint64, gwHost string) (int, error) {
+	if gwHost != "" && service.gwClientByHost(gwHost) == nil {
+		return 0, fmt.Errorf("%w: unknown GroundWork connection: %v", tcgnats.ErrDLQ, gwHost)
+	}
+
+	var msgs []*tcgnats.DLQMsg
+	if seq == 0 {
+		var err error
+		if msgs, err = tcgnats.ListDLQ(ctx, 0, 0); err != nil {
+			return 0, err
+		}
+	} else {
+		msg, err := tcgnats.GetDLQ(ctx, seq)
+		if err != nil {
+			return 0, err
+		}
+		msgs = append(msgs, msg)
+	}
+
+	cnt := 0
+	for _, msg := range msgs {
+		header := http.Header(msg.Header).Clone()
+		msgHost := header.Get(hdrDLQGWHost)
+		if gwHost != "" && msgHost != gwHost {
+			if seq != 0 {
+				return cnt, fmt.Errorf("%w: record %v belongs to GroundWork connection: %v",
+					tcgnats.ErrDLQ, msg.Sequence, msgHost)
+			}
+			continue
+		}
+		gwClient := service.gwClientByHost(msgHost)
+		if gwClient == nil {
+			if seq != 0 {
+				return cnt, fmt.Errorf("%w: unknown GroundWork connection: %v", tcgnats.ErrDLQ, msgHost)
+			}
+			log.Warn().
+				Uint64("sequence", msg.Sequence).
+				Str("gwHost", msgHost).
+				Msg("could not replay dead-letter message: unknown GroundWork connection")
+			continue
+		}
+		header.Del(hdrDLQError)
+		header.Del(hdrDLQGWHost)
+		header.Del(hdrDLQTime)
+
+		/* the record keeps data as it was in the stream */
+		data, err := decompress(msg.Data, header)
+		if err == nil {
+			err = deliver(ctx, gwClient, data, header)
+		}
+		if err != nil {
+			log.Warn().Err(err).
+				Uint64("sequence", msg.Sequence).
+				Str("gwHost", msgHost).
+				Msg("could not replay dead-letter message")
+			return cnt, err
+		}
+		if err := tcgnats.DeleteDLQ(ctx, msg.Sequence); err != nil {
+			log.Warn().Err(err).
+				Uint64("sequence", msg.Sequence).
+				Msg("could not remove replayed dead-letter message")
+		}
+		cnt++
+	}
+	return cnt, nil
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gwos/tcg/codec"
+	"github.com/gwos/tcg/sdk/clients"
+	tcgerr "github.com/gwos/tcg/sdk/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDLQ(t *testing.T) {
+	gwClients := GetAgentService().gwClients
+	t.Cleanup(func() {
+		GetAgentService().gwClients = gwClients
+		assert.NoError(t, GetAgentService().StopNats())
+		assert.NoError(t, os.RemoveAll(filepath.Join(GetAgentService().Connector.NatsStoreDir, "jetstream")))
+	})
+
+	var mu sync.Mutex
+	received := make(map[string][]string)
+	newServer := func(name string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			received[name] = append(received[name], string(body))
+			mu.Unlock()
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	gw1, gw2 := newServer("gw1"), newServer("gw2")
+	GetAgentService().gwClients = []clients.GWClient{
+		{GWConnection: clients.GWConnection{HostName: gw1.URL}},
+		{GWConnection: clients.GWConnection{HostName: gw2.URL}},
+	}
+
+	ctx := context.Background()
+	assert.NoError(t, GetAgentService().StartNats())
+
+	header := make(http.Header)
+	header.Set(clients.HdrPayloadType, typeInventory.String())
+	cause := fmt.Errorf("%w: bad data", tcgerr.ErrUndecided)
+	putDLQ(ctx, gw1.URL, subjInventory, []byte(`{"resources":[]}`), header, cause)
+	putDLQ(ctx, gw2.URL, subjInventory, []byte(`{"resources":[1]}`), header, cause)
+	putDLQ(ctx, gw1.URL, subjInventory, []byte(`{"resources":[2]}`), header, cause)
+	putDLQ(ctx, "gw-host-gone", subjInventory, []byte(`{"resources":[3]}`), header, cause)
+
+	records, err := GetAgentService().ListDLQ(ctx, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, records, 4)
+	assert.Equal(t, subjInventory, records[0].Subject)
+	assert.Equal(t, gw1.URL, records[0].GWHost)
+	assert.Equal(t, typeInventory.String(), records[0].PayloadType)
+	assert.Contains(t, records[0].Error, "bad data")
+	assert.Nil(t, records[0].Payload)
+
+	record, err := GetAgentService().GetDLQ(ctx, records[1].Sequence)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"resources":[1]}`, string(record.Payload))
+
+	_, err = GetAgentService().ReplayDLQ(ctx, 0, "unknown-gw-host")
+	assert.ErrorContains(t, err, "unknown GroundWork connection")
+	_, err = GetAgentService().ReplayDLQ(ctx, records[1].Sequence, gw1.URL)
+	assert.ErrorContains(t, err, "belongs to GroundWork connection")
+	_, err = GetAgentService().ReplayDLQ(ctx, records[3].Sequence, "")
+	assert.ErrorContains(t, err, "unknown GroundWork connection")
+
+	/* the record goes to the connection it failed on only */
+	cnt, err := GetAgentService().ReplayDLQ(ctx, records[0].Sequence, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+	assert.Equal(t, map[string][]string{"gw1": {`{"resources":[]}`}}, received)
+
+	/* records of other connections are kept */
+	cnt, err = GetAgentService().ReplayDLQ(ctx, 0, gw1.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+	assert.Equal(t, map[string][]string{"gw1": {`{"resources":[]}`, `{"resources":[2]}`}}, received)
+
+	cnt, err = GetAgentService().ReplayDLQ(ctx, 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+	assert.Equal(t, []string{`{"resources":[1]}`}, received["gw2"])
+	assert.Len(t, received["gw1"], 2)
+
+	records, err = GetAgentService().ListDLQ(ctx, 0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "gw-host-gone", records[0].GWHost)
+	}
+
+	/* compressed record is decoded before delivery */
+	var buf bytes.Buffer
+	c, err := codec.Get(codec.Zstd)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Encode(&buf, []byte(`{"resources":[4]}`)))
+	header.Set(clients.HdrCompressed, codec.Zstd)
+	header.Set(clients.HdrPayloadLen, "17")
+	putDLQ(ctx, gw2.URL, subjInventory, buf.Bytes(), header, cause)
+	cnt, err = GetAgentService().ReplayDLQ(ctx, 0, gw2.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+	assert.Equal(t, []string{`{"resources":[1]}`, `{"resources":[4]}`}, received["gw2"])
+
+	assert.NoError(t, GetAgentService().PurgeDLQ(ctx, 0))
+	records, err = GetAgentService().ListDLQ(ctx, 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	for _, query := range []string{"limit=-1", "limit=x", "from=-1"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/dlq?"+query, nil)
+		GetController().listDLQ(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/gwos/tcg/config"
+	"github.com/gwos/tcg/sdk/transit"
+	"github.com/rs/zerolog/log"
+)
+
+// downtimesFile keeps applied downtimes between restarts
+const downtimesFile = "downtimes.json"
+
+// appliedDowntimes holds downtimes applied by SyncExt per agent
+// to detect removed ones on the next sync
+var appliedDowntimes = struct {
+	sync.Mutex
+	loaded bool
+	m      map[string][]transit.Downtime
+}{m: make(map[string][]transit.Downtime)}
+
+type downtimeKey struct {
+	host    string
+	service string
+}
+
+func (service *TransitService) loadAppliedDowntimes() {
+	if appliedDowntimes.loaded {
+		return
+	}
+	appliedDowntimes.loaded = true
+	data, err := os.ReadFile(filepath.Join(service.NatsStoreDir, downtimesFile))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warn().Err(err).Msg("could not read applied downtimes")
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &appliedDowntimes.m); err != nil {
+		log.Warn().Err(err).Msg("could not parse applied downtimes")
+	}
+}
+
+func (service *TransitService) storeAppliedDowntimes() {
+	data, err := json.Marshal(appliedDowntimes.m)
+	if err == nil {
+		_ = os.MkdirAll(service.NatsStoreDir, 0777)
+		err = config.WriteFileAtomic(filepath.Join(service.NatsStoreDir, downtimesFile), data, 0666)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("could not store applied downtimes")
+	}
+}
+
+// syncDowntimes reconciles downtimes collected from extended inventory:
+// sets new ones with SetInDowntime and clears disappeared ones with ClearInDowntime
+func (service *TransitService) syncDowntimes(ctx context.Context, agentID string, dt *transit.Downtimes) error {
+	appliedDowntimes.Lock()
+	defer appliedDowntimes.Unlock()
+	service.loadAppliedDowntimes()
+
+	prev := make(map[downtimeKey]transit.Downtime)
+	for _, d := range appliedDowntimes.m[agentID] {
+		prev[downtimeKey{d.HostName, d.ServiceDescription}] = d
+	}
+	curr := make(map[downtimeKey]transit.Downtime)
+	for _, d := range dt.BizHostServiceInDowntimes {
+		if d.ScheduledDowntimeDepth > 0 {
+			curr[downtimeKey{d.HostName, d.ServiceDescription}] = d
+		}
+	}
+
+	var (
+		setHosts    []string
+		setServices = make(map[string][]string)
+		toClear     transit.Downtimes
+	)
+	for k := range curr {
+		if _, ok := prev[k]; ok {
+			continue
+		}
+		if k.service == "" {
+			setHosts = append(setHosts, k.host)
+		} else {
+			setServices[k.host] = append(setServices[k.host], k.service)
+		}
+	}
+	for k, d := range prev {
+		if _, ok := curr[k]; !ok {
+			toClear.BizHostServiceInDowntimes = append(toClear.BizHostServiceInDowntimes, d)
+		}
+	}
+
+	if len(setHosts) > 0 {
+		slices.Sort(setHosts)
+		if err := service.setInDowntime(ctx, transit.DowntimesRequest{
+			HostNames: setHosts, SetHosts: true,
+		}); err != nil {
+			return err
+		}
+	}
+	for host, svcs := range setServices {
+		slices.Sort(svcs)
+		if err := service.setInDowntime(ctx, transit.DowntimesRequest{
+			HostNames: []string{host}, ServiceDescriptions: svcs, SetServices: true,
+		}); err != nil {
+			return err
+		}
+	}
+	if len(toClear.BizHostServiceInDowntimes) > 0 {
+		payload, err := json.Marshal(toClear)
+		if err != nil {
+			return err
+		}
+		if err := service.ClearInDowntime(ctx, payload); err != nil {
+			return err
+		}
+	}
+
+	if len(setHosts) > 0 || len(setServices) > 0 || len(toClear.BizHostServiceInDowntimes) > 0 {
+		applied := make([]transit.Downtime, 0, len(curr))
+		for _, d := range curr {
+			applied = append(applied, d)
+		}
+		if len(applied) == 0 {
+			delete(appliedDowntimes.m, agentID)
+		} else {
+			appliedDowntimes.m[agentID] = applied
+		}
+		service.storeAppliedDowntimes()
+		log.Info().
+			Str("agentID", agentID).
+			Int("setHosts", len(setHosts)).
+			Int("setServicesHosts", len(setServices)).
+			Int("cleared", len(toClear.BizHostServiceInDowntimes)).
+			Msg("synced downtimes")
+	}
+	return nil
+}
+
+func (service *TransitService) setInDowntime(ctx context.Context, p transit.DowntimesRequest) error {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return service.SetInDowntime(ctx, payload)
+}
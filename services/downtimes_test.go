@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gwos/tcg/sdk/transit"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_syncDowntimes(t *testing.T) {
+	t.Cleanup(func() {
+		appliedDowntimes.m = make(map[string][]transit.Downtime)
+		assert.NoError(t, GetAgentService().StopNats())
+		assert.NoError(t, os.RemoveAll(filepath.Join(GetAgentService().Connector.NatsStoreDir, "jetstream")))
+		assert.NoError(t, os.RemoveAll(filepath.Join(GetAgentService().Connector.NatsStoreDir, downtimesFile)))
+	})
+	appliedDowntimes.loaded = true
+	assert.NoError(t, GetAgentService().StartNats())
+
+	ctx := context.Background()
+	var lastSeq uint64
+	published := func() []*StreamRecord {
+		var records []*StreamRecord
+		assert.Eventually(t, func() bool {
+			list, err := GetAgentService().ListStreamMsgs(ctx, "", subjDowntimes, lastSeq+1, 0, 100)
+			if err != nil || len(list) == 0 {
+				return false
+			}
+			for _, rec := range list {
+				r, err := GetAgentService().GetStreamMsg(ctx, "", rec.Sequence)
+				assert.NoError(t, err)
+				records = append(records, r)
+				lastSeq = rec.Sequence
+			}
+			return true
+		}, time.Second*5, time.Millisecond*100)
+		return records
+	}
+	setReq := func(r *StreamRecord) transit.DowntimesRequest {
+		assert.Equal(t, typeSetInDowntime.String(), r.PayloadType)
+		var p transit.DowntimesRequest
+		assert.NoError(t, json.Unmarshal(r.Payload, &p))
+		return p
+	}
+	clearReq := func(r *StreamRecord) []transit.Downtime {
+		assert.Equal(t, typeClearInDowntime.String(), r.PayloadType)
+		var p transit.Downtimes
+		assert.NoError(t, json.Unmarshal(r.Payload, &p))
+		return p.BizHostServiceInDowntimes
+	}
+
+	dt := transit.Downtimes{BizHostServiceInDowntimes: []transit.Downtime{
+		{EntityType: "HOST", EntityName: "host1", HostName: "host1", ScheduledDowntimeDepth: 1},
+		{EntityType: "SERVICE", EntityName: "host1", HostName: "host1", ServiceDescription: "svc1", ScheduledDowntimeDepth: 1},
+		{EntityType: "HOST", EntityName: "host2", HostName: "host2", ScheduledDowntimeDepth: 0},
+	}}
+	assert.NoError(t, GetTransitService().syncDowntimes(ctx, "agent1", &dt))
+	assert.Len(t, appliedDowntimes.m["agent1"], 2)
+	assert.FileExists(t, filepath.Join(GetAgentService().Connector.NatsStoreDir, downtimesFile))
+	if records := published(); assert.Len(t, records, 2) {
+		assert.Equal(t, transit.DowntimesRequest{HostNames: []string{"host1"}, SetHosts: true}, setReq(records[0]))
+		assert.Equal(t, transit.DowntimesRequest{HostNames: []string{"host1"},
+			ServiceDescriptions: []string{"svc1"}, SetServices: true}, setReq(records[1]))
+	}
+
+	hostDowntime, svcDowntime := dt.BizHostServiceInDowntimes[0], dt.BizHostServiceInDowntimes[1]
+	dt.BizHostServiceInDowntimes = dt.BizHostServiceInDowntimes[:1]
+	assert.NoError(t, GetTransitService().syncDowntimes(ctx, "agent1", &dt))
+	if assert.Len(t, appliedDowntimes.m["agent1"], 1) {
+		assert.Equal(t, "host1", appliedDowntimes.m["agent1"][0].HostName)
+		assert.Empty(t, appliedDowntimes.m["agent1"][0].ServiceDescription)
+	}
+	if records := published(); assert.Len(t, records, 1) {
+		assert.Equal(t, []transit.Downtime{svcDowntime}, clearReq(records[0]))
+	}
+
+	dt.BizHostServiceInDowntimes = nil
+	assert.NoError(t, GetTransitService().syncDowntimes(ctx, "agent1", &dt))
+	assert.NotContains(t, appliedDowntimes.m, "agent1")
+	if records := published(); assert.Len(t, records, 1) {
+		assert.Equal(t, []transit.Downtime{hostDowntime}, clearReq(records[0]))
+	}
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	tcgnats "github.com/gwos/tcg/nats"
+)
+
+// Names of readiness checks
+const (
+	CheckConfig  = "config"
+	CheckNats    = "nats"
+	CheckGWAuth  = "gwAuth"
+	CheckBacklog = "backlog"
+)
+
+// HealthCheck defines result of readiness check
+type HealthCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// Readiness defines result of all readiness checks,
+// Details are reported but do not affect Ready
+type Readiness struct {
+	Ready   bool          `json:"ready"`
+	Checks  []HealthCheck `json:"checks"`
+	Details []HealthCheck `json:"details,omitempty"`
+}
+
+// Readiness checks that TCG can accept data:
+// NATS and stream available and backlog is not too old.
+// Connector config and GroundWork authentication are reported in details
+// as data is buffered in NATS until they come
+func (service *AgentService) Readiness() Readiness {
+	checks := []HealthCheck{
+		service.checkNats(),
+		service.checkBacklog(),
+	}
+	ready := true
+	for _, c := range checks {
+		ready = ready && c.OK
+	}
+	details := []HealthCheck{
+		service.checkConfig(),
+		service.checkGWAuth(),
+	}
+	return Readiness{Ready: ready, Checks: checks, Details: details}
+}
+
+func (service *AgentService) checkConfig() HealthCheck {
+	if !service.isConnectorConfigured() {
+		return HealthCheck{Name: CheckConfig, Message: "connector config is not received"}
+	}
+	return HealthCheck{Name: CheckConfig, OK: true}
+}
+
+func (service *AgentService) checkNats() HealthCheck {
+	if !tcgnats.IsStartedServer() {
+		return HealthCheck{Name: CheckNats, Message: "nats server is not started"}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	infos, err := tcgnats.StreamInfos(ctx)
+	if err != nil {
+		return HealthCheck{Name: CheckNats, Message: fmt.Sprintf("could not get stream info: %v", err)}
+	}
+	msgs := make([]string, 0, len(infos))
+	for _, info := range infos {
+		msg := fmt.Sprintf("stream %s has %d messages, %d bytes", info.Config.Name, info.State.Msgs, info.State.Bytes)
+		if info.Config.MaxBytes > 0 {
+			msg += fmt.Sprintf(", %.1f%% of max bytes", float64(info.State.Bytes)*100/float64(info.Config.MaxBytes))
+		}
+		msgs = append(msgs, msg)
+	}
+	return HealthCheck{Name: CheckNats, OK: true, Message: strings.Join(msgs, "; ")}
+}
+
+func (service *AgentService) checkGWAuth() HealthCheck {
+	service.muClients.RLock()
+	lastAt := make(map[string]time.Time, len(service.gwClients))
+	for i := range service.gwClients {
+		lastAt[service.gwClients[i].HostName] = service.gwClients[i].ConnectedAt()
+	}
+	service.muClients.RUnlock()
+	if len(lastAt) == 0 {
+		return HealthCheck{Name: CheckGWAuth, Message: "groundwork connections are not started"}
+	}
+	maxAge := service.Connector.ReadyMaxAuthAge
+	if maxAge <= 0 {
+		return HealthCheck{Name: CheckGWAuth, OK: true}
+	}
+	/* the token is proven by recent delivery as well */
+	for _, p := range service.deliveryStats() {
+		if ms, err := strconv.ParseInt(p.LastSuccessAt, 10, 64); err == nil {
+			if t := time.UnixMilli(ms); t.After(lastAt[p.GWHost]) {
+				lastAt[p.GWHost] = t
+			}
+		}
+	}
+	for host, t := range lastAt {
+		if time.Since(t) < maxAge {
+			return HealthCheck{Name: CheckGWAuth, OK: true,
+				Message: fmt.Sprintf("%s authenticated at %s", host, t.UTC().Format(time.RFC3339))}
+		}
+	}
+	return HealthCheck{Name: CheckGWAuth,
+		Message: fmt.Sprintf("no groundwork connection authenticated in %s", maxAge)}
+}
+
+func (service *AgentService) checkBacklog() HealthCheck {
+	maxAge := service.Connector.ReadyMaxBacklogAge
+	if maxAge <= 0 {
+		return HealthCheck{Name: CheckBacklog, OK: true}
+	}
+	var oldest time.Duration
+	var gwHost string
+	for _, p := range service.deliveryStats() {
+		if age := time.Duration(p.OldestUnackedAge) * time.Millisecond; age > oldest {
+			oldest, gwHost = age, p.GWHost
+		}
+	}
+	if oldest > maxAge {
+		return HealthCheck{Name: CheckBacklog,
+			Message: fmt.Sprintf("%s backlog age %s exceeds %s", gwHost, oldest.Round(time.Second), maxAge)}
+	}
+	return HealthCheck{Name: CheckBacklog, OK: true}
+}
+
+// @Description The following API endpoint can be used to check the process is alive.
+// @Tags    agent, connector
+// @Produce json
+// @Success 200
+// @Router  /healthz [get]
+func (controller *Controller) healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"alive": true})
+}
+
+// @Description The following API endpoint can be used to check TCG can deliver data.
+// @Tags    agent, connector
+// @Produce json
+// @Success 200 {object} services.Readiness
+// @Failure 503 {object} services.Readiness
+// @Router  /readyz [get]
+func (controller *Controller) readyz(c *gin.Context) {
+	r := controller.Readiness()
+	if !r.Ready {
+		c.JSON(http.StatusServiceUnavailable, r)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
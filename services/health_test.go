@@ -0,0 +1,48 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	controller := GetController()
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.GET("/healthz", controller.healthz)
+	router.GET("/readyz", controller.readyz)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	/* nats is not started in test */
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var r Readiness
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &r))
+	assert.False(t, r.Ready)
+	checks := make(map[string]HealthCheck)
+	for _, c := range r.Checks {
+		checks[c.Name] = c
+	}
+	assert.Len(t, checks, 2)
+	assert.False(t, checks[CheckNats].OK)
+	assert.True(t, checks[CheckBacklog].OK)
+
+	/* config and groundwork auth are reported apart of readiness */
+	details := make(map[string]HealthCheck)
+	for _, c := range r.Details {
+		details[c.Name] = c
+	}
+	assert.Len(t, details, 2)
+	assert.Contains(t, details, CheckConfig)
+	assert.False(t, details[CheckGWAuth].OK)
+}
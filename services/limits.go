@@ -0,0 +1,153 @@
+package services
+
+import (
+	"expvar"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gwos/tcg/config"
+	"github.com/patrickmn/go-cache"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+)
+
+var (
+	xLimitsInFlight            = expvar.NewInt("tcgLimitsInFlight")
+	xLimitsRejectedRate        = expvar.NewInt("tcgLimitsRejectedRate")
+	xLimitsRejectedConcurrency = expvar.NewInt("tcgLimitsRejectedConcurrency")
+	xLimitsRejectedBodySize    = expvar.NewInt("tcgLimitsRejectedBodySize")
+	xLimitsRejectedByClient    = expvar.NewMap("tcgLimitsRejectedByClient")
+	muLimitsRejectedByClient   sync.Mutex
+)
+
+// maxRejectedClients caps the number of keys in tcgLimitsRejectedByClient,
+// rejections of other clients are counted under the "other" key
+const maxRejectedClients = 100
+
+// requestLimiter limits request rate per client, concurrency and body size
+type requestLimiter struct {
+	rateLimit    rate.Limit
+	rateBurst    int
+	maxBodyBytes int64
+
+	mu       sync.Mutex
+	limiters *cache.Cache
+	inFlight chan struct{}
+}
+
+func newRequestLimiter(cfg *config.Connector) *requestLimiter {
+	l := &requestLimiter{
+		rateLimit:    rate.Limit(cfg.ControllerRateLimit),
+		rateBurst:    max(cfg.ControllerRateBurst, 1),
+		maxBodyBytes: cfg.ControllerMaxBodyBytes,
+		limiters:     cache.New(10*time.Minute, time.Minute),
+	}
+	if cfg.ControllerMaxConcurrent > 0 {
+		l.inFlight = make(chan struct{}, cfg.ControllerMaxConcurrent)
+	}
+	return l
+}
+
+// clientKey returns token name if authorized with local API token, or client IP
+func clientKey(c *gin.Context) string {
+	if name := c.GetString(ctxKeyToken); name != "" {
+		return "token:" + name
+	}
+	return c.ClientIP()
+}
+
+func (l *requestLimiter) limiter(key string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if v, ok := l.limiters.Get(key); ok {
+		return v.(*rate.Limiter)
+	}
+	lim := rate.NewLimiter(l.rateLimit, l.rateBurst)
+	l.limiters.SetDefault(key, lim)
+	return lim
+}
+
+// countRejectedByClient counts rejection keeping tcgLimitsRejectedByClient bounded
+func countRejectedByClient(key string) {
+	muLimitsRejectedByClient.Lock()
+	defer muLimitsRejectedByClient.Unlock()
+	if xLimitsRejectedByClient.Get(key) == nil {
+		n := 0
+		xLimitsRejectedByClient.Do(func(expvar.KeyValue) { n++ })
+		if n >= maxRejectedClients {
+			key = "other"
+		}
+	}
+	xLimitsRejectedByClient.Add(key, 1)
+}
+
+func (l *requestLimiter) reject(c *gin.Context, code int, retryAfter time.Duration, counter *expvar.Int, msg string) {
+	key := clientKey(c)
+	counter.Add(1)
+	countRejectedByClient(key)
+	log.Warn().Str("url", c.Request.URL.Redacted()).
+		Str("client", key).
+		Msg(msg)
+	if retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	c.AbortWithStatusJSON(code, gin.H{"error": msg})
+}
+
+// handleIP is a middleware limiting request rate per client IP,
+// should be used before checkAccess to protect authentication
+func (l *requestLimiter) handleIP(c *gin.Context) {
+	if l.rateLimit > 0 {
+		r := l.limiter(c.ClientIP()).Reserve()
+		if delay := r.Delay(); delay > 0 {
+			r.Cancel()
+			l.reject(c, http.StatusTooManyRequests, delay, xLimitsRejectedRate,
+				"request rate limit exceeded")
+		}
+	}
+}
+
+// handle is a middleware applying limits,
+// should be used after checkAccess to get the client token,
+// request rate of token is limited apart of client IP
+func (l *requestLimiter) handle(c *gin.Context) {
+	if l.maxBodyBytes > 0 {
+		if c.Request.ContentLength > l.maxBodyBytes {
+			l.reject(c, http.StatusRequestEntityTooLarge, 0, xLimitsRejectedBodySize,
+				fmt.Sprintf("request body exceeds %d bytes", l.maxBodyBytes))
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, l.maxBodyBytes)
+	}
+
+	if name := c.GetString(ctxKeyToken); name != "" && l.rateLimit > 0 {
+		r := l.limiter("token:" + name).Reserve()
+		if delay := r.Delay(); delay > 0 {
+			r.Cancel()
+			l.reject(c, http.StatusTooManyRequests, delay, xLimitsRejectedRate,
+				"request rate limit exceeded")
+			return
+		}
+	}
+
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+			xLimitsInFlight.Add(1)
+			defer func() {
+				<-l.inFlight
+				xLimitsInFlight.Add(-1)
+			}()
+		default:
+			l.reject(c, http.StatusTooManyRequests, time.Second, xLimitsRejectedConcurrency,
+				"too many concurrent requests")
+			return
+		}
+	}
+	c.Next()
+}
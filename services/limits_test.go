@@ -0,0 +1,107 @@
+package services
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gwos/tcg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLimiter(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	handler := func(c *gin.Context) {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+	do := func(router *gin.Engine, body string, remoteAddr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("rate", func(t *testing.T) {
+		router := gin.New()
+		limiter := newRequestLimiter(&config.Connector{
+			ControllerRateLimit: 0.1,
+			ControllerRateBurst: 2,
+		})
+		router.POST("/test", limiter.handleIP, limiter.handle, handler)
+
+		rejected := xLimitsRejectedRate.Value()
+		assert.Equal(t, http.StatusNoContent, do(router, "", "10.0.0.1:1000").Code)
+		assert.Equal(t, http.StatusNoContent, do(router, "", "10.0.0.1:1000").Code)
+		w := do(router, "", "10.0.0.1:1000")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		assert.Equal(t, rejected+1, xLimitsRejectedRate.Value())
+		/* other client is limited apart */
+		assert.Equal(t, http.StatusNoContent, do(router, "", "10.0.0.2:1000").Code)
+	})
+
+	t.Run("rate by token", func(t *testing.T) {
+		router := gin.New()
+		limiter := newRequestLimiter(&config.Connector{
+			ControllerRateLimit: 0.1,
+			ControllerRateBurst: 1,
+		})
+		setToken := func(c *gin.Context) { c.Set(ctxKeyToken, "t1") }
+		router.POST("/test", setToken, limiter.handle, handler)
+
+		assert.Equal(t, http.StatusNoContent, do(router, "", "10.0.0.1:1000").Code)
+		/* token is limited regardless of client IP */
+		assert.Equal(t, http.StatusTooManyRequests, do(router, "", "10.0.0.2:1000").Code)
+	})
+
+	t.Run("rejected by client", func(t *testing.T) {
+		for i := range maxRejectedClients + 10 {
+			countRejectedByClient(fmt.Sprintf("10.1.%d.%d", i/256, i%256))
+		}
+		n := 0
+		xLimitsRejectedByClient.Do(func(expvar.KeyValue) { n++ })
+		assert.LessOrEqual(t, n, maxRejectedClients+1)
+		assert.NotNil(t, xLimitsRejectedByClient.Get("other"))
+	})
+
+	t.Run("body", func(t *testing.T) {
+		router := gin.New()
+		router.POST("/test", newRequestLimiter(&config.Connector{
+			ControllerMaxBodyBytes: 8,
+		}).handle, handler)
+
+		assert.Equal(t, http.StatusNoContent, do(router, "12345678", "10.0.0.1:1000").Code)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, do(router, "123456789", "10.0.0.1:1000").Code)
+	})
+
+	t.Run("concurrency", func(t *testing.T) {
+		router := gin.New()
+		entered, release := make(chan struct{}), make(chan struct{})
+		router.POST("/test", newRequestLimiter(&config.Connector{
+			ControllerMaxConcurrent: 1,
+		}).handle, func(c *gin.Context) {
+			entered <- struct{}{}
+			<-release
+			c.Status(http.StatusNoContent)
+		})
+
+		done := make(chan int)
+		go func() { done <- do(router, "", "10.0.0.1:1000").Code }()
+		<-entered
+		w := do(router, "", "10.0.0.2:1000")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+		close(release)
+		assert.Equal(t, http.StatusNoContent, <-done)
+	})
+}
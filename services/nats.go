@@ -6,10 +6,13 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"maps"
 	"net/http"
 	"strings"
 
+	"github.com/gwos/tcg/codec"
+	"github.com/gwos/tcg/config"
 	tcgnats "github.com/gwos/tcg/nats"
 	"github.com/gwos/tcg/sdk/clients"
 	tcgerr "github.com/gwos/tcg/sdk/errors"
@@ -39,20 +42,28 @@ func Put2Nats(ctx context.Context, subj string, payload []byte) error {
 	header.Set(clients.HdrSpanTraceID, span.SpanContext().TraceID().String())
 	header.Set(clients.HdrSpanTraceFlags, span.SpanContext().TraceFlags().String())
 
-	if len(payload) > int(agentService.NatsMaxPayload) {
+	codecName := compressionCodec(agentService.NatsCompression, subj, len(payload))
+	if codecName == "" && len(payload) > int(agentService.NatsMaxPayload) {
+		codecName = codec.Gzip
+	}
+	if codecName != "" {
 		n0 := len(payload)
 		var buf bytes.Buffer
-		_, err = clients.GZip(ctx, &buf, payload)
+		if codecName == codec.Gzip {
+			_, err = clients.GZip(ctx, &buf, payload)
+		} else {
+			err = compress(ctx, codecName, &buf, payload)
+		}
 		if err != nil {
 			return err
 		}
 		if buf.Len() > int(agentService.NatsMaxPayload) {
-			err = fmt.Errorf("%w: %v / %v / %v / %v / gzip compressed",
-				tcgnats.ErrPayloadLim, subj, agentService.NatsMaxPayload, n0, buf.Len())
+			err = fmt.Errorf("%w: %v / %v / %v / %v / %s compressed",
+				tcgnats.ErrPayloadLim, subj, agentService.NatsMaxPayload, n0, buf.Len(), codecName)
 			return err
 		}
 		payload = buf.Bytes()
-		header.Set(clients.HdrCompressed, "gzip")
+		header.Set(clients.HdrCompressed, codecName)
 		header.Set(clients.HdrPayloadLen, fmt.Sprint(n0))
 	}
 	header.Add(clients.HdrPayloadLen, fmt.Sprint(len(payload)))
@@ -61,6 +72,37 @@ func Put2Nats(ctx context.Context, subj string, payload []byte) error {
 	return err
 }
 
+// compressionCodec returns codec of the first rule matching subject and payload size
+func compressionCodec(rules []config.CompressionRule, subj string, size int) string {
+	for _, r := range rules {
+		if matchAny(r.Subjects, subj) {
+			if size < r.MinBytes {
+				return ""
+			}
+			return r.Codec
+		}
+	}
+	return ""
+}
+
+// compress encodes payload with codec wrapped in trace span
+func compress(ctx context.Context, codecName string, w io.Writer, p []byte) error {
+	_, span := tracing.StartTraceSpan(ctx, "services", "compress")
+	err := func() error {
+		c, err := codec.Get(codecName)
+		if err != nil {
+			return err
+		}
+		return c.Encode(w, p)
+	}()
+	tracing.EndTraceSpan(span,
+		tracing.TraceAttrError(err),
+		tracing.TraceAttrStr("codec", codecName),
+		tracing.TraceAttrPayloadLen(p),
+	)
+	return err
+}
+
 func getCtx(ctx context.Context, sc trace.SpanContext) context.Context {
 	if sc.IsValid() {
 		return trace.ContextWithRemoteSpanContext(ctx, sc)
@@ -69,11 +111,8 @@ func getCtx(ctx context.Context, sc trace.SpanContext) context.Context {
 }
 
 func makeDurable(durable string, handleWithCtx func(context.Context, jetstream.Msg) error) tcgnats.DurableCfg {
-	for _, s := range []string{"/", ".", "*", ">"} {
-		durable = strings.ReplaceAll(durable, s, "")
-	}
 	return tcgnats.DurableCfg{
-		Durable: durable,
+		Durable: durableName(durable),
 		Handler: func(ctx context.Context, msg jetstream.Msg) error {
 			var (
 				err     error
@@ -133,9 +172,10 @@ func makeDurable(durable string, handleWithCtx func(context.Context, jetstream.M
 				/* it looks like an issue with credentialed user
 				so, wait for configuration update */
 				log.Err(err).Msg("dispatcher got an issue with credentialed user, wait for configuration update")
+				agentService.notify(EventGWUnauthorized, err.Error())
 				_ = agentService.StopTransport()
 			} else if errors.Is(err, tcgerr.ErrUndecided) {
-				/* it looks like an issue with data */
+				/* it looks like an issue with data, message is moved into dead-letter stream */
 				log.Err(err).Msg("dispatcher got an issue with data")
 			} else if err != nil {
 				log.Err(err).Msg("dispatcher got an issue")
@@ -146,13 +186,26 @@ func makeDurable(durable string, handleWithCtx func(context.Context, jetstream.M
 	}
 }
 
+// durableName sanitizes durable name
+func durableName(durable string) string {
+	for _, s := range []string{"/", ".", "*", ">"} {
+		durable = strings.ReplaceAll(durable, s, "")
+	}
+	return durable
+}
+
+// gwDurableName returns durable name for GroundWork connection
+func gwDurableName(gwHost string) string {
+	return durableName(fmt.Sprintf("#%s#", gwHost))
+}
+
 func makeSubscriptions(gwClients []clients.GWClient) []tcgnats.DurableCfg {
 	var subs = make([]tcgnats.DurableCfg, 0, len(gwClients))
 	for i := range gwClients {
 		// gwClient := gwClient /* hold loop var copy */
 		gwClient := &gwClients[i]
 		subs = append(subs, makeDurable(
-			fmt.Sprintf("#%s#", gwClient.HostName),
+			gwDurableName(gwClient.HostName),
 			adaptClient(gwClient),
 		))
 	}
@@ -161,42 +214,87 @@ func makeSubscriptions(gwClients []clients.GWClient) []tcgnats.DurableCfg {
 
 func adaptClient(gwClient *clients.GWClient) func(context.Context, jetstream.Msg) error {
 	return func(ctx context.Context, msg jetstream.Msg) error {
-		data, header, pType := msg.Data(), msg.Headers(), new(payloadType)
-		if _, err := pType.FromStr(header.Get(clients.HdrPayloadType)); err != nil {
-			return err
+		data, header := msg.Data(), http.Header(msg.Headers())
+		origHeader := header.Clone()
+		payload, err := decompress(data, header)
+		if err == nil {
+			err = deliver(ctx, gwClient, payload, header)
 		}
-		if header.Get(clients.HdrTodoTracerCtx) != "" &&
-			header.Get(clients.HdrCompressed) == "" {
-			// TODO: process redundant case (HdrTodoTracerCtx && HdrCompressed)
-			data = agentService.fixTracerContext(data)
-			header.Del(clients.HdrTodoTracerCtx)
+		if errors.Is(err, tcgerr.ErrUndecided) {
+			/* it looks like an issue with data, keep it for investigation and replay */
+			putDLQ(ctx, gwClient.HostName, msg.Subject(), data, origHeader, err)
 		}
-		ctx = clients.CtxWithHeader(ctx, http.Header(header))
-
-		var fn func(context.Context, []byte) ([]byte, error)
-		switch *pType {
-		case typeEvents:
-			fn = gwClient.SendEvents
-		case typeEventsAck:
-			fn = gwClient.SendEventsAck
-		case typeEventsUnack:
-			fn = gwClient.SendEventsUnack
-		case typeClearInDowntime:
-			fn = gwClient.ClearInDowntime
-		case typeSetInDowntime:
-			fn = gwClient.SetInDowntime
-		case typeInventory:
-			fn = gwClient.SynchronizeInventory
-		case typeMetrics:
-			fn = gwClient.SendResourcesWithMetrics
-		default:
-			return fmt.Errorf("%w: unknown payload type: %v", tcgnats.ErrDispatcher, *pType)
+		return err
+	}
+}
+
+// decompress decodes payload compressed with other than gzip codec,
+// gzipped payload is passed as is to GroundWork with Content-Encoding
+func decompress(data []byte, header http.Header) ([]byte, error) {
+	codecName := header.Get(clients.HdrCompressed)
+	if codecName == "" || codecName == codec.Gzip {
+		return data, nil
+	}
+	p, err := codec.Decode(codecName, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not decompress: %w", tcgerr.ErrUndecided, err)
+	}
+	header.Del(clients.HdrCompressed)
+	header.Del(clients.HdrPayloadLen)
+	return p, nil
+}
+
+// deliver sends payload to GroundWork connection based on payload type header
+func deliver(ctx context.Context, gwClient *clients.GWClient, data []byte, header http.Header) error {
+	pType := new(payloadType)
+	if _, err := pType.FromStr(header.Get(clients.HdrPayloadType)); err != nil {
+		return err
+	}
+	if header.Get(clients.HdrTodoTracerCtx) != "" &&
+		header.Get(clients.HdrCompressed) == "" {
+		// TODO: process redundant case (HdrTodoTracerCtx && HdrCompressed)
+		data = agentService.fixTracerContext(data)
+		header.Del(clients.HdrTodoTracerCtx)
+	}
+	if !gwClient.Routing.IsEmpty() {
+		var err error
+		if data, err = route(gwClient.Routing, *pType, data, header); err != nil {
+			return fmt.Errorf("%w: could not apply routing: %w", tcgerr.ErrUndecided, err)
 		}
-		_, err := fn(ctx, data)
-		if err == nil && *pType == typeMetrics {
-			agentService.stats.MetricsSent.Add(1)
+		if data == nil {
+			log.Debug().
+				Str("gwHost", gwClient.HostName).
+				Str("payloadType", pType.String()).
+				Msg("routing: nothing to send")
+			return nil
 		}
+		header.Del(clients.HdrPayloadLen)
+	}
+	ctx = clients.CtxWithHeader(ctx, header)
 
-		return err
+	var fn func(context.Context, []byte) ([]byte, error)
+	switch *pType {
+	case typeEvents:
+		fn = gwClient.SendEvents
+	case typeEventsAck:
+		fn = gwClient.SendEventsAck
+	case typeEventsUnack:
+		fn = gwClient.SendEventsUnack
+	case typeClearInDowntime:
+		fn = gwClient.ClearInDowntime
+	case typeSetInDowntime:
+		fn = gwClient.SetInDowntime
+	case typeInventory:
+		fn = gwClient.SynchronizeInventory
+	case typeMetrics:
+		fn = gwClient.SendResourcesWithMetrics
+	default:
+		return fmt.Errorf("%w: unknown payload type: %v", tcgnats.ErrDispatcher, *pType)
+	}
+	_, err := fn(ctx, data)
+	if err == nil && *pType == typeMetrics {
+		agentService.stats.MetricsSent.Add(1)
 	}
+
+	return err
 }
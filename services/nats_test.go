@@ -0,0 +1,46 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gwos/tcg/codec"
+	"github.com/gwos/tcg/config"
+	"github.com/gwos/tcg/sdk/clients"
+	tcgerr "github.com/gwos/tcg/sdk/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompression(t *testing.T) {
+	rules := []config.CompressionRule{
+		{Subjects: []string{subjEvents}, Codec: codec.Snappy},
+		{Subjects: []string{"tcg.*"}, Codec: codec.Zstd, MinBytes: 100},
+	}
+	assert.Equal(t, codec.Snappy, compressionCodec(rules, subjEvents, 10))
+	assert.Equal(t, codec.Zstd, compressionCodec(rules, subjMetrics, 100))
+	assert.Equal(t, "", compressionCodec(rules, subjMetrics, 99))
+	assert.Equal(t, "", compressionCodec(rules, "other", 1000))
+
+	p := bytes.Repeat([]byte(`{"name":"host"},`), 100)
+	var buf bytes.Buffer
+	assert.NoError(t, compress(context.Background(), codec.Zstd, &buf, p))
+
+	header := make(http.Header)
+	header.Set(clients.HdrCompressed, codec.Zstd)
+	data, err := decompress(buf.Bytes(), header)
+	assert.NoError(t, err)
+	assert.Equal(t, p, data)
+	assert.Empty(t, header.Get(clients.HdrCompressed))
+
+	/* gzip is passed to GroundWork as is */
+	header.Set(clients.HdrCompressed, codec.Gzip)
+	data, err = decompress(buf.Bytes(), header)
+	assert.NoError(t, err)
+	assert.Equal(t, buf.Bytes(), data)
+
+	header.Set(clients.HdrCompressed, codec.Snappy)
+	_, err = decompress([]byte("bad"), header)
+	assert.ErrorIs(t, err, tcgerr.ErrUndecided)
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gwos/tcg/config"
+	"github.com/gwos/tcg/sdk/clients"
+	"github.com/rs/zerolog/log"
+)
+
+// watchConfig checks the config file every ConfigWatchInterval
+// and pushes the reload task on file changes,
+// also restarts transport on changes of secret files referenced in config
+func (service *AgentService) watchConfig() {
+	if service.Connector.ConfigWatchInterval <= 0 {
+		log.Debug().Msg("watching config file is not configured")
+		return
+	}
+	configPath := config.GetConfig().ConfigPath()
+	stat := func(p string) (time.Time, int64) {
+		if fi, err := os.Stat(p); err == nil {
+			return fi.ModTime(), fi.Size()
+		}
+		return time.Time{}, 0
+	}
+	statSecrets := func() string {
+		var sb strings.Builder
+		for _, p := range config.GetConfig().SecretFiles() {
+			mt, sz := stat(p)
+			fmt.Fprintf(&sb, "%s:%d:%d;", p, mt.UnixNano(), sz)
+		}
+		return sb.String()
+	}
+	go func() {
+		modTime, size := stat(configPath)
+		secrets := statSecrets()
+		for {
+			interval := service.Connector.ConfigWatchInterval
+			if interval <= 0 {
+				log.Info().Msg("stopped watching config file")
+				return
+			}
+			time.Sleep(interval)
+			if mt, sz := stat(configPath); !mt.IsZero() && (!mt.Equal(modTime) || sz != size) {
+				modTime, size = mt, sz
+				if err := service.taskQueue.PushSync(taskReload); err != nil {
+					log.Warn().Err(err).Str("configPath", configPath).Msg("could not reload config")
+				}
+				secrets = statSecrets()
+				continue
+			}
+			if s := statSecrets(); s != secrets {
+				secrets = s
+				if service.agentStatus.Transport.Value() != StatusRunning {
+					continue
+				}
+				log.Info().Msg("secret files changed, restarting transport")
+				for _, subj := range []taskSubject{taskStopTransport, taskStartTransport} {
+					if err := service.taskQueue.PushSync(subj); err != nil {
+						log.Warn().Err(err).Msgf("could not push %v on secret rotation", subj)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// reload applies changes of the config file:
+// logging and retry delays are updated by config, batchers and their WAL are reset in place,
+// transport or nats are restarted via task queue only if affected
+func (service *AgentService) reload() error {
+	natsChk0, err := service.Connector.Nats.Hashsum()
+	if err != nil {
+		log.Err(err).Msg("error getting nats config checksum")
+	}
+	changes, err := config.GetConfig().Reload()
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	log.Info().Interface("changes", changes).Msg("reloaded config")
+
+	var restartTransport, resetBatchers bool
+	for _, c := range changes {
+		switch {
+		case strings.HasPrefix(c.Field, "Connector.Batch"):
+			resetBatchers = true
+		case strings.HasPrefix(c.Field, "DSConnection"):
+			service.dsClient = clients.DSClient{DSConnection: config.GetConfig().DSConnection.AsClient()}
+		case strings.HasPrefix(c.Field, "GWConnections"),
+			c.Field == "Connector.AgentID", c.Field == "Connector.AppName",
+			c.Field == "Connector.AppType", c.Field == "Connector.Enabled":
+			restartTransport = true
+		}
+	}
+	if resetBatchers {
+		GetTransitService().eventsBatcher.Reset(service.Connector.BatchEvents, service.Connector.BatchMaxBytes)
+		GetTransitService().inventoryBatcher.Reset(service.Connector.BatchInventory, service.Connector.BatchMaxBytes)
+		GetTransitService().metricsBatcher.Reset(service.Connector.BatchMetrics, service.Connector.BatchMaxBytes)
+		GetTransitService().setBatchWAL(service.Connector.BatchWALDir)
+	}
+
+	natsChk, err := service.Connector.Nats.Hashsum()
+	if err != nil {
+		log.Err(err).Msg("error getting nats config checksum")
+	}
+	isNatsRunning := service.agentStatus.Nats.Value() == StatusRunning
+	isTransportRunning := service.agentStatus.Transport.Value() == StatusRunning
+	startTransport := service.Connector.Enabled && (isTransportRunning || restartTransport)
+
+	var tasks []taskSubject
+	switch {
+	case isNatsRunning && !bytes.Equal(natsChk0, natsChk):
+		tasks = append(tasks, taskStopNats, taskStartNats)
+		if startTransport {
+			tasks = append(tasks, taskStartTransport)
+		}
+	case isNatsRunning && restartTransport:
+		tasks = append(tasks, taskStopTransport)
+		if startTransport {
+			tasks = append(tasks, taskStartTransport)
+		}
+	}
+	/* the task queue is busy with this task, so push restart tasks async */
+	for _, subj := range tasks {
+		if _, err := service.taskQueue.PushAsync(subj); err != nil {
+			log.Err(err).Msgf("could not push %v on reloading config", subj)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gwos/tcg/sdk/transit"
+	"github.com/rs/zerolog/log"
+)
+
+type ctxReplayKeyType int
+
+const ctxReplay ctxReplayKeyType = iota
+
+// ctxWithReplay marks context to skip exportTransit on replay
+func ctxWithReplay(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxReplay, true)
+}
+
+func isReplayCtx(ctx context.Context) bool {
+	v, _ := ctx.Value(ctxReplay).(bool)
+	return v
+}
+
+// ReplayOptions defines options for ReplayTransitDir
+type ReplayOptions struct {
+	// Dir is the directory with files written by exportTransit,
+	// it should be ExportTransitDir or its subdirectory
+	Dir string `json:"dir"`
+	// AgentID overrides agentId of tracer context if set
+	AgentID string `json:"agentId,omitempty"`
+	// TracerCtx replaces tracer context with new one if set
+	TracerCtx bool `json:"tracerCtx,omitempty"`
+	// From and To limit the replayed files by timestamp if set
+	From time.Time `json:"from,omitzero"`
+	To   time.Time `json:"to,omitzero"`
+}
+
+// ReplayResult describes ReplayTransitDir result
+type ReplayResult struct {
+	Replayed int      `json:"replayed"`
+	Skipped  []string `json:"skipped,omitempty"`
+}
+
+type transitFile struct {
+	name string
+	op   TransitOperation
+	ts   time.Time
+}
+
+// parseTransitFile parses the file name made by exportTransit
+// in form of "<RFC3339Nano>-<TransitOperation>.json"
+func parseTransitFile(name string) (transitFile, bool) {
+	for _, op := range []TransitOperation{
+		TOpClearInDowntime, TOpSetInDowntime,
+		TOpSendEvents, TOpSendEventsAck, TOpSendEventsUnack,
+		TOpSendMetrics, TOpSendStates, TOpSyncInventory,
+	} {
+		if ts, ok := strings.CutSuffix(name, "-"+string(op)+".json"); ok {
+			t, err := time.Parse(time.RFC3339Nano, ts)
+			if err != nil {
+				return transitFile{}, false
+			}
+			return transitFile{name: name, op: op, ts: t}, true
+		}
+	}
+	return transitFile{}, false
+}
+
+// rewriteTracerContext updates tracer context of payload based on options
+func (service *TransitService) rewriteTracerContext(payload []byte, opts ReplayOptions) ([]byte, error) {
+	if !opts.TracerCtx && opts.AgentID == "" {
+		return payload, nil
+	}
+	var p map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	ctxJSON, ok := p["context"]
+	if !ok {
+		return payload, nil
+	}
+	tc := transit.TracerContext{}
+	if err := json.Unmarshal(ctxJSON, &tc); err != nil {
+		return nil, err
+	}
+	if opts.TracerCtx {
+		tc = service.MakeTracerContext()
+	}
+	if opts.AgentID != "" {
+		tc.AgentID = opts.AgentID
+	}
+	var err error
+	if p["context"], err = json.Marshal(tc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(p)
+}
+
+// replayDir resolves the replay dir inside ExportTransitDir
+// relative paths are joined with ExportTransitDir
+func (service *TransitService) replayDir(dir string) (string, error) {
+	base := service.Connector.ExportTransitDir
+	if base == "" {
+		return "", fmt.Errorf("exportTransitDir is not set")
+	}
+	base, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	if dir == "" {
+		return base, nil
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(base, dir)
+	}
+	rel, err := filepath.Rel(base, filepath.Clean(dir))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("replay dir is outside of exportTransitDir: %v", dir)
+	}
+	return filepath.Join(base, rel), nil
+}
+
+// ReplayTransitDir reads files written by exportTransit
+// and pushes them back in original order
+func (service *TransitService) ReplayTransitDir(ctx context.Context, opts ReplayOptions) (ReplayResult, error) {
+	res := ReplayResult{}
+	var err error
+	if opts.Dir, err = service.replayDir(opts.Dir); err != nil {
+		return res, err
+	}
+	entries, err := os.ReadDir(opts.Dir)
+	if err != nil {
+		return res, err
+	}
+	files := make([]transitFile, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		f, ok := parseTransitFile(e.Name())
+		if !ok {
+			res.Skipped = append(res.Skipped, e.Name())
+			continue
+		}
+		if (!opts.From.IsZero() && f.ts.Before(opts.From)) ||
+			(!opts.To.IsZero() && f.ts.After(opts.To)) {
+			continue
+		}
+		files = append(files, f)
+	}
+	/* RFC3339Nano trims trailing zeros, so names are not sortable as strings */
+	slices.SortStableFunc(files, func(a, b transitFile) int { return a.ts.Compare(b.ts) })
+
+	ctx = ctxWithReplay(ctx)
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		payload, err := os.ReadFile(filepath.Join(opts.Dir, f.name))
+		if err != nil {
+			return res, err
+		}
+		if payload, err = service.rewriteTracerContext(payload, opts); err != nil {
+			return res, fmt.Errorf("could not rewrite tracer context: %v: %w", f.name, err)
+		}
+
+		switch f.op {
+		case TOpClearInDowntime:
+			err = service.ClearInDowntime(ctx, payload)
+		case TOpSetInDowntime:
+			err = service.SetInDowntime(ctx, payload)
+		case TOpSendEvents:
+			err = service.SendEvents(ctx, payload)
+		case TOpSendEventsAck:
+			err = service.SendEventsAck(ctx, payload)
+		case TOpSendEventsUnack:
+			err = service.SendEventsUnack(ctx, payload)
+		case TOpSendMetrics:
+			err = service.SendResourceWithMetrics(ctx, payload)
+		case TOpSendStates:
+			p := new(transit.ResourcesWithServicesRequest)
+			if err = json.Unmarshal(payload, p); err == nil {
+				err = service.SendStates(ctx, p)
+			}
+		case TOpSyncInventory:
+			err = service.SynchronizeInventory(ctx, payload)
+		}
+		if err != nil {
+			return res, fmt.Errorf("could not replay: %v: %w", f.name, err)
+		}
+		res.Replayed++
+		log.Debug().Str("file", f.name).Msg("replayed transit file")
+	}
+
+	log.Info().
+		Str("dir", opts.Dir).
+		Int("replayed", res.Replayed).
+		Int("skipped", len(res.Skipped)).
+		Msg("replayed transit files")
+	return res, nil
+}
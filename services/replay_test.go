@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gwos/tcg/sdk/transit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayTransitDir(t *testing.T) {
+	dir := t.TempDir()
+	exportTransitDir := GetTransitService().Connector.ExportTransitDir
+	t.Cleanup(func() {
+		GetTransitService().Connector.ExportTransitDir = exportTransitDir
+		assert.NoError(t, GetAgentService().StopNats())
+		assert.NoError(t, os.RemoveAll(filepath.Join(GetAgentService().Connector.NatsStoreDir, "jetstream")))
+		assert.NoError(t, os.RemoveAll(filepath.Join(GetAgentService().Connector.NatsStoreDir, "inventory.json")))
+		assert.NoError(t, os.RemoveAll(filepath.Join(GetAgentService().Connector.NatsStoreDir, "inventory1.json")))
+	})
+
+	for name, data := range map[string]string{
+		"2025-01-02T10:00:00.5Z-events.json":       `{"events":[{"host":"host1"}]}`,
+		"2025-01-02T10:00:00.25Z-inventory.json":   `{"context":{"agentId":"agent1","traceToken":"token1"},"resources":[]}`,
+		"2025-01-02T10:00:01Z-events-ack.json":     `{"acks":[{"host":"host1"}]}`,
+		"2025-01-02T10:00:02Z-downtime-clear.json": `{"bizHostServiceInDowntimes":[]}`,
+		"inventory.json":                           `{}`,
+	} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0664))
+	}
+
+	f, ok := parseTransitFile("2025-01-02T10:00:00.25Z-events-ack.json")
+	assert.True(t, ok)
+	assert.Equal(t, TOpSendEventsAck, f.op)
+	_, ok = parseTransitFile("inventory.json")
+	assert.False(t, ok)
+
+	payload, err := GetTransitService().rewriteTracerContext(
+		[]byte(`{"context":{"agentId":"agent1","traceToken":"token1"},"resources":[]}`),
+		ReplayOptions{AgentID: "agent2"})
+	assert.NoError(t, err)
+	p := transit.InventoryRequest{}
+	assert.NoError(t, json.Unmarshal(payload, &p))
+	assert.Equal(t, "agent2", p.Context.AgentID)
+	assert.Equal(t, "token1", p.Context.TraceToken)
+
+	assert.NoError(t, GetAgentService().StartNats())
+	/* replayed payloads should not be exported again */
+	GetTransitService().Connector.ExportTransitDir = dir
+	res, err := GetTransitService().ReplayTransitDir(context.Background(), ReplayOptions{Dir: dir, TracerCtx: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, res.Replayed)
+	assert.Equal(t, []string{"inventory.json"}, res.Skipped)
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 5)
+
+	for _, d := range []string{"..", "../other", filepath.Dir(dir), "/etc"} {
+		_, err = GetTransitService().ReplayTransitDir(context.Background(), ReplayOptions{Dir: d})
+		assert.ErrorContains(t, err, "outside of exportTransitDir", d)
+	}
+	GetTransitService().Connector.ExportTransitDir = ""
+	_, err = GetTransitService().ReplayTransitDir(context.Background(), ReplayOptions{Dir: dir})
+	assert.ErrorContains(t, err, "exportTransitDir is not set")
+}
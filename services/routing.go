@@ -0,0 +1,347 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/gwos/tcg/codec"
+	"github.com/gwos/tcg/config"
+	"github.com/gwos/tcg/sdk/clients"
+	"github.com/gwos/tcg/sdk/transit"
+	"github.com/rs/zerolog/log"
+)
+
+// Define routing payload types
+// group payload types as the user sees them
+const (
+	routeDowntimes = "downtimes"
+	routeEvents    = "events"
+	routeInventory = "inventory"
+	routeMetrics   = "metrics"
+)
+
+// routingGroupsFile keeps known host groups between restarts
+const routingGroupsFile = "routing-groups.json"
+
+// routingGroups holds the last known host groups of hosts
+// as only inventory and metrics payloads bring the membership
+var routingGroups = struct {
+	sync.Mutex
+	loaded bool
+	m      map[string][]string
+}{m: make(map[string][]string)}
+
+func loadRoutingGroups() {
+	if routingGroups.loaded {
+		return
+	}
+	routingGroups.loaded = true
+	data, err := os.ReadFile(filepath.Join(GetAgentService().NatsStoreDir, routingGroupsFile))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warn().Err(err).Msg("could not read routing groups")
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &routingGroups.m); err != nil {
+		log.Warn().Err(err).Msg("could not parse routing groups")
+	}
+}
+
+func storeRoutingGroups() {
+	data, err := json.Marshal(routingGroups.m)
+	if err == nil {
+		_ = os.MkdirAll(GetAgentService().NatsStoreDir, 0777)
+		err = config.WriteFileAtomic(filepath.Join(GetAgentService().NatsStoreDir, routingGroupsFile), data, 0666)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("could not store routing groups")
+	}
+}
+
+// routeItem describes the piece of data to route,
+// empty fields are unknown for the piece of data
+type routeItem struct {
+	payload string
+	host    string
+	groups  []string
+	service string
+}
+
+// router applies routing rules to payloads
+type router struct {
+	clients.RoutingRules
+}
+
+func routeType(pType payloadType) string {
+	switch pType {
+	case typeEvents, typeEventsAck, typeEventsUnack:
+		return routeEvents
+	case typeClearInDowntime, typeSetInDowntime:
+		return routeDowntimes
+	case typeInventory:
+		return routeInventory
+	case typeMetrics:
+		return routeMetrics
+	}
+	return ""
+}
+
+// matchAny checks if value matches any pattern
+func matchAny(patterns []string, values ...string) bool {
+	for _, pattern := range patterns {
+		for _, v := range values {
+			if ok, _ := path.Match(pattern, v); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// match checks rule against item,
+// unknownMatch defines the result of criteria on unknown fields,
+// so the data that can not be checked is not filtered out
+// by include rules (true) as well as by exclude rules (false),
+// host groups of a host with unknown membership never match
+// to not send the host anywhere but its own connection
+func match(rule clients.RoutingRule, it routeItem, unknownMatch bool) bool {
+	if len(rule.PayloadTypes) != 0 && !matchAny(rule.PayloadTypes, it.payload) {
+		return false
+	}
+	check := func(patterns []string, values ...string) bool {
+		if len(patterns) == 0 {
+			return true
+		}
+		if len(values) == 0 || (len(values) == 1 && values[0] == "") {
+			return unknownMatch
+		}
+		return matchAny(patterns, values...)
+	}
+	checkGroups := func() bool {
+		if len(rule.HostGroups) != 0 && it.host != "" {
+			return matchAny(rule.HostGroups, it.groups...)
+		}
+		return check(rule.HostGroups, it.groups...)
+	}
+	return check(rule.Hosts, it.host) &&
+		checkGroups() &&
+		check(rule.Services, it.service)
+}
+
+// allow checks if item should be sent
+func (r router) allow(it routeItem) bool {
+	if it.host != "" && len(it.groups) == 0 {
+		routingGroups.Lock()
+		loadRoutingGroups()
+		it.groups = routingGroups.m[it.host]
+		routingGroups.Unlock()
+	}
+	if len(r.Include) != 0 && !slices.ContainsFunc(r.Include, func(rule clients.RoutingRule) bool {
+		return match(rule, it, true)
+	}) {
+		return false
+	}
+	return !slices.ContainsFunc(r.Exclude, func(rule clients.RoutingRule) bool {
+		return match(rule, it, false)
+	})
+}
+
+// learnGroups updates known host groups with payload groups,
+// inventory brings the full membership of its hosts and replaces the known one,
+// other payloads (hosts is nil) only add groups of hosts with unknown membership
+func learnGroups(groups []transit.ResourceGroup, hosts []string) {
+	m := make(map[string][]string, len(hosts))
+	for _, host := range hosts {
+		m[host] = []string{}
+	}
+	for _, g := range groups {
+		if g.Type != transit.HostGroup {
+			continue
+		}
+		for _, res := range g.Resources {
+			m[res.Name] = append(m[res.Name], g.GroupName)
+		}
+	}
+	if len(m) == 0 {
+		return
+	}
+	routingGroups.Lock()
+	defer routingGroups.Unlock()
+	loadRoutingGroups()
+	changed := false
+	for host, hostGroups := range m {
+		prev, ok := routingGroups.m[host]
+		if ok && (hosts == nil || slices.Equal(prev, hostGroups)) {
+			continue
+		}
+		routingGroups.m[host] = hostGroups
+		changed = true
+	}
+	if changed {
+		storeRoutingGroups()
+	}
+}
+
+// filterGroups keeps references to sent resources and drops emptied groups
+func filterGroups(groups []transit.ResourceGroup, hosts map[string]bool) []transit.ResourceGroup {
+	result := groups[:0]
+	for _, g := range groups {
+		if len(g.Resources) == 0 {
+			result = append(result, g)
+			continue
+		}
+		g.Resources = slices.DeleteFunc(g.Resources, func(res transit.ResourceRef) bool {
+			return !hosts[res.Name]
+		})
+		if len(g.Resources) != 0 {
+			result = append(result, g)
+		}
+	}
+	return result
+}
+
+// route applies routing rules to payload,
+// returns nil payload if there is nothing to send
+func route(rules clients.RoutingRules, pType payloadType, data []byte, header http.Header) ([]byte, error) {
+	if rules.IsEmpty() {
+		return data, nil
+	}
+	if codecName := header.Get(clients.HdrCompressed); codecName != "" {
+		var err error
+		if data, err = codec.Decode(codecName, data); err != nil {
+			return nil, err
+		}
+		header.Del(clients.HdrCompressed)
+	}
+
+	r := router{RoutingRules: rules}
+	rType := routeType(pType)
+	switch pType {
+	case typeInventory:
+		p := new(transit.InventoryRequest)
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, err
+		}
+		hosts := make([]string, 0, len(p.Resources))
+		for _, res := range p.Resources {
+			hosts = append(hosts, res.Name)
+		}
+		learnGroups(p.Groups, hosts)
+		sent := make(map[string]bool)
+		p.Resources = slices.DeleteFunc(p.Resources, func(res transit.InventoryResource) bool {
+			return !r.allow(routeItem{payload: rType, host: res.Name})
+		})
+		for i := range p.Resources {
+			res := &p.Resources[i]
+			sent[res.Name] = true
+			res.Services = slices.DeleteFunc(res.Services, func(svc transit.InventoryService) bool {
+				return !r.allow(routeItem{payload: rType, host: res.Name, service: svc.Name})
+			})
+		}
+		p.Groups = filterGroups(p.Groups, sent)
+		/* inventory is synchronized even if empty */
+		return json.Marshal(p)
+
+	case typeMetrics:
+		p := new(transit.ResourcesWithServicesRequest)
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, err
+		}
+		learnGroups(p.Groups, nil)
+		hosts := make(map[string]bool)
+		p.Resources = slices.DeleteFunc(p.Resources, func(res transit.MonitoredResource) bool {
+			return !r.allow(routeItem{payload: rType, host: res.Name})
+		})
+		for i := range p.Resources {
+			res := &p.Resources[i]
+			hosts[res.Name] = true
+			res.Services = slices.DeleteFunc(res.Services, func(svc transit.MonitoredService) bool {
+				return !r.allow(routeItem{payload: rType, host: res.Name, service: svc.Name})
+			})
+		}
+		if len(p.Resources) == 0 {
+			return nil, nil
+		}
+		p.Groups = filterGroups(p.Groups, hosts)
+		return json.Marshal(p)
+
+	case typeEvents:
+		p := new(transit.GroundworkEventsRequest)
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, err
+		}
+		p.Events = slices.DeleteFunc(p.Events, func(e transit.GroundworkEvent) bool {
+			return !r.allow(routeItem{payload: rType, host: e.Host, service: e.Service})
+		})
+		if len(p.Events) == 0 {
+			return nil, nil
+		}
+		return json.Marshal(p)
+
+	case typeEventsAck:
+		p := new(transit.GroundworkEventsAckRequest)
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, err
+		}
+		p.Acks = slices.DeleteFunc(p.Acks, func(e transit.GroundworkEventAck) bool {
+			return !r.allow(routeItem{payload: rType, host: e.Host, service: e.Service})
+		})
+		if len(p.Acks) == 0 {
+			return nil, nil
+		}
+		return json.Marshal(p)
+
+	case typeEventsUnack:
+		p := new(transit.GroundworkEventsUnackRequest)
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, err
+		}
+		p.Unacks = slices.DeleteFunc(p.Unacks, func(e transit.GroundworkEventUnack) bool {
+			return !r.allow(routeItem{payload: rType, host: e.Host, service: e.Service})
+		})
+		if len(p.Unacks) == 0 {
+			return nil, nil
+		}
+		return json.Marshal(p)
+
+	case typeClearInDowntime:
+		p := new(transit.Downtimes)
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, err
+		}
+		p.BizHostServiceInDowntimes = slices.DeleteFunc(p.BizHostServiceInDowntimes, func(d transit.Downtime) bool {
+			return !r.allow(routeItem{payload: rType, host: d.HostName, service: d.ServiceDescription})
+		})
+		if len(p.BizHostServiceInDowntimes) == 0 {
+			return nil, nil
+		}
+		return json.Marshal(p)
+
+	case typeSetInDowntime:
+		p := new(transit.DowntimesRequest)
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, err
+		}
+		p.HostNames = slices.DeleteFunc(p.HostNames, func(s string) bool {
+			return !r.allow(routeItem{payload: rType, host: s})
+		})
+		p.HostGroupNames = slices.DeleteFunc(p.HostGroupNames, func(s string) bool {
+			return !r.allow(routeItem{payload: rType, groups: []string{s}})
+		})
+		p.ServiceDescriptions = slices.DeleteFunc(p.ServiceDescriptions, func(s string) bool {
+			return !r.allow(routeItem{payload: rType, service: s})
+		})
+		if len(p.HostNames) == 0 && len(p.HostGroupNames) == 0 {
+			return nil, nil
+		}
+		return json.Marshal(p)
+	}
+	return data, nil
+}
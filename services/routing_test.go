@@ -0,0 +1,129 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gwos/tcg/sdk/clients"
+	"github.com/gwos/tcg/sdk/transit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoute(t *testing.T) {
+	t.Cleanup(func() {
+		routingGroups.m = make(map[string][]string)
+		assert.NoError(t, os.RemoveAll(filepath.Join(GetAgentService().Connector.NatsStoreDir, routingGroupsFile)))
+	})
+	routingGroups.loaded = true
+	routingGroups.m = make(map[string][]string)
+
+	inventory := []byte(`{
+		"resources":[
+			{"name":"child1-host1","type":"host","services":[{"name":"cpu"},{"name":"disk"}]},
+			{"name":"child2-host1","type":"host","services":[{"name":"cpu"}]},
+			{"name":"host3","type":"host","services":[{"name":"cpu"}]}
+		],
+		"groups":[
+			{"groupName":"child1","type":"HostGroup","resources":[{"name":"child1-host1"}]},
+			{"groupName":"child2","type":"HostGroup","resources":[{"name":"child2-host1"},{"name":"host3"}]}
+		]}`)
+	events := []byte(`{"events":[
+		{"host":"child1-host1","service":"cpu"},
+		{"host":"host3","service":"cpu"},
+		{"host":"host4","service":"cpu"}
+	]}`)
+
+	t.Run("empty rules", func(t *testing.T) {
+		data, err := route(clients.RoutingRules{}, typeInventory, inventory, make(http.Header))
+		assert.NoError(t, err)
+		assert.Equal(t, inventory, data)
+	})
+
+	t.Run("include host groups", func(t *testing.T) {
+		rules := clients.RoutingRules{Include: []clients.RoutingRule{{HostGroups: []string{"child2"}}}}
+		data, err := route(rules, typeInventory, inventory, make(http.Header))
+		assert.NoError(t, err)
+		p := transit.InventoryRequest{}
+		assert.NoError(t, json.Unmarshal(data, &p))
+		if assert.Len(t, p.Resources, 2) {
+			assert.Equal(t, "child2-host1", p.Resources[0].Name)
+			assert.Equal(t, "host3", p.Resources[1].Name)
+		}
+		if assert.Len(t, p.Groups, 1) {
+			assert.Equal(t, "child2", p.Groups[0].GroupName)
+		}
+
+		/* events have no groups but membership is known from inventory,
+		host4 with unknown membership is not sent */
+		data, err = route(rules, typeEvents, events, make(http.Header))
+		assert.NoError(t, err)
+		e := transit.GroundworkEventsRequest{}
+		assert.NoError(t, json.Unmarshal(data, &e))
+		if assert.Len(t, e.Events, 1) {
+			assert.Equal(t, "host3", e.Events[0].Host)
+		}
+
+		/* membership is kept between restarts */
+		routingGroups.loaded, routingGroups.m = false, make(map[string][]string)
+		data, err = route(rules, typeEvents, events, make(http.Header))
+		assert.NoError(t, err)
+		e = transit.GroundworkEventsRequest{}
+		assert.NoError(t, json.Unmarshal(data, &e))
+		if assert.Len(t, e.Events, 1) {
+			assert.Equal(t, "host3", e.Events[0].Host)
+		}
+
+		/* inventory replaces membership, host3 has left child2 */
+		_, err = route(rules, typeInventory, []byte(`{
+			"resources":[{"name":"host3","type":"host"}],
+			"groups":[{"groupName":"child1","type":"HostGroup","resources":[{"name":"host3"}]}]}`),
+			make(http.Header))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"child1"}, routingGroups.m["host3"])
+		data, err = route(rules, typeEvents, events, make(http.Header))
+		assert.NoError(t, err)
+		assert.Nil(t, data)
+
+		/* metrics groups do not override known membership */
+		_, err = route(rules, typeMetrics, []byte(`{
+			"resources":[{"name":"host3","type":"host"}],
+			"groups":[{"groupName":"child2","type":"HostGroup","resources":[{"name":"host3"}]}]}`),
+			make(http.Header))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"child1"}, routingGroups.m["host3"])
+	})
+
+	t.Run("exclude services and payload types", func(t *testing.T) {
+		rules := clients.RoutingRules{Exclude: []clients.RoutingRule{
+			{Hosts: []string{"child1-*"}, Services: []string{"disk"}},
+			{PayloadTypes: []string{"events"}},
+		}}
+		data, err := route(rules, typeInventory, inventory, make(http.Header))
+		assert.NoError(t, err)
+		p := transit.InventoryRequest{}
+		assert.NoError(t, json.Unmarshal(data, &p))
+		if assert.Len(t, p.Resources, 3) {
+			assert.Len(t, p.Resources[0].Services, 1)
+			assert.Equal(t, "cpu", p.Resources[0].Services[0].Name)
+		}
+
+		data, err = route(rules, typeEvents, events, make(http.Header))
+		assert.NoError(t, err)
+		assert.Nil(t, data)
+	})
+
+	t.Run("downtimes", func(t *testing.T) {
+		rules := clients.RoutingRules{Include: []clients.RoutingRule{{Hosts: []string{"child1-*"}}}}
+		data, err := route(rules, typeSetInDowntime,
+			[]byte(`{"hostNames":["child1-host1","host3"],"serviceDescriptions":["cpu"],"setHosts":true}`),
+			make(http.Header))
+		assert.NoError(t, err)
+		p := transit.DowntimesRequest{}
+		assert.NoError(t, json.Unmarshal(data, &p))
+		assert.Equal(t, []string{"child1-host1"}, p.HostNames)
+		assert.Equal(t, []string{"cpu"}, p.ServiceDescriptions)
+	})
+}
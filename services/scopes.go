@@ -0,0 +1,92 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gwos/tcg/config"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Scopes of local API tokens
+const (
+	ScopeAdmin           = "admin"
+	ScopeIngestDowntimes = "ingest:downtimes"
+	ScopeIngestEvents    = "ingest:events"
+	ScopeIngestInventory = "ingest:inventory"
+	ScopeIngestMetrics   = "ingest:metrics"
+	ScopeReadStats       = "read:stats"
+)
+
+const (
+	ctxKeyScopes = "tcg-scopes"
+	ctxKeyToken  = "tcg-token"
+)
+
+// HashToken returns hash of local API token to put in config
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookupToken returns config of local API token
+func lookupToken(tokens []config.APIToken, token string) (config.APIToken, bool) {
+	hash := []byte(HashToken(token))
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(strings.ToLower(t.Hash))) == 1 {
+			return t, true
+		}
+	}
+	return config.APIToken{}, false
+}
+
+// checkToken checks local API token from Authorization header,
+// returns false if there is no bearer token to check with other methods
+func (controller *Controller) checkToken(c *gin.Context) bool {
+	token, ok := strings.CutPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
+	if !ok || len(controller.Connector.ControllerTokens) == 0 {
+		return false
+	}
+	t, ok := lookupToken(controller.Connector.ControllerTokens, token)
+	if !ok {
+		log.Warn().Str("url", c.Request.URL.Redacted()).
+			Msg("access disallowed with token")
+		c.AbortWithStatusJSON(http.StatusUnauthorized,
+			gin.H{"error": "invalid token"})
+		return true
+	}
+	log.Debug().Func(func(e *zerolog.Event) { e.Str("url", c.Request.URL.Redacted()) }).
+		Str("token", t.Name).
+		Strs("scopes", t.Scopes).
+		Msg("access allowed with token")
+	c.Set(ctxKeyScopes, t.Scopes)
+	c.Set(ctxKeyToken, t.Name)
+	c.Set(ctxKeyPrincipal, "token:"+t.Name)
+	return true
+}
+
+// requireScope limits access with local API tokens to routes of scope,
+// other access methods are not limited
+func requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get(ctxKeyScopes)
+		if !ok {
+			return
+		}
+		scopes, _ := v.([]string)
+		if slices.Contains(scopes, scope) || slices.Contains(scopes, ScopeAdmin) {
+			return
+		}
+		log.Warn().Str("url", c.Request.URL.Redacted()).
+			Str("scope", scope).
+			Msg("access disallowed with token out of scope")
+		c.AbortWithStatusJSON(http.StatusForbidden,
+			gin.H{"error": "token has no scope: " + scope})
+	}
+}
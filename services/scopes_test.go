@@ -0,0 +1,55 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gwos/tcg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopes(t *testing.T) {
+	controller := GetController()
+	tokens0 := controller.Connector.ControllerTokens
+	controller.Connector.ControllerTokens = []config.APIToken{
+		{Name: "reader", Hash: HashToken("READ-TOKEN"), Scopes: []string{ScopeReadStats}},
+		{Name: "pusher", Hash: HashToken("PUSH-TOKEN"), Scopes: []string{ScopeIngestMetrics, ScopeIngestEvents}},
+	}
+	t.Cleanup(func() { controller.Connector.ControllerTokens = tokens0 })
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	controller.registerAPI1(router, "localhost:0", []Entrypoint{
+		{URL: "/test/push", Method: http.MethodPost, Scope: ScopeIngestMetrics,
+			Handler: func(c *gin.Context) { c.Status(http.StatusNoContent) }},
+		{URL: "/test/read", Method: http.MethodGet, Scope: ScopeReadStats,
+			Handler: func(c *gin.Context) { c.Status(http.StatusNoContent) }},
+		{URL: "/test/admin", Method: http.MethodPost,
+			Handler: func(c *gin.Context) { c.Status(http.StatusNoContent) }},
+	})
+
+	for _, tc := range []struct {
+		method, url, token string
+		code               int
+	}{
+		{http.MethodGet, "/api/v1/test/read", "READ-TOKEN", http.StatusNoContent},
+		{http.MethodPost, "/api/v1/stop", "READ-TOKEN", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/stop", "PUSH-TOKEN", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/config", "PUSH-TOKEN", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/reset-nats", "PUSH-TOKEN", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/inventory", "PUSH-TOKEN", http.StatusForbidden},
+		{http.MethodGet, "/api/v1/metrics", "PUSH-TOKEN", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/test/push", "PUSH-TOKEN", http.StatusNoContent},
+		{http.MethodPost, "/api/v1/test/push", "READ-TOKEN", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/test/admin", "PUSH-TOKEN", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/stop", "WRONG-TOKEN", http.StatusUnauthorized},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.url, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, tc.method+" "+tc.url+" "+tc.token)
+	}
+}
@@ -66,14 +66,17 @@ var (
 )
 
 // Define NATS subjects
-// group downtimes, events actions, and inventory with metrics
-// as try to keep the processing order.
+// group downtimes and events actions as try to keep the processing order,
+// inventory is ordered before metrics, in separate stream it holds other streams.
+// Inventory is published to subject of agent, like "tcg.inventory.<agentId>",
+// so the stream limited with maxMsgsPerSubject keeps the latest inventory of each agent.
 // It is recommended to keep the maximum number of tokens in your subjects
 // to a reasonable value of 16 tokens max. (https://docs.nats.io/nats-concepts/subjects)
 const (
-	subjDowntimes        = "tcg.downtimes"
-	subjEvents           = "tcg.events"
-	subjInventoryMetrics = "tcg.metrics"
+	subjDowntimes = "tcg.downtimes"
+	subjEvents    = "tcg.events"
+	subjInventory = "tcg.inventory"
+	subjMetrics   = "tcg.metrics"
 )
 
 // Status defines status value
@@ -142,6 +145,7 @@ func (p Stats) MarshalJSON() ([]byte, error) {
 type AgentStatsExt struct {
 	transit.AgentIdentity
 	Stats
+	Deliveries []DeliveryStats    `json:"deliveries"`
 	LastErrors []logzer.LogRecord `json:"lastErrors"`
 }
 
@@ -162,6 +166,11 @@ func (p AgentStatsExt) MarshalJSON() ([]byte, error) {
 		return nil, err
 	}
 	buf = append(buf, bb[1:len(bb)-1]...)
+	buf = append(buf, `,"deliveries":`...)
+	if bb, err = json.Marshal(p.Deliveries); err != nil {
+		return nil, err
+	}
+	buf = append(buf, bb...)
 	buf = append(buf, `,"lastErrors":`...)
 	if bb, err = json.Marshal(p.LastErrors); err != nil {
 		return nil, err
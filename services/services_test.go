@@ -17,6 +17,7 @@ func Test_statsMarshal(t *testing.T) {
 	p, err := json.Marshal(GetAgentService().Stats())
 	assert.NoError(t, err)
 	assert.Contains(t, string(p), "agentId")
+	assert.Contains(t, string(p), `"deliveries":[`)
 	assert.Contains(t, string(p), `"lastErrors":[`)
 	assert.Contains(t, string(p), `"upSince":"`)
 }
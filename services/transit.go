@@ -1,6 +1,7 @@
 package services
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -8,11 +9,14 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/gwos/tcg/batcher"
 	"github.com/gwos/tcg/batcher/events"
+	"github.com/gwos/tcg/batcher/inventory"
 	"github.com/gwos/tcg/batcher/metrics"
 	"github.com/gwos/tcg/config"
 	"github.com/gwos/tcg/sdk/clients"
@@ -39,8 +43,9 @@ type TransitService struct {
 	*AgentService
 	listMetricsHandler func() ([]byte, error)
 
-	eventsBatcher  *batcher.Batcher
-	metricsBatcher *batcher.Batcher
+	eventsBatcher    *batcher.Batcher
+	inventoryBatcher *batcher.Batcher
+	metricsBatcher   *batcher.Batcher
 }
 
 var onceTransitService sync.Once
@@ -65,10 +70,43 @@ func GetTransitService() *TransitService {
 			transitService.Connector.BatchMetrics,
 			transitService.Connector.BatchMaxBytes,
 		)
+		transitService.inventoryBatcher = batcher.NewBatcher(
+			new(inventory.InventoryBatchBuilder),
+			transitService.sendInventory,
+			transitService.Connector.BatchInventory,
+			transitService.Connector.BatchMaxBytes,
+		)
+		transitService.inventoryBatcher.SetDebounce(true)
+		transitService.setBatchWAL(transitService.Connector.BatchWALDir)
 	})
 	return transitService
 }
 
+// setBatchWAL keeps batch buffers in write-ahead files in dir,
+// moves buffers on dir changes, empty dir keeps them in memory only
+func (service *TransitService) setBatchWAL(dir string) {
+	walPath := func(name string) string {
+		if dir == "" {
+			return ""
+		}
+		return filepath.Join(dir, name)
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			log.Err(err).Msg("could not create batch WAL dir")
+		}
+	}
+	if err := service.eventsBatcher.SetWAL(walPath("events.wal")); err != nil {
+		log.Err(err).Msg("could not set events batch WAL")
+	}
+	if err := service.metricsBatcher.SetWAL(walPath("metrics.wal")); err != nil {
+		log.Err(err).Msg("could not set metrics batch WAL")
+	}
+	if err := service.inventoryBatcher.SetWAL(walPath("inventory.wal")); err != nil {
+		log.Err(err).Msg("could not set inventory batch WAL")
+	}
+}
+
 func defaultListMetricsHandler() ([]byte, error) {
 	return nil, fmt.Errorf("listMetricsHandler unavailable")
 }
@@ -88,8 +126,8 @@ func (service *TransitService) RemoveListMetricsHandler() {
 	service.listMetricsHandler = defaultListMetricsHandler
 }
 
-func (service *TransitService) exportTransit(op TransitOperation, payload []byte) error {
-	if len(service.Connector.ExportTransitDir) == 0 {
+func (service *TransitService) exportTransit(ctx context.Context, op TransitOperation, payload []byte) error {
+	if len(service.Connector.ExportTransitDir) == 0 || isReplayCtx(ctx) {
 		return nil
 	}
 	if err := os.MkdirAll(service.Connector.ExportTransitDir, 0777); err != nil {
@@ -122,7 +160,7 @@ func (service *TransitService) ClearInDowntime(ctx context.Context, payload []by
 		}
 	}()
 
-	if err := service.exportTransit(TOpClearInDowntime, payload); err != nil {
+	if err := service.exportTransit(ctx, TOpClearInDowntime, payload); err != nil {
 		log.Err(err).Msgf("could not exportTransit: %v", TOpClearInDowntime)
 	}
 
@@ -153,7 +191,7 @@ func (service *TransitService) SetInDowntime(ctx context.Context, payload []byte
 		}
 	}()
 
-	if err := service.exportTransit(TOpSetInDowntime, payload); err != nil {
+	if err := service.exportTransit(ctx, TOpSetInDowntime, payload); err != nil {
 		log.Err(err).Msgf("could not exportTransit: %v", TOpSetInDowntime)
 	}
 
@@ -171,7 +209,7 @@ func (service *TransitService) SetInDowntime(ctx context.Context, payload []byte
 
 // SendEvents implements TransitServices.SendEvents interface
 func (service *TransitService) SendEvents(ctx context.Context, payload []byte) error {
-	if err := service.exportTransit(TOpSendEvents, payload); err != nil {
+	if err := service.exportTransit(ctx, TOpSendEvents, payload); err != nil {
 		log.Err(err).Msgf("could not exportTransit: %v", TOpSendEvents)
 	}
 
@@ -225,7 +263,7 @@ func (service *TransitService) SendEventsAck(ctx context.Context, payload []byte
 		}
 	}()
 
-	if err := service.exportTransit(TOpSendEventsAck, payload); err != nil {
+	if err := service.exportTransit(ctx, TOpSendEventsAck, payload); err != nil {
 		log.Err(err).Msgf("could not exportTransit: %v", TOpSendEventsAck)
 	}
 
@@ -256,7 +294,7 @@ func (service *TransitService) SendEventsUnack(ctx context.Context, payload []by
 		}
 	}()
 
-	if err := service.exportTransit(TOpSendEventsUnack, payload); err != nil {
+	if err := service.exportTransit(ctx, TOpSendEventsUnack, payload); err != nil {
 		log.Err(err).Msgf("could not exportTransit: %v", TOpSendEventsUnack)
 	}
 
@@ -274,7 +312,7 @@ func (service *TransitService) SendEventsUnack(ctx context.Context, payload []by
 
 // SendResourceWithMetrics implements TransitServices.SendResourceWithMetrics interface
 func (service *TransitService) SendResourceWithMetrics(ctx context.Context, payload []byte) error {
-	if err := service.exportTransit(TOpSendMetrics, payload); err != nil {
+	if err := service.exportTransit(ctx, TOpSendMetrics, payload); err != nil {
 		log.Err(err).Msgf("could not exportTransit: %v", TOpSendMetrics)
 	}
 
@@ -306,6 +344,11 @@ func (service *TransitService) sendMetrics(ctx context.Context, payload []byte)
 		}
 	}()
 
+	/* inventory goes before metrics referencing it */
+	if service.Connector.BatchInventory != 0 {
+		service.inventoryBatcher.Batch()
+	}
+
 	payload, todoTracerCtx := service.mixTracerContext(payload)
 	header := make(http.Header)
 	header.Set(clients.HdrPayloadType, typeMetrics.String())
@@ -313,14 +356,14 @@ func (service *TransitService) sendMetrics(ctx context.Context, payload []byte)
 		header.Set(clients.HdrTodoTracerCtx, "-")
 	}
 	ctx = clients.CtxWithHeader(ctx, header)
-	err = Put2Nats(ctx, subjInventoryMetrics, payload)
+	err = Put2Nats(ctx, subjMetrics, payload)
 	return err
 
 	// b, err = natsPayload{span.SpanContext(), payload, typeMetrics}.Marshal()
 	// if err != nil {
 	// 	return err
 	// }
-	// err = nats.Publish(subjInventoryMetrics, b)
+	// err = nats.Publish(subjMetrics, b)
 	// return err
 }
 
@@ -344,7 +387,7 @@ func (service *TransitService) SendStates(ctx context.Context, p *transit.Resour
 	if err != nil {
 		return err
 	}
-	if err := service.exportTransit(TOpSendStates, payload); err != nil {
+	if err := service.exportTransit(ctx, TOpSendStates, payload); err != nil {
 		log.Err(err).Msgf("could not exportTransit: %v", TOpSendStates)
 	}
 
@@ -355,6 +398,9 @@ func (service *TransitService) SendStates(ctx context.Context, p *transit.Resour
 	if service.Connector.BatchMetrics != 0 {
 		service.metricsBatcher.Batch()
 	}
+	if service.Connector.BatchInventory != 0 {
+		service.inventoryBatcher.Batch()
+	}
 
 	payload, todoTracerCtx := service.mixTracerContext(payload)
 	header := make(http.Header)
@@ -363,7 +409,7 @@ func (service *TransitService) SendStates(ctx context.Context, p *transit.Resour
 		header.Set(clients.HdrTodoTracerCtx, "-")
 	}
 	ctx = clients.CtxWithHeader(ctx, header)
-	err = Put2Nats(ctx, subjInventoryMetrics, payload)
+	err = Put2Nats(ctx, subjMetrics, payload)
 	return err
 }
 
@@ -392,7 +438,7 @@ func (service *TransitService) SynchronizeInventory(ctx context.Context, payload
 		}
 	}(payload)
 
-	if err := service.exportTransit(TOpSyncInventory, payload); err != nil {
+	if err := service.exportTransit(ctx, TOpSyncInventory, payload); err != nil {
 		log.Err(err).Msgf("could not exportTransit: %v", TOpSyncInventory)
 	}
 
@@ -407,6 +453,15 @@ func (service *TransitService) SynchronizeInventory(ctx context.Context, payload
 
 	service.stats.LastInventoryRun.Set(time.Now().UnixMilli())
 
+	if service.Connector.BatchInventory == 0 {
+		err = service.sendInventory(ctx, payload)
+		return err
+	}
+	service.inventoryBatcher.Add(payload)
+	return nil
+}
+
+func (service *TransitService) sendInventory(ctx context.Context, payload []byte) error {
 	payload, todoTracerCtx := service.mixTracerContext(payload)
 	header := make(http.Header)
 	header.Set(clients.HdrPayloadType, typeInventory.String())
@@ -414,8 +469,26 @@ func (service *TransitService) SynchronizeInventory(ctx context.Context, payload
 		header.Set(clients.HdrTodoTracerCtx, "-")
 	}
 	ctx = clients.CtxWithHeader(ctx, header)
-	err = Put2Nats(ctx, subjInventoryMetrics, payload)
-	return err
+	return Put2Nats(ctx, inventorySubject(payload), payload)
+}
+
+// inventorySubject returns inventory subject of agent from payload context,
+// falls back to connector AgentID
+func inventorySubject(payload []byte) string {
+	var p struct {
+		Context struct {
+			AgentID string `json:"agentId"`
+		} `json:"context"`
+	}
+	_ = json.Unmarshal(payload, &p)
+	agentID := cmp.Or(p.Context.AgentID, GetAgentService().Connector.AgentID, "_")
+	/* keep the single subject token */
+	return subjInventory + "." + strings.Map(func(r rune) rune {
+		if r == '.' || r == '*' || r == '>' || unicode.IsSpace(r) {
+			return '_'
+		}
+		return r
+	}, agentID)
 }
 
 // SynchronizeInventoryExt processes extended inventory included additional properties
@@ -479,16 +552,12 @@ func (service *TransitService) SyncExt(ctx context.Context, p *transit.Inventory
 		}
 	}
 
-	if len(dt.BizHostServiceInDowntimes) > 0 {
-		payload, err = json.Marshal(dt)
-		if err != nil {
-			return err
-		}
-		// TODO: Sync Downtimes
-		log.Trace().RawJSON("downtimes", payload).Msg("TODO: Sync Downtimes")
+	agentID := p.Context.AgentID
+	if agentID == "" {
+		agentID = service.Connector.AgentID
 	}
-
-	return nil
+	err = service.syncDowntimes(ctx, agentID, &dt)
+	return err
 }
 
 func filterExtInfo(
@@ -577,10 +646,10 @@ func filterExtInfo(
 			if v, ok := mSvc.Properties["ScheduledDowntimeDepth"]; ok {
 				delete(mSvc.Properties, "ScheduledDowntimeDepth")
 				dt.BizHostServiceInDowntimes = append(dt.BizHostServiceInDowntimes, transit.Downtime{
-					EntityType:             "HOST",
+					EntityType:             "SERVICE",
 					EntityName:             mRes.Name,
 					HostName:               mRes.Name,
-					ServiceDescription:     mSvc.Description,
+					ServiceDescription:     mSvc.Name,
 					ScheduledDowntimeDepth: int(*v.IntegerValue),
 				})
 			}
@@ -1,8 +1,11 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/gwos/tcg/sdk/transit"
 	"github.com/stretchr/testify/assert"
@@ -374,3 +377,54 @@ func Test_SyncExt(t *testing.T) {
 		assert.JSONEq(t, jsonMonitoring, string(payload))
 	})
 }
+
+func TestInventoryBeforeMetrics(t *testing.T) {
+	service := GetTransitService()
+	batchInventory, batchMetrics := service.Connector.BatchInventory, service.Connector.BatchMetrics
+	storeDir := service.Connector.NatsStoreDir
+	t.Cleanup(func() {
+		service.Connector.BatchInventory, service.Connector.BatchMetrics = batchInventory, batchMetrics
+		service.inventoryBatcher.Clear()
+		assert.NoError(t, service.StopNats())
+		service.Connector.NatsStoreDir = storeDir
+	})
+	service.Connector.BatchInventory, service.Connector.BatchMetrics = time.Hour, 0
+	service.Connector.NatsStoreDir = t.TempDir()
+
+	ctx := context.Background()
+	assert.NoError(t, service.StartNats())
+	assert.NoError(t, service.SynchronizeInventory(ctx, []byte(`{"resources":[{"name":"host","type":"host"}]}`)))
+	assert.NoError(t, service.SendResourceWithMetrics(ctx, []byte(`{"resources":[{"name":"host"}]}`)))
+
+	var records []StreamRecord
+	assert.Eventually(t, func() bool {
+		var err error
+		records, err = service.ListStreamMsgs(ctx, "", "", 0, 0, 100)
+		return err == nil && len(records) > 1 && records[len(records)-1].Subject == subjMetrics
+	}, time.Second*5, time.Millisecond*100)
+	/* payloads buffered by other tests may go first */
+	if assert.Greater(t, len(records), 1) {
+		assert.Equal(t, inventorySubject(nil), records[len(records)-2].Subject)
+		assert.Equal(t, subjMetrics, records[len(records)-1].Subject)
+	}
+}
+
+func TestSetBatchWAL(t *testing.T) {
+	service := GetTransitService()
+	t.Cleanup(func() { service.setBatchWAL(service.Connector.BatchWALDir) })
+
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	service.setBatchWAL(dir1)
+	for _, name := range []string{"events.wal", "inventory.wal", "metrics.wal"} {
+		assert.FileExists(t, filepath.Join(dir1, name))
+	}
+	service.setBatchWAL(dir2)
+	for _, name := range []string{"events.wal", "inventory.wal", "metrics.wal"} {
+		assert.NoFileExists(t, filepath.Join(dir1, name))
+		assert.FileExists(t, filepath.Join(dir2, name))
+	}
+	service.setBatchWAL("")
+	for _, name := range []string{"events.wal", "inventory.wal", "metrics.wal"} {
+		assert.NoFileExists(t, filepath.Join(dir2, name))
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gwos/tcg/config"
+	tcgnats "github.com/gwos/tcg/nats"
+	"github.com/gwos/tcg/sdk/clients"
+	"github.com/gwos/tcg/sdk/transit"
+	"github.com/rs/zerolog/log"
+)
+
+// Events of outbound webhooks
+const (
+	EventConfigReceived       = "configReceived"
+	EventTransportStarted     = "transportStarted"
+	EventTransportStopped     = "transportStopped"
+	EventGWUnauthorized       = "gwUnauthorized"
+	EventNatsStoreNearLimit   = "natsStoreNearLimit"
+	EventBacklogOverThreshold = "backlogOverThreshold"
+)
+
+// WebhookEvent defines payload of outbound webhook
+type WebhookEvent struct {
+	Event         string                `json:"event"`
+	Time          time.Time             `json:"time"`
+	Message       string                `json:"message,omitempty"`
+	AgentIdentity transit.AgentIdentity `json:"agentIdentity"`
+}
+
+// webhookRetryDelays defines delays between attempts of sending webhook
+var webhookRetryDelays = []time.Duration{time.Second, time.Second * 5, time.Second * 30}
+
+var (
+	xWebhooksSent   = expvar.NewInt("tcgWebhooksSent")
+	xWebhooksFailed = expvar.NewInt("tcgWebhooksFailed")
+)
+
+// notify sends event to subscribed webhooks in background
+func (service *AgentService) notify(event, message string) {
+	hooks := service.Connector.Webhooks
+	if len(hooks) == 0 {
+		return
+	}
+	payload, err := json.Marshal(WebhookEvent{
+		Event:         event,
+		Time:          time.Now().UTC(),
+		Message:       message,
+		AgentIdentity: service.Connector.AgentIdentity,
+	})
+	if err != nil {
+		log.Err(err).Str("event", event).Msg("could not marshal webhook event")
+		return
+	}
+	for _, hook := range hooks {
+		if len(hook.Events) == 0 || slices.Contains(hook.Events, event) {
+			go func() { _ = sendWebhook(hook, event, payload) }()
+		}
+	}
+}
+
+// sendWebhook posts payload to webhook with retries
+func sendWebhook(hook config.Webhook, event string, payload []byte) error {
+	headers := map[string]string{"Content-Type": "application/json"}
+	for k, v := range hook.Headers {
+		s, err := config.ResolveSecret(v)
+		if err != nil {
+			xWebhooksFailed.Add(1)
+			log.Err(err).Str("url", hook.URL).Str("event", event).
+				Msgf("could not resolve webhook header: %s", k)
+			return err
+		}
+		headers[k] = s
+	}
+
+	var err error
+	for i := 0; ; i++ {
+		req := clients.Req{
+			URL:     hook.URL,
+			Method:  http.MethodPost,
+			Headers: headers,
+			Payload: payload,
+		}
+		if err = req.Send(); err == nil {
+			if req.Status >= 200 && req.Status < 300 {
+				xWebhooksSent.Add(1)
+				log.Debug().Str("url", hook.URL).Str("event", event).Msg("sent webhook")
+				return nil
+			}
+			err = fmt.Errorf("unexpected status: %d", req.Status)
+		}
+		if i >= len(webhookRetryDelays) {
+			break
+		}
+		time.Sleep(webhookRetryDelays[i])
+	}
+	xWebhooksFailed.Add(1)
+	log.Warn().Err(err).Str("url", hook.URL).Str("event", event).Msg("could not send webhook")
+	return err
+}
+
+// watchWebhooks checks NATS store and backlog every WebhookCheckInterval
+// and notifies once on crossing the threshold, then again after recovery
+func (service *AgentService) watchWebhooks() {
+	if service.Connector.WebhookCheckInterval <= 0 {
+		log.Debug().Msg("checks for webhooks are not configured")
+		return
+	}
+	go func() {
+		var storeAlert, backlogAlert bool
+		alert := func(active *bool, event, message string, on bool) {
+			if on && !*active {
+				service.notify(event, message)
+			}
+			*active = on
+		}
+		for {
+			interval := service.Connector.WebhookCheckInterval
+			if interval <= 0 {
+				log.Info().Msg("stopped checks for webhooks")
+				return
+			}
+			time.Sleep(interval)
+			if len(service.Connector.Webhooks) == 0 {
+				continue
+			}
+			msg, on := service.checkStoreUsage()
+			alert(&storeAlert, EventNatsStoreNearLimit, msg, on)
+			c := service.checkBacklog()
+			alert(&backlogAlert, EventBacklogOverThreshold, c.Message, !c.OK)
+		}
+	}()
+}
+
+// checkStoreUsage returns true if usage of any NATS stream reaches WebhookStoreThreshold
+// of max bytes or max messages
+func (service *AgentService) checkStoreUsage() (string, bool) {
+	threshold := service.Connector.WebhookStoreThreshold
+	if threshold <= 0 || !tcgnats.IsStartedServer() {
+		return "", false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	infos, err := tcgnats.StreamInfos(ctx)
+	if err != nil {
+		log.Debug().Err(err).Msg("could not get stream info")
+		return "", false
+	}
+	usage := func(v uint64, limit int64) float64 {
+		if limit <= 0 {
+			return 0
+		}
+		return float64(v) * 100 / float64(limit)
+	}
+	for _, info := range infos {
+		bytesUsage := usage(info.State.Bytes, info.Config.MaxBytes)
+		msgsUsage := usage(info.State.Msgs, info.Config.MaxMsgs)
+		if bytesUsage >= threshold || msgsUsage >= threshold {
+			return fmt.Sprintf("stream %s uses %.1f%% of max bytes, %.1f%% of max messages",
+				info.Config.Name, bytesUsage, msgsUsage), true
+		}
+	}
+	return "", false
+}
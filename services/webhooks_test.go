@@ -0,0 +1,67 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gwos/tcg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhooks(t *testing.T) {
+	delays := webhookRetryDelays
+	webhookRetryDelays = []time.Duration{time.Millisecond, time.Millisecond}
+	defer func() { webhookRetryDelays = delays }()
+
+	var calls atomic.Int32
+	received := make(chan WebhookEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		/* fail first attempt to check retries */
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("X-Token"))
+		body, _ := io.ReadAll(r.Body)
+		var e WebhookEvent
+		assert.NoError(t, json.Unmarshal(body, &e))
+		received <- e
+	}))
+	defer srv.Close()
+
+	t.Setenv("TEST_WEBHOOK_TOKEN", "secret")
+	service := GetAgentService()
+	webhooks := service.Connector.Webhooks
+	defer func() { service.Connector.Webhooks = webhooks }()
+	service.Connector.Webhooks = []config.Webhook{{
+		URL:     srv.URL,
+		Events:  []string{EventGWUnauthorized},
+		Headers: map[string]string{"X-Token": config.SecretRefEnv + "TEST_WEBHOOK_TOKEN"},
+	}}
+
+	/* not subscribed event is skipped */
+	service.notify(EventConfigReceived, "")
+	service.notify(EventGWUnauthorized, "unauthorized")
+	select {
+	case e := <-received:
+		assert.Equal(t, EventGWUnauthorized, e.Event)
+		assert.Equal(t, "unauthorized", e.Message)
+		assert.Equal(t, service.Connector.AgentIdentity, e.AgentIdentity)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook is not received")
+	}
+	assert.Equal(t, int32(2), calls.Load())
+
+	/* failed after all retries */
+	assert.Error(t, sendWebhook(config.Webhook{URL: srv.URL + "/missing"}, EventTransportStopped, []byte("{}")))
+}
@@ -0,0 +1,119 @@
+//go:build !codeanalysis
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gwos/tcg/config"
+	"github.com/gwos/tcg/connectors"
+	"github.com/gwos/tcg/connectors/apm"
+	"github.com/gwos/tcg/connectors/azure"
+	"github.com/gwos/tcg/connectors/checker"
+	"github.com/gwos/tcg/connectors/elastic"
+	"github.com/gwos/tcg/connectors/events/helpers"
+	"github.com/gwos/tcg/connectors/k8s"
+	"github.com/gwos/tcg/connectors/office"
+	"github.com/gwos/tcg/connectors/oracle"
+	"github.com/gwos/tcg/connectors/server"
+	"github.com/gwos/tcg/connectors/snmp"
+	"github.com/gwos/tcg/sdk/transit"
+	"github.com/spf13/pflag"
+)
+
+const CmdValidate = "validate"
+
+// runValidate checks config file, env overrides and connector config offline,
+// prints found problems to stderr and the effective config with secrets redacted to stdout,
+// returns exit code
+func runValidate(args []string) int {
+	flags := pflag.NewFlagSet(CmdValidate, pflag.ContinueOnError)
+	configPath := flags.String("config", "",
+		"path to config file, $TCG_CONFIG or tcg_config.yaml in work directory by default")
+	connectorName := flags.String("connector", "",
+		"connector to validate connector config with, appName from config by default")
+	connectorConfig := flags.String("connector-config", "",
+		"path to connector config in json as delivered to /api/v1/config")
+	config.AddEnvFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	config.ApplyEnvFlags()
+	if *configPath == "" {
+		*configPath = config.Config{}.ConfigPath()
+	}
+
+	cfg, problems := config.Validate(*configPath)
+	if *connectorConfig != "" {
+		if data, err := os.ReadFile(*connectorConfig); err != nil {
+			problems = append(problems, fmt.Errorf("could not read connector config: %w", err))
+		} else {
+			if err := cfg.MergeConnector(data); err != nil {
+				problems = append(problems, err)
+			}
+			name := *connectorName
+			if name == "" {
+				name = cfg.Connector.AppName
+			}
+			if err := validateConnector(name, data); err != nil {
+				problems = append(problems, err)
+			}
+		}
+	}
+
+	if output, err := cfg.RedactedYAML(); err != nil {
+		problems = append(problems, fmt.Errorf("could not prepare effective config: %w", err))
+	} else {
+		fmt.Fprintf(os.Stdout, "%s", output)
+	}
+	for _, err := range problems {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%s: found %d problem(s)\n", *configPath, len(problems))
+		return 1
+	}
+	return 0
+}
+
+// validateConnector unmarshals connector config into ExtConfig of connector and validates it
+func validateConnector(name string, data []byte) error {
+	var ext any
+	switch {
+	case matchCmd(CmdAPM, name):
+		ext = &apm.ExtConfig{}
+	case matchCmd(CmdAzure, name):
+		ext = &azure.ExtConfig{}
+	case matchCmd(CmdChecker, name):
+		ext = &checker.ExtConfig{}
+	case matchCmd(CmdElastic, name):
+		ext = &elastic.ExtConfig{}
+	case matchCmd(CmdEvents, name):
+		ext = &helpers.ExtConfig{}
+	case matchCmd(CmdK8S, name) || matchCmd(CmdK8Sa, name):
+		ext = &k8s.ExtConfig{}
+	case matchCmd(CmdNSCA, name):
+		ext = &map[string]any{}
+	case matchCmd(CmdOffice, name):
+		ext = &office.ExtConfig{}
+	case matchCmd(CmdOracle, name):
+		ext = &oracle.ExtConfig{}
+	case matchCmd(CmdServer, name):
+		ext = &server.ExtConfig{}
+	case matchCmd(CmdSNMP, name):
+		ext = &snmp.ExtConfig{}
+	default:
+		return fmt.Errorf("unknown connector: %q", name)
+	}
+	if err := connectors.UnmarshalConfig(data,
+		&transit.MetricsProfile{}, &transit.MonitorConnection{Extensions: ext}); err != nil {
+		return fmt.Errorf("could not parse connector config: %w", err)
+	}
+	if v, ok := ext.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("could not validate connector config: %w", err)
+		}
+	}
+	return nil
+}